@@ -120,7 +120,7 @@ func receiveMessages(
 ) {
 	for msg := range client.ReceiveResponse(ctx) {
 		switch m := msg.(type) {
-		case *claude.SDKSystemMessage:
+		case *claude.SystemInitMessage:
 			handleSystemMessage(m)
 		case *claude.SDKAssistantMessage:
 			handleAssistantMessage(m)
@@ -133,10 +133,8 @@ func receiveMessages(
 	close(queryComplete)
 }
 
-func handleSystemMessage(m *claude.SDKSystemMessage) {
-	if m.Subtype == "init" {
-		fmt.Printf("✓ Session started with model: %v\n\n", m.Data["model"])
-	}
+func handleSystemMessage(m *claude.SystemInitMessage) {
+	fmt.Printf("✓ Session started with model: %s\n\n", m.Model)
 }
 
 func handleAssistantMessage(m *claude.SDKAssistantMessage) {
@@ -66,10 +66,8 @@ func runPhase1SimpleQuery(ctx context.Context,
 	var stats modelStats
 	for msg := range client.ReceiveResponse(ctx) {
 		switch m := msg.(type) {
-		case *claude.SDKSystemMessage:
-			if m.Subtype == "init" {
-				fmt.Printf("✓ Using model: %v\n", m.Data["model"])
-			}
+		case *claude.SystemInitMessage:
+			fmt.Printf("✓ Using model: %s\n", m.Model)
 		case *claude.SDKAssistantMessage:
 			fmt.Print("Claude Haiku: ")
 			for _, block := range m.Message.Content {
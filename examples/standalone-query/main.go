@@ -74,7 +74,7 @@ func main() {
 // handleMessage processes different types of SDK messages.
 func handleMessage(msg claude.SDKMessage) {
 	switch m := msg.(type) {
-	case *claude.SDKSystemMessage:
+	case *claude.SystemInitMessage:
 		handleSystemMessage(m)
 	case *claude.SDKAssistantMessage:
 		handleAssistantMessage(m)
@@ -87,10 +87,8 @@ func handleMessage(msg claude.SDKMessage) {
 }
 
 // handleSystemMessage processes system initialization messages.
-func handleSystemMessage(m *claude.SDKSystemMessage) {
-	if m.Subtype == "init" {
-		fmt.Printf("[System] Initialized with model: %v\n", m.Data["model"])
-	}
+func handleSystemMessage(m *claude.SystemInitMessage) {
+	fmt.Printf("[System] Initialized with model: %s\n", m.Model)
 }
 
 // handleAssistantMessage displays assistant response content.
@@ -0,0 +1,229 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeouts for the probes. A CLI too old to know --help may not exit on it,
+// so that probe gives up sooner.
+const (
+	versionProbeTimeout = 30 * time.Second
+	helpProbeTimeout    = 5 * time.Second
+)
+
+// flagRegex matches the long flags listed in --help output.
+var flagRegex = regexp.MustCompile(`--[A-Za-z][A-Za-z0-9-]*`)
+
+// CLIInfo describes what an installed CLI supports, as probed from its
+// --version and --help output.
+type CLIInfo struct {
+	// Version is the CLI version, for example "2.0.14".
+	Version string
+	// Flags holds the long flags listed by --help. It is empty when the help
+	// output lists none, in which case every flag is assumed supported.
+	Flags []string
+}
+
+// HasFlag reports whether the CLI accepts flag, given with its leading
+// dashes. It returns true when the flags are unknown.
+func (i *CLIInfo) HasFlag(flag string) bool {
+	if len(i.Flags) == 0 {
+		return true
+	}
+	n := sort.SearchStrings(i.Flags, flag)
+
+	return n < len(i.Flags) && i.Flags[n] == flag
+}
+
+// probeEntry holds the probe results for one executable. The version and
+// the help output are probed separately, so the version check alone does
+// not pay for --help.
+type probeEntry struct {
+	mu      sync.Mutex
+	version string
+	flags   []string
+	helped  bool
+}
+
+// probeCache holds probe results for the life of the process, keyed by the
+// executable's path, modification time and size and by the wrapper and
+// identity it runs under (see launchKey), so an upgraded CLI is probed again.
+var probeCache = struct {
+	sync.Mutex
+	entries map[string]*probeEntry
+}{entries: make(map[string]*probeEntry)}
+
+// ProbeCLI returns the version and flags of executable, running it with
+// --version and --help the first time it is seen. The probe runs the way
+// NewProcess would run the CLI for config: in its working directory, with
+// its environment, user and groups, and inside its command wrapper. Results
+// are cached until the file changes; a later config that differs only in
+// environment or working directory reuses them. If --help fails, the flags are left
+// unknown.
+func ProbeCLI(executable string, config *ProcessConfig) (*CLIInfo, error) {
+	entry := probeEntryFor(executable, config)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if err := entry.probeVersion(executable, config); err != nil {
+		return nil, err
+	}
+	if !entry.helped {
+		if output, err := runProbe(executable, config, "--help", helpProbeTimeout); err == nil {
+			entry.flags = parseFlags(output)
+		}
+		entry.helped = true
+	}
+
+	return &CLIInfo{Version: entry.version, Flags: entry.flags}, nil
+}
+
+// probeVersion runs --version unless the version is already known.
+func (e *probeEntry) probeVersion(executable string, config *ProcessConfig) error {
+	if e.version != "" {
+		return nil
+	}
+
+	output, err := runProbe(executable, config, "--version", versionProbeTimeout)
+	if err != nil {
+		return fmt.Errorf(errWrapFormat, ErrVersionCheckFailed, err)
+	}
+
+	major, minor, patch, err := parseVersion(output)
+	if err != nil {
+		return fmt.Errorf(errWrapFormat, ErrVersionParseFailed, err)
+	}
+	e.version = fmt.Sprintf("%d.%d.%d", major, minor, patch)
+
+	return nil
+}
+
+// cliVersion returns the version of executable, probing it if needed.
+func cliVersion(executable string, config *ProcessConfig) (string, error) {
+	entry := probeEntryFor(executable, config)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if err := entry.probeVersion(executable, config); err != nil {
+		return "", err
+	}
+
+	return entry.version, nil
+}
+
+// probeEntryFor returns the cache entry for executable. An executable that
+// cannot be found gets a fresh entry that is not cached, so the probe runs
+// and reports the failure.
+func probeEntryFor(executable string, config *ProcessConfig) *probeEntry {
+	path := executable
+	if !strings.ContainsRune(path, filepath.Separator) {
+		found, err := exec.LookPath(path)
+		if err != nil {
+			return &probeEntry{}
+		}
+		path = found
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return &probeEntry{}
+	}
+
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%x",
+		path, info.ModTime().UnixNano(), info.Size(), launchKey(config))
+
+	probeCache.Lock()
+	defer probeCache.Unlock()
+
+	entry, ok := probeCache.entries[key]
+	if !ok {
+		entry = &probeEntry{}
+		probeCache.entries[key] = entry
+	}
+
+	return entry
+}
+
+// launchKey digests the command wrapper and the identity of config, the
+// parts that decide which CLI runs. The environment is left out: it changes
+// from run to run and would grow the cache without bound.
+func launchKey(config *ProcessConfig) [sha256.Size]byte {
+	if config == nil {
+		config = &ProcessConfig{}
+	}
+
+	h := sha256.New()
+	for _, part := range [][]string{
+		config.CommandWrapper,
+		{config.User, config.Group},
+		config.SupplementaryGroups,
+		{fmt.Sprint(config.SupplementaryGroups == nil)},
+	} {
+		for _, s := range part {
+			h.Write([]byte(s))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+
+	return sum
+}
+
+// runProbe runs executable with arg and returns its combined output.
+func runProbe(executable string, config *ProcessConfig, arg string, timeout time.Duration) (string, error) {
+	probe := ProcessConfig{}
+	if config != nil {
+		probe = *config
+	}
+	probe.Args = []string{arg}
+
+	id, err := resolveIdentity(&probe)
+	if err != nil {
+		return "", err
+	}
+	var userEnv []string
+	if id != nil {
+		userEnv = id.env
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := createCommand(ctx, executable, &probe, userEnv)
+	configureUserCredential(cmd, id)
+	// Children of a killed probe may hold the output pipe open.
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+
+	return string(output), nil
+}
+
+// parseFlags returns the sorted, distinct long flags in help output.
+func parseFlags(help string) []string {
+	seen := make(map[string]bool)
+	var flags []string
+	for _, flag := range flagRegex.FindAllString(help, -1) {
+		if !seen[flag] {
+			seen[flag] = true
+			flags = append(flags, flag)
+		}
+	}
+	sort.Strings(flags)
+
+	return flags
+}
@@ -0,0 +1,219 @@
+//go:build !windows
+
+package transport
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// probeScript answers --version and --help like the CLI and logs every
+// invocation to a file next to it.
+const probeScript = `#!/bin/sh
+echo "$1" >> "$(dirname "$0")/calls"
+case "$1" in
+--version) echo "2.0.14 (Claude Code)" ;;
+--help) cat <<'EOF'
+Usage: claude [options] [command] [prompt]
+
+Options:
+  -p, --print                       Print response and exit
+  --output-format <format>          Output format
+  --model <model>                   Model for the current session
+  --allowedTools, --allowed-tools <tools...>
+  -h, --help                        Display help for command
+EOF
+;;
+esac
+`
+
+// writeProbeScript writes script as an executable and returns its path and
+// the path of its call log.
+func writeProbeScript(t *testing.T, script string) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "claude")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	return path, filepath.Join(dir, "calls")
+}
+
+// probeCalls returns the arguments the script was run with, in order.
+func probeCalls(t *testing.T, log string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+
+	return strings.Fields(string(data))
+}
+
+func TestProbeCLI_ParsesVersionAndFlags(t *testing.T) {
+	path, _ := writeProbeScript(t, probeScript)
+
+	info, err := ProbeCLI(path, nil)
+	if err != nil {
+		t.Fatalf("ProbeCLI: %v", err)
+	}
+
+	if info.Version != "2.0.14" {
+		t.Errorf("expected version 2.0.14, got %q", info.Version)
+	}
+	for _, flag := range []string{"--print", "--output-format", "--model", "--allowed-tools", "--allowedTools", "--help"} {
+		if !info.HasFlag(flag) {
+			t.Errorf("expected %s to be supported; flags %v", flag, info.Flags)
+		}
+	}
+	for _, flag := range []string{"--tools", "--include-partial-messages", "-p"} {
+		if info.HasFlag(flag) {
+			t.Errorf("expected %s to be unsupported", flag)
+		}
+	}
+}
+
+func TestProbeCLI_ProbesOncePerExecutable(t *testing.T) {
+	path, log := writeProbeScript(t, probeScript)
+
+	for range 3 {
+		if _, err := ProbeCLI(path, nil); err != nil {
+			t.Fatalf("ProbeCLI: %v", err)
+		}
+		if err := checkCLIVersion(path, nil); err != nil {
+			t.Fatalf("checkCLIVersion: %v", err)
+		}
+	}
+
+	if calls := probeCalls(t, log); strings.Join(calls, " ") != "--version --help" {
+		t.Errorf("expected one --version and one --help run, got %v", calls)
+	}
+}
+
+func TestProbeCLI_ProbesAgainWhenExecutableChanges(t *testing.T) {
+	path, log := writeProbeScript(t, probeScript)
+
+	if _, err := ProbeCLI(path, nil); err != nil {
+		t.Fatalf("ProbeCLI: %v", err)
+	}
+
+	// An upgrade replaces the file; move its mtime as well in case the
+	// filesystem's timestamps are coarse.
+	upgraded := strings.Replace(probeScript, "2.0.14", "2.1.0", 1)
+	if err := os.WriteFile(path, []byte(upgraded), 0o755); err != nil {
+		t.Fatalf("rewrite script: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	info, err := ProbeCLI(path, nil)
+	if err != nil {
+		t.Fatalf("ProbeCLI: %v", err)
+	}
+	if info.Version != "2.1.0" {
+		t.Errorf("expected upgraded version 2.1.0, got %q", info.Version)
+	}
+	if calls := probeCalls(t, log); len(calls) != 4 {
+		t.Errorf("expected the upgraded CLI to be probed again, got %v", calls)
+	}
+}
+
+func TestProbeCLI_RunsWithProcessEnvironment(t *testing.T) {
+	path, _ := writeProbeScript(t, `#!/bin/sh
+echo "claude version $CLI_VERSION"
+`)
+
+	// The base environment replaces the SDK's own, as EnvPolicy does.
+	probe := func(version string) string {
+		t.Helper()
+		info, err := ProbeCLI(path, &ProcessConfig{
+			BaseEnv: []string{"PATH=" + os.Getenv("PATH")},
+			Env:     []string{"CLI_VERSION=" + version},
+		})
+		if err != nil {
+			t.Fatalf("ProbeCLI: %v", err)
+		}
+		return info.Version
+	}
+	if got := probe("2.0.14"); got != "2.0.14" {
+		t.Errorf("expected version 2.0.14, got %q", got)
+	}
+
+	// The environment is not part of the cache key, so a second
+	// environment reuses the first result.
+	if got := probe("2.1.0"); got != "2.0.14" {
+		t.Errorf("expected cached version 2.0.14, got %q", got)
+	}
+}
+
+func TestLaunchKey_CoversIdentity(t *testing.T) {
+	base := launchKey(&ProcessConfig{})
+	for _, config := range []*ProcessConfig{
+		{User: "nobody"},
+		{Group: "nogroup"},
+		{SupplementaryGroups: []string{}},
+		{CommandWrapper: []string{"firejail"}},
+	} {
+		if launchKey(config) == base {
+			t.Errorf("expected %+v to change the cache key", config)
+		}
+	}
+	for _, config := range []*ProcessConfig{
+		{BaseEnv: []string{}},
+		{Env: []string{"A=1"}},
+		{Cwd: "/work"},
+	} {
+		if launchKey(config) != base {
+			t.Errorf("expected %+v to keep the cache key", config)
+		}
+	}
+}
+
+func TestProbeCLI_VersionCheckSkipsHelp(t *testing.T) {
+	path, log := writeProbeScript(t, probeScript)
+
+	if err := checkCLIVersion(path, nil); err != nil {
+		t.Fatalf("checkCLIVersion: %v", err)
+	}
+
+	if calls := probeCalls(t, log); strings.Join(calls, " ") != "--version" {
+		t.Errorf("expected only --version to run, got %v", calls)
+	}
+}
+
+func TestProbeCLI_HelpFailureLeavesFlagsUnknown(t *testing.T) {
+	path, _ := writeProbeScript(t, `#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "claude version 2.1.0"
+	exit 0
+fi
+echo "error: unknown option '$1'" >&2
+exit 1
+`)
+
+	info, err := ProbeCLI(path, nil)
+	if err != nil {
+		t.Fatalf("ProbeCLI: %v", err)
+	}
+	if len(info.Flags) != 0 || !info.HasFlag("--anything") {
+		t.Errorf("expected unknown flags to be assumed supported, got %v", info.Flags)
+	}
+}
+
+func TestProbeCLI_VersionFailure(t *testing.T) {
+	if _, err := ProbeCLI(filepath.Join(t.TempDir(), "missing"), nil); err == nil ||
+		!strings.Contains(err.Error(), "failed to check Claude CLI version") {
+		t.Errorf("expected version check failure, got %v", err)
+	}
+}
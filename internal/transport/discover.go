@@ -0,0 +1,241 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecutablePathEnvVar names an environment variable that overrides
+// executable discovery when PathToClaudeCodeExecutable is not set.
+const ExecutablePathEnvVar = "CLAUDE_AGENT_SDK_CLI_PATH"
+
+// ExecutableSource says how the CLI executable was found.
+type ExecutableSource string
+
+// Executable sources, in discovery order.
+const (
+	SourceOption       ExecutableSource = "option"
+	SourceEnv          ExecutableSource = "env"
+	SourcePath         ExecutableSource = "path"
+	SourceLocalInstall ExecutableSource = "local_install"
+	SourceNpmPrefix    ExecutableSource = "npm_prefix"
+	SourceNpmGlobal    ExecutableSource = "npm_global"
+	SourceSystem       ExecutableSource = "system"
+	SourceNodeModules  ExecutableSource = "node_modules"
+)
+
+// Executable is a resolved CLI executable.
+type Executable struct {
+	Path   string
+	Source ExecutableSource
+}
+
+// ExecutableNotFoundError is returned when discovery finds no CLI. It wraps
+// ErrClaudeExecutableNotFound.
+type ExecutableNotFoundError struct {
+	// Tried lists every location that was checked, in order.
+	Tried []string
+}
+
+func (e *ExecutableNotFoundError) Error() string {
+	return ErrClaudeExecutableNotFound.Error() + "; tried: " + strings.Join(e.Tried, ", ")
+}
+
+func (e *ExecutableNotFoundError) Unwrap() error {
+	return ErrClaudeExecutableNotFound
+}
+
+// systemBinDir is the system-wide install location; a variable so tests can
+// move it.
+var systemBinDir = "/usr/local/bin"
+
+// npmPrefixTimeout bounds the `npm prefix -g` run.
+const npmPrefixTimeout = 2 * time.Second
+
+// npmGlobalPrefix returns npm's global prefix as `npm prefix -g` reports it,
+// or "" when npm is missing or fails. npm runs once per process; a variable
+// so tests can replace it.
+var npmGlobalPrefix = sync.OnceValue(func() string {
+	ctx, cancel := context.WithTimeout(context.Background(), npmPrefixTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "npm", "prefix", "-g")
+	cmd.WaitDelay = time.Second
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(output))
+})
+
+// executableCache holds discovered executables for the life of the process,
+// keyed by everything discovery depends on.
+var executableCache = struct {
+	sync.Mutex
+	found map[string]Executable
+}{found: make(map[string]Executable)}
+
+// candidate is a location discovery checks.
+type candidate struct {
+	path   string
+	source ExecutableSource
+}
+
+// FindExecutable resolves the CLI executable. An explicit path is used as
+// given, then the ExecutablePathEnvVar override. Otherwise the first
+// executable "claude" is taken from PATH, ~/.claude/local, the npm global
+// prefix (see npmPrefix), ~/.npm-global/bin, /usr/local/bin and
+// node_modules/.bin under cwd (or the current directory). Discovered paths
+// are cached until the file disappears.
+func FindExecutable(explicit, cwd string) (Executable, error) {
+	if explicit != "" {
+		return Executable{Path: explicit, Source: SourceOption}, nil
+	}
+	if override := os.Getenv(ExecutablePathEnvVar); override != "" {
+		return Executable{Path: override, Source: SourceEnv}, nil
+	}
+
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+	candidates := executableCandidates(cwd)
+
+	key := cacheKey(candidates)
+	executableCache.Lock()
+	defer executableCache.Unlock()
+
+	if found, ok := executableCache.found[key]; ok {
+		if _, err := os.Stat(found.Path); err == nil {
+			return found, nil
+		}
+		delete(executableCache.found, key)
+	}
+
+	tried := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		if c.source == SourcePath {
+			for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+				if dir != "" {
+					tried = append(tried, filepath.Join(dir, c.path))
+				}
+			}
+		} else {
+			tried = append(tried, c.path)
+		}
+
+		path, err := exec.LookPath(c.path)
+		if err != nil {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+
+		found := Executable{Path: path, Source: c.source}
+		executableCache.found[key] = found
+
+		return found, nil
+	}
+
+	return Executable{}, &ExecutableNotFoundError{Tried: tried}
+}
+
+// executableCandidates lists the locations to check, in order. The PATH
+// entry is the bare name, which exec.LookPath searches for.
+func executableCandidates(cwd string) []candidate {
+	candidates := []candidate{{"claude", SourcePath}}
+
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		candidates = append(candidates, candidate{filepath.Join(home, ".claude", "local", "claude"), SourceLocalInstall})
+	}
+	if prefix := npmPrefix(home); prefix != "" {
+		// npm links global binaries into the prefix itself on Windows.
+		bin := filepath.Join(prefix, "bin")
+		if runtime.GOOS == "windows" {
+			bin = prefix
+		}
+		candidates = append(candidates, candidate{filepath.Join(bin, "claude"), SourceNpmPrefix})
+	}
+	if home != "" {
+		candidates = append(candidates, candidate{filepath.Join(home, ".npm-global", "bin", "claude"), SourceNpmGlobal})
+	}
+	candidates = append(candidates, candidate{filepath.Join(systemBinDir, "claude"), SourceSystem})
+	if cwd != "" {
+		candidates = append(candidates, candidate{filepath.Join(cwd, "node_modules", ".bin", "claude"), SourceNodeModules})
+	}
+
+	return candidates
+}
+
+// npmPrefix returns npm's global prefix: NPM_CONFIG_PREFIX, the prefix set
+// in the user's .npmrc, or else what `npm prefix -g` reports, which covers
+// the default prefix of nvm, volta, Homebrew and distribution packages.
+func npmPrefix(home string) string {
+	for _, name := range []string{"NPM_CONFIG_PREFIX", "npm_config_prefix"} {
+		if prefix := os.Getenv(name); prefix != "" {
+			return prefix
+		}
+	}
+
+	npmrc := os.Getenv("NPM_CONFIG_USERCONFIG")
+	if npmrc == "" && home != "" {
+		npmrc = filepath.Join(home, ".npmrc")
+	}
+	if prefix := npmrcPrefix(npmrc, home); prefix != "" {
+		return prefix
+	}
+
+	return npmGlobalPrefix()
+}
+
+// npmrcPrefix returns the prefix setting in the npmrc file at path, with a
+// leading ~ expanded to home, or "" if the file does not set one.
+func npmrcPrefix(path, home string) string {
+	if path == "" {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	prefix := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || strings.TrimSpace(key) != "prefix" {
+			continue
+		}
+		// As in npm, the last setting wins.
+		prefix = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+
+	if rest, ok := strings.CutPrefix(prefix, "~"); ok && home != "" {
+		prefix = filepath.Join(home, rest)
+	}
+
+	return prefix
+}
+
+// cacheKey identifies a discovery: the candidate list and the PATH it
+// searched.
+func cacheKey(candidates []candidate) string {
+	var b strings.Builder
+	b.WriteString(os.Getenv("PATH"))
+	for _, c := range candidates {
+		b.WriteByte(0)
+		b.WriteString(c.path)
+	}
+
+	return b.String()
+}
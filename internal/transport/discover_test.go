@@ -0,0 +1,172 @@
+//go:build !windows
+
+package transport
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// discoveryEnv points every discovery location at empty temporary
+// directories and returns the fake home and working directory.
+func discoveryEnv(t *testing.T) (home, cwd string) {
+	t.Helper()
+
+	home, cwd = t.TempDir(), t.TempDir()
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("HOME", home)
+	t.Setenv("NPM_CONFIG_PREFIX", "")
+	t.Setenv("npm_config_prefix", "")
+	t.Setenv("NPM_CONFIG_USERCONFIG", "")
+	t.Setenv(ExecutablePathEnvVar, "")
+
+	npm := npmGlobalPrefix
+	npmGlobalPrefix = func() string { return "" }
+	t.Cleanup(func() { npmGlobalPrefix = npm })
+
+	system := systemBinDir
+	systemBinDir = t.TempDir()
+	t.Cleanup(func() { systemBinDir = system })
+
+	return home, cwd
+}
+
+// installCLI writes an executable claude into dir and returns its path.
+func installCLI(t *testing.T, dir string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return writeExecutable(t, dir, "claude", "exit 0\n")
+}
+
+func TestFindExecutable_ExplicitAndEnvOverride(t *testing.T) {
+	discoveryEnv(t)
+
+	found, err := FindExecutable("/opt/claude/bin/claude", "")
+	if err != nil || found != (Executable{"/opt/claude/bin/claude", SourceOption}) {
+		t.Errorf("expected explicit path, got %+v, %v", found, err)
+	}
+
+	t.Setenv(ExecutablePathEnvVar, "/srv/claude")
+	found, err = FindExecutable("", "")
+	if err != nil || found != (Executable{"/srv/claude", SourceEnv}) {
+		t.Errorf("expected env override, got %+v, %v", found, err)
+	}
+}
+
+func TestFindExecutable_Locations(t *testing.T) {
+	tests := []struct {
+		name   string
+		source ExecutableSource
+		dir    func(t *testing.T, home, cwd string) string
+	}{
+		{"PATH", SourcePath, func(t *testing.T, _, _ string) string {
+			dir := t.TempDir()
+			t.Setenv("PATH", dir)
+
+			return dir
+		}},
+		{"local install", SourceLocalInstall, func(_ *testing.T, home, _ string) string {
+			return filepath.Join(home, ".claude", "local")
+		}},
+		{"npm prefix", SourceNpmPrefix, func(t *testing.T, _, _ string) string {
+			prefix := t.TempDir()
+			t.Setenv("NPM_CONFIG_PREFIX", prefix)
+
+			return filepath.Join(prefix, "bin")
+		}},
+		{"npmrc prefix", SourceNpmPrefix, func(t *testing.T, home, _ string) string {
+			npmrc := "registry=https://registry.npmjs.org/\nprefix = ~/.local/npm\n"
+			if err := os.WriteFile(filepath.Join(home, ".npmrc"), []byte(npmrc), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			return filepath.Join(home, ".local", "npm", "bin")
+		}},
+		{"npm default prefix", SourceNpmPrefix, func(t *testing.T, _, _ string) string {
+			prefix := t.TempDir()
+			npmGlobalPrefix = func() string { return prefix }
+
+			return filepath.Join(prefix, "bin")
+		}},
+		{"npm global", SourceNpmGlobal, func(_ *testing.T, home, _ string) string {
+			return filepath.Join(home, ".npm-global", "bin")
+		}},
+		{"system", SourceSystem, func(_ *testing.T, _, _ string) string {
+			return systemBinDir
+		}},
+		{"node_modules", SourceNodeModules, func(_ *testing.T, _, cwd string) string {
+			return filepath.Join(cwd, "node_modules", ".bin")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, cwd := discoveryEnv(t)
+			want := installCLI(t, tt.dir(t, home, cwd))
+
+			found, err := FindExecutable("", cwd)
+			if err != nil {
+				t.Fatalf("FindExecutable: %v", err)
+			}
+			if found != (Executable{want, tt.source}) {
+				t.Errorf("expected %s from %s, got %+v", want, tt.source, found)
+			}
+		})
+	}
+}
+
+func TestFindExecutable_NotFoundListsTriedPaths(t *testing.T) {
+	home, cwd := discoveryEnv(t)
+	pathDir := os.Getenv("PATH")
+	t.Setenv("NPM_CONFIG_PREFIX", "/npm-prefix")
+
+	_, err := FindExecutable("", cwd)
+
+	var notFound *ExecutableNotFoundError
+	if !errors.As(err, &notFound) || !errors.Is(err, ErrClaudeExecutableNotFound) {
+		t.Fatalf("expected ExecutableNotFoundError, got %v", err)
+	}
+
+	want := []string{
+		filepath.Join(pathDir, "claude"),
+		filepath.Join(home, ".claude", "local", "claude"),
+		"/npm-prefix/bin/claude",
+		filepath.Join(home, ".npm-global", "bin", "claude"),
+		filepath.Join(systemBinDir, "claude"),
+		filepath.Join(cwd, "node_modules", ".bin", "claude"),
+	}
+	if !slices.Equal(notFound.Tried, want) {
+		t.Errorf("expected tried paths %q, got %q", want, notFound.Tried)
+	}
+}
+
+func TestFindExecutable_CachesUntilRemoved(t *testing.T) {
+	home, cwd := discoveryEnv(t)
+	local := installCLI(t, filepath.Join(home, ".claude", "local"))
+
+	found, err := FindExecutable("", cwd)
+	if err != nil || found.Path != local {
+		t.Fatalf("expected %s, got %+v, %v", local, found, err)
+	}
+
+	// A CLI appearing earlier in the search order is not picked up while
+	// the cached one still exists.
+	onPath := installCLI(t, os.Getenv("PATH"))
+	if found, _ := FindExecutable("", cwd); found.Path != local {
+		t.Errorf("expected cached %s, got %+v", local, found)
+	}
+
+	if err := os.Remove(local); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := FindExecutable("", cwd); found != (Executable{onPath, SourcePath}) {
+		t.Errorf("expected rediscovery of %s, got %+v", onPath, found)
+	}
+}
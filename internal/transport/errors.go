@@ -6,9 +6,10 @@ import "errors"
 
 var (
 	// ErrClaudeExecutableNotFound is returned when the `claude` executable
-	// cannot be found in PATH and no explicit path was provided.
+	// cannot be found in any of the discovery locations and no explicit
+	// path was provided. See FindExecutable.
 	ErrClaudeExecutableNotFound = errors.New(
-		"claude executable not found in PATH and no " +
+		"claude executable not found and no " +
 			"PathToClaudeCodeExecutable provided",
 	)
 
@@ -30,6 +31,9 @@ var (
 	// ErrTransportClose is returned when transport close fails.
 	ErrTransportClose = errors.New("failed to close transport")
 
+	// ErrTransportClosed is returned when writing to a closed transport.
+	ErrTransportClosed = errors.New("transport is closed")
+
 	// ErrProcessKill is returned when process kill fails.
 	ErrProcessKill = errors.New("failed to kill process")
 
@@ -51,4 +55,20 @@ var (
 	// This typically means the parent process lacks sufficient privileges
 	// (CAP_SETUID/CAP_SETGID capabilities or root access).
 	ErrUserSwitchFailed = errors.New("user switch failed")
+
+	// ErrGroupLookupFailed is returned when a group, or a user's group
+	// list, cannot be looked up.
+	ErrGroupLookupFailed = errors.New("group lookup failed")
+
+	// ErrWorkingDirectoryDenied is returned when the user the CLI runs as
+	// cannot access its working directory.
+	ErrWorkingDirectoryDenied = errors.New("working directory is not accessible to the target user")
+
+	// ErrResourceLimits is returned when resource limits are invalid or
+	// cannot be applied to the started process.
+	ErrResourceLimits = errors.New("failed to apply resource limits")
+
+	// ErrResourceLimitsUnsupported is returned when resource limits other
+	// than a wall-clock timeout are requested outside Linux.
+	ErrResourceLimitsUnsupported = errors.New("resource limits are only supported on Linux")
 )
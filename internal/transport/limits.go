@@ -0,0 +1,223 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// IO priority classes for ResourceLimits.IOClass, as used by ionice.
+const (
+	IOClassNone       = 0
+	IOClassRealtime   = 1
+	IOClassBestEffort = 2
+	IOClassIdle       = 3
+)
+
+// ResourceLimits constrains the CLI process. The rlimits, nice value and IO
+// priority are Linux-only; WallTimeout works everywhere. Zero fields are left
+// unchanged. The OS limits are in place before the command runs, so tool
+// subprocesses inherit them.
+type ResourceLimits struct {
+	// AddressSpace is RLIMIT_AS, in bytes.
+	AddressSpace uint64
+	// CPUTime is RLIMIT_CPU, rounded up to whole seconds. The soft limit
+	// is one second below the hard limit so the process gets SIGXCPU
+	// before SIGKILL.
+	CPUTime time.Duration
+	// OpenFiles is RLIMIT_NOFILE.
+	OpenFiles uint64
+	// Processes is RLIMIT_NPROC. It counts every process of the CLI's user,
+	// not just the CLI's descendants.
+	Processes uint64
+	// CoreSize is RLIMIT_CORE, in bytes; nil leaves it unchanged and zero
+	// disables core dumps.
+	CoreSize *uint64
+	// Nice is the scheduling priority of the CLI's process group.
+	Nice int
+	// IOClass and IOLevel set the IO priority of the CLI's process group.
+	IOClass int
+	IOLevel int
+	// WallTimeout terminates the CLI's process group once it has run this
+	// long.
+	WallTimeout time.Duration
+}
+
+// hasOSLimits reports whether any limit other than WallTimeout is set.
+func (l *ResourceLimits) hasOSLimits() bool {
+	return l != nil && (l.AddressSpace > 0 || l.CPUTime > 0 || l.OpenFiles > 0 ||
+		l.Processes > 0 || l.CoreSize != nil || l.Nice != 0 || l.IOClass != IOClassNone)
+}
+
+// validateResourceLimits checks limits before the process is started.
+func validateResourceLimits(limits *ResourceLimits) error {
+	if limits == nil {
+		return nil
+	}
+	if !osLimitsSupported && limits.hasOSLimits() {
+		return ErrResourceLimitsUnsupported
+	}
+	if limits.Nice < -20 || limits.Nice > 19 {
+		return fmt.Errorf("%w: nice value %d is outside -20..19", ErrResourceLimits, limits.Nice)
+	}
+	if limits.IOClass < IOClassNone || limits.IOClass > IOClassIdle {
+		return fmt.Errorf("%w: unknown IO priority class %d", ErrResourceLimits, limits.IOClass)
+	}
+	if limits.IOLevel < 0 || limits.IOLevel > 7 {
+		return fmt.Errorf("%w: IO priority level %d is outside 0..7", ErrResourceLimits, limits.IOLevel)
+	}
+
+	return nil
+}
+
+// cpuSeconds returns CPUTime rounded up to whole seconds.
+func (l *ResourceLimits) cpuSeconds() uint64 {
+	return uint64((l.CPUTime + time.Second - 1) / time.Second)
+}
+
+// limitSigns are stderr fragments that show the CLI failed on an rlimit it
+// cannot detect any other way: unlike RLIMIT_CPU, the address space, open
+// file and process limits only make system calls fail. A bare EAGAIN is
+// left out, since non-blocking and network I/O report it too; only the
+// messages for a failed fork or spawn count.
+var limitSigns = []struct {
+	limit clauderrs.ProcessLimit
+	signs []string
+}{
+	{clauderrs.LimitAddressSpace, []string{"out of memory", "allocation failed", "cannot allocate memory", "enomem"}},
+	{clauderrs.LimitOpenFiles, []string{"too many open files", "emfile"}},
+	{clauderrs.LimitProcesses, []string{
+		"fork: resource temporarily unavailable", "fork: retry", "cannot fork", "spawn eagain",
+	}},
+}
+
+// exceededLimit works out which limit, if any, stopped the process. It is
+// only called for a process that failed.
+func (p *Process) exceededLimit(signal string, stderr string) clauderrs.ProcessLimit {
+	if p.timedOut.Load() {
+		return clauderrs.LimitWallClock
+	}
+
+	limits := p.limits
+	if limits == nil {
+		return ""
+	}
+
+	if limits.CPUTime > 0 && cpuLimitHit(p.cmd.ProcessState, signal, limits) {
+		return clauderrs.LimitCPUTime
+	}
+
+	stderr = strings.ToLower(stderr)
+	for _, entry := range limitSigns {
+		if !limits.isSet(entry.limit) {
+			continue
+		}
+		for _, sign := range entry.signs {
+			if strings.Contains(stderr, sign) {
+				return entry.limit
+			}
+		}
+	}
+
+	return ""
+}
+
+// isSet reports whether the rlimit behind limit is configured.
+func (l *ResourceLimits) isSet(limit clauderrs.ProcessLimit) bool {
+	switch limit {
+	case clauderrs.LimitAddressSpace:
+		return l.AddressSpace > 0
+	case clauderrs.LimitOpenFiles:
+		return l.OpenFiles > 0
+	case clauderrs.LimitProcesses:
+		return l.Processes > 0
+	default:
+		return false
+	}
+}
+
+// limitMessage describes an exceeded limit for a ProcessError.
+func (l *ResourceLimits) limitMessage(limit clauderrs.ProcessLimit) string {
+	var detail string
+	switch limit {
+	case clauderrs.LimitWallClock:
+		detail = "wall-clock timeout of " + l.WallTimeout.String()
+	case clauderrs.LimitCPUTime:
+		detail = fmt.Sprintf("CPU time limit of %ds", l.cpuSeconds())
+	case clauderrs.LimitAddressSpace:
+		detail = fmt.Sprintf("address space limit of %d bytes", l.AddressSpace)
+	case clauderrs.LimitOpenFiles:
+		detail = fmt.Sprintf("open file limit of %d", l.OpenFiles)
+	case clauderrs.LimitProcesses:
+		detail = fmt.Sprintf("process limit of %d", l.Processes)
+	default:
+		detail = string(limit) + " limit"
+	}
+
+	return "Claude Code process exceeded its " + detail
+}
+
+// limitGate holds a started process until its limits have been applied, so
+// that nothing runs without them. The process starts as a shell that waits
+// for a line on an inherited pipe and then execs the real command; the PID,
+// and with it the limits, carry over the exec.
+type limitGate struct {
+	r, w *os.File
+}
+
+// gateScript waits for the release line on fd 3 and execs the command.
+const gateScript = `read -r _ <&3 || exit 125; exec 3<&-; exec "$0" "$@"`
+
+// started closes the parent's copy of the read end once the process exists.
+func (g *limitGate) started() {
+	if g != nil {
+		_ = g.r.Close()
+	}
+}
+
+// release applies limits to the process and lets it continue.
+func (g *limitGate) release(pid int, limits *ResourceLimits) error {
+	if g == nil {
+		return nil
+	}
+	defer g.w.Close()
+
+	if err := applyResourceLimits(pid, limits); err != nil {
+		return err
+	}
+	if _, err := g.w.WriteString("\n"); err != nil {
+		return fmt.Errorf(errWrapFormat, ErrResourceLimits, err)
+	}
+
+	return nil
+}
+
+// abort closes both ends after the process failed to start.
+func (g *limitGate) abort() {
+	if g != nil {
+		_ = g.r.Close()
+		_ = g.w.Close()
+	}
+}
+
+// enforceWallTimeout terminates the process group once d has passed, unless
+// the process exits first.
+func (p *Process) enforceWallTimeout(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-p.done:
+		return
+	case <-timer.C:
+	}
+
+	p.timedOut.Store(true)
+	p.terminate()
+	if !p.waitExit(p.gracePeriod) {
+		_ = p.kill()
+	}
+}
@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// osLimitsSupported reports whether ResourceLimits beyond WallTimeout can be
+// applied on this platform.
+const osLimitsSupported = true
+
+// Linux constants missing from package syscall.
+const (
+	rlimitNPROC      = 6
+	ioprioWhoPgrp    = 2
+	ioprioClassShift = 13
+)
+
+// rlimit is one resource limit to set with prlimit.
+type rlimit struct {
+	name       string
+	resource   int
+	soft, hard uint64
+}
+
+// rlimits lists the configured resource limits.
+func (l *ResourceLimits) rlimits() []rlimit {
+	var out []rlimit
+	if l.AddressSpace > 0 {
+		out = append(out, rlimit{"address space", syscall.RLIMIT_AS, l.AddressSpace, l.AddressSpace})
+	}
+	if l.CPUTime > 0 {
+		out = append(out, rlimit{"CPU time", syscall.RLIMIT_CPU, l.cpuSeconds(), l.cpuSeconds() + 1})
+	}
+	if l.OpenFiles > 0 {
+		out = append(out, rlimit{"open files", syscall.RLIMIT_NOFILE, l.OpenFiles, l.OpenFiles})
+	}
+	if l.Processes > 0 {
+		out = append(out, rlimit{"processes", rlimitNPROC, l.Processes, l.Processes})
+	}
+	if l.CoreSize != nil {
+		out = append(out, rlimit{"core size", syscall.RLIMIT_CORE, *l.CoreSize, *l.CoreSize})
+	}
+
+	return out
+}
+
+// newLimitGate rewrites cmd to wait behind a limitGate when limits needs
+// one, and returns nil otherwise.
+func newLimitGate(cmd *exec.Cmd, limits *ResourceLimits) (*limitGate, error) {
+	if !limits.hasOSLimits() {
+		return nil, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf(errWrapFormat, ErrResourceLimits, err)
+	}
+
+	cmd.Args = append([]string{"sh", "-c", gateScript, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+
+	return &limitGate{r: r, w: w}, nil
+}
+
+// applyResourceLimits sets the rlimits of the started process and the
+// priorities of its process group. Tool subprocesses inherit them.
+func applyResourceLimits(pid int, limits *ResourceLimits) error {
+	if !limits.hasOSLimits() {
+		return nil
+	}
+
+	for _, rl := range limits.rlimits() {
+		if err := prlimit(pid, rl.resource, syscall.Rlimit{Cur: rl.soft, Max: rl.hard}); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrResourceLimits, rl.name, err)
+		}
+	}
+
+	// The process leads its own group (see configureProcessGroup), so pid
+	// is also the group ID.
+	if limits.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, pid, limits.Nice); err != nil {
+			return fmt.Errorf("%w: nice: %w", ErrResourceLimits, err)
+		}
+	}
+	if limits.IOClass != IOClassNone {
+		prio := limits.IOClass<<ioprioClassShift | limits.IOLevel
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoPgrp, uintptr(pid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("%w: IO priority: %w", ErrResourceLimits, errno)
+		}
+	}
+
+	return nil
+}
+
+func prlimit(pid, resource int, limit syscall.Rlimit) error {
+	_, _, errno := syscall.RawSyscall6(
+		syscall.SYS_PRLIMIT64,
+		uintptr(pid),
+		uintptr(resource),
+		uintptr(unsafe.Pointer(&limit)),
+		0, 0, 0,
+	)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// cpuLimitHit reports whether the process died of its CPU time limit: the
+// soft limit sends SIGXCPU, which the process may catch, and the hard limit a
+// SIGKILL once the CPU time has run out.
+func cpuLimitHit(state *os.ProcessState, signal string, limits *ResourceLimits) bool {
+	if state == nil {
+		return false
+	}
+	switch signal {
+	case syscall.SIGXCPU.String():
+		return true
+	case syscall.SIGKILL.String():
+		return state.UserTime()+state.SystemTime() >= time.Duration(limits.cpuSeconds())*time.Second
+	default:
+		return false
+	}
+}
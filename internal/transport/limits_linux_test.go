@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestResourceLimits_AppliedToProcessAndChildren(t *testing.T) {
+	coreSize := uint64(0)
+	// The soft limits are read by a child process, as a tool subprocess
+	// would inherit them: CPU time, core size, processes, open files.
+	proc := startLimitedScript(t, `awk '/^Max (cpu time|core file size|processes|open files) /{print $(NF-2)}' /proc/self/limits
+awk '{print $19}' /proc/$$/stat
+cat >/dev/null
+`, &ResourceLimits{
+		OpenFiles: 64,
+		CoreSize:  &coreSize,
+		CPUTime:   1500 * time.Millisecond,
+		Processes: 4096,
+		Nice:      5,
+		IOClass:   IOClassIdle,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var got []string
+	for range 5 {
+		line, err := proc.Transport().Read(ctx)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		got = append(got, strings.TrimSpace(string(line)))
+	}
+
+	want := []string{"2", "0", "4096", "64", "5"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected limits %v, got %v", want, got)
+	}
+
+	if err := proc.Close(); err != nil {
+		t.Errorf("expected clean shutdown, got %v", err)
+	}
+}
+
+func TestResourceLimits_CPUTimeExceeded(t *testing.T) {
+	proc := startLimitedScript(t, "while :; do :; done\n", &ResourceLimits{
+		CPUTime: time.Second,
+	})
+
+	if limit := waitLimit(t, proc); limit != clauderrs.LimitCPUTime {
+		t.Errorf("expected CPU time limit, got %q", limit)
+	}
+}
+
+func TestResourceLimits_OpenFilesReportedFromStderr(t *testing.T) {
+	proc := startLimitedScript(t, `echo "Error: EMFILE: too many open files, open '/tmp/x'" >&2
+exit 1
+`, &ResourceLimits{OpenFiles: 64})
+
+	if limit := waitLimit(t, proc); limit != clauderrs.LimitOpenFiles {
+		t.Errorf("expected open files limit, got %q", limit)
+	}
+}
+
+func TestResourceLimits_ProcessesReportedFromStderr(t *testing.T) {
+	proc := startLimitedScript(t, `echo "Error: spawn EAGAIN" >&2
+exit 1
+`, &ResourceLimits{Processes: 64})
+
+	if limit := waitLimit(t, proc); limit != clauderrs.LimitProcesses {
+		t.Errorf("expected process limit, got %q", limit)
+	}
+}
+
+func TestResourceLimits_UnrelatedFailureIsNotALimit(t *testing.T) {
+	for _, stderr := range []string{
+		"fatal: bad config",
+		// Non-blocking and network I/O report EAGAIN too.
+		"Error: read EAGAIN: Resource temporarily unavailable",
+	} {
+		t.Run(stderr, func(t *testing.T) {
+			proc := startLimitedScript(t, "echo '"+stderr+"' >&2\nexit 3\n", &ResourceLimits{
+				OpenFiles: 64,
+				Processes: 64,
+				CPUTime:   time.Minute,
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var procErr *clauderrs.ProcessError
+			if err := proc.Wait(ctx); !errors.As(err, &procErr) {
+				t.Fatalf("expected ProcessError, got %v", err)
+			}
+			if procErr.Code() != clauderrs.ErrCodeProcessExited || procErr.Limit() != "" {
+				t.Errorf("expected plain exit, got code %s limit %q", procErr.Code(), procErr.Limit())
+			}
+		})
+	}
+}
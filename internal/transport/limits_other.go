@@ -0,0 +1,28 @@
+//go:build !linux
+
+package transport
+
+import (
+	"os"
+	"os/exec"
+)
+
+// osLimitsSupported reports whether ResourceLimits beyond WallTimeout can be
+// applied on this platform.
+const osLimitsSupported = false
+
+// newLimitGate returns nil: there are no limits to wait for.
+func newLimitGate(*exec.Cmd, *ResourceLimits) (*limitGate, error) {
+	return nil, nil
+}
+
+// applyResourceLimits is only implemented on Linux; validateResourceLimits
+// rejects OS limits elsewhere.
+func applyResourceLimits(int, *ResourceLimits) error {
+	return nil
+}
+
+// cpuLimitHit always reports false: CPU time limits are Linux-only.
+func cpuLimitHit(*os.ProcessState, string, *ResourceLimits) bool {
+	return false
+}
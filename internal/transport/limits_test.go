@@ -0,0 +1,107 @@
+//go:build !windows
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// startLimitedScript starts a shell script as the CLI process under limits.
+func startLimitedScript(t *testing.T, script string, limits *ResourceLimits) *Process {
+	t.Helper()
+	t.Setenv(SkipVersionCheckEnvVar, "true")
+
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	proc, err := NewProcess(context.Background(), &ProcessConfig{
+		Executable:          path,
+		ShutdownGracePeriod: 200 * time.Millisecond,
+		ResourceLimits:      limits,
+	})
+	if err != nil {
+		t.Fatalf("NewProcess: %v", err)
+	}
+	t.Cleanup(func() { _ = proc.Close() })
+
+	return proc
+}
+
+// waitLimit waits for the process and returns the limit it was stopped by.
+func waitLimit(t *testing.T, proc *Process) clauderrs.ProcessLimit {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := proc.Wait(ctx)
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) {
+		t.Fatalf("expected ProcessError, got %v", err)
+	}
+	if procErr.Code() != clauderrs.ErrCodeProcessLimitExceeded {
+		t.Fatalf("expected %s, got %s: %v", clauderrs.ErrCodeProcessLimitExceeded, procErr.Code(), procErr)
+	}
+
+	return procErr.Limit()
+}
+
+func TestResourceLimits_WallTimeoutTerminatesProcess(t *testing.T) {
+	// The script ignores SIGTERM, so the timeout has to escalate to SIGKILL.
+	proc := startLimitedScript(t, "trap '' TERM\nwhile :; do sleep 0.05; done\n", &ResourceLimits{
+		WallTimeout: 200 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if limit := waitLimit(t, proc); limit != clauderrs.LimitWallClock {
+		t.Errorf("expected wall clock limit, got %q", limit)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("wall timeout took %s", elapsed)
+	}
+}
+
+func TestResourceLimits_WallTimeoutCleanExit(t *testing.T) {
+	// A CLI that exits cleanly on SIGTERM is still reported as timed out.
+	proc := startLimitedScript(t, "trap 'exit 0' TERM\nwhile :; do sleep 0.05; done\n", &ResourceLimits{
+		WallTimeout: 100 * time.Millisecond,
+	})
+
+	if limit := waitLimit(t, proc); limit != clauderrs.LimitWallClock {
+		t.Errorf("expected wall clock limit, got %q", limit)
+	}
+}
+
+func TestResourceLimits_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits ResourceLimits
+	}{
+		{"nice too low", ResourceLimits{Nice: -21}},
+		{"nice too high", ResourceLimits{Nice: 20}},
+		{"unknown IO class", ResourceLimits{IOClass: 4}},
+		{"IO level out of range", ResourceLimits{IOClass: IOClassBestEffort, IOLevel: 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateResourceLimits(&tt.limits); !errors.Is(err, ErrResourceLimits) {
+				t.Errorf("expected ErrResourceLimits, got %v", err)
+			}
+		})
+	}
+
+	if err := validateResourceLimits(&ResourceLimits{WallTimeout: time.Second}); err != nil {
+		t.Errorf("wall timeout alone should be valid everywhere, got %v", err)
+	}
+}
@@ -7,11 +7,25 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
 )
 
 const errWrapFormat = "%w: %w"
 
+// DefaultShutdownGracePeriod is how long Close waits for the process to exit
+// after closing stdin, and again after SIGTERM, before escalating.
+const DefaultShutdownGracePeriod = 5 * time.Second
+
+// stderrDrainTimeout bounds how long an exit error waits for the remaining
+// stderr output, which tool subprocesses may keep open after the CLI exits.
+const stderrDrainTimeout = time.Second
+
 // Process represents a Claude Code subprocess.
 type Process struct {
 	cmd       *exec.Cmd
@@ -19,7 +33,23 @@ type Process struct {
 	done      chan struct{}
 	err       error
 	errOnce   sync.Once
-	mu        sync.Mutex
+
+	gracePeriod time.Duration
+	// wrapperGroups collects the process groups of the wrapper's
+	// descendants for shutdown signals; nil without a command wrapper.
+	wrapperGroups map[int]bool
+	// signalMu serializes shutdown signals, which update wrapperGroups.
+	signalMu  sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+
+	limits   *ResourceLimits
+	timedOut atomic.Bool
+
+	executable Executable
+
+	stderrTail *tailBuffer
+	stderrDone chan struct{}
 }
 
 // ProcessConfig configures process spawning.
@@ -30,11 +60,38 @@ type ProcessConfig struct {
 	Cwd           string
 	StderrHandler func(string)
 	MaxBufferSize int
+	// BaseEnv replaces the inherited environment that Env is added to when
+	// non-nil; an empty slice starts the process with only Env.
+	BaseEnv []string
 	// User specifies the username to run the subprocess as.
-	// When set, the subprocess will run with the credentials of the specified user.
+	// When set, the subprocess will run with the credentials of the specified user,
+	// its supplementary groups, and HOME, USER and LOGNAME set for that user.
 	// This is Unix-specific and requires appropriate permissions (typically root).
 	// When empty, the subprocess runs as the current user.
 	User string
+	// Group overrides the primary group, by name or numeric GID.
+	Group string
+	// SupplementaryGroups replaces the supplementary groups, by name or
+	// numeric GID; a non-nil empty slice clears them. Nil uses the groups
+	// of User, or keeps the current ones when User is empty.
+	SupplementaryGroups []string
+	// ShutdownGracePeriod is how long Close waits for the process to exit
+	// after closing stdin, and again after SIGTERM, before sending SIGKILL.
+	// Zero uses DefaultShutdownGracePeriod.
+	ShutdownGracePeriod time.Duration
+	// StderrTailSize is how many bytes of the most recent stderr output are
+	// kept for ProcessError reports. Zero uses DefaultStderrTailSize.
+	StderrTailSize int
+	// CommandWrapper is an argv prefix that the CLI runs under, such as
+	// bwrap, nsjail, firejail, systemd-run or unshare. It may contain the
+	// {executable} and {cwd} placeholders and {env:...} templates (see
+	// WrapperEnvPlaceholder). The version check runs through the wrapper
+	// too, and shutdown signals also reach CLI processes the wrapper moved
+	// to another process group (Linux only).
+	CommandWrapper []string
+	// ResourceLimits constrains the process and its tool subprocesses; nil
+	// applies none.
+	ResourceLimits *ResourceLimits
 }
 
 // NewProcess spawns a new Claude Code process.
@@ -46,78 +103,145 @@ func NewProcess(
 		return nil, ErrConfigRequired
 	}
 
-	executable, err := resolveExecutable(config.Executable)
+	found, err := FindExecutable(config.Executable, config.Cwd)
 	if err != nil {
 		return nil, err
 	}
+	executable := found.Path
 
-	// Verify CLI version compatibility before spawning process.
-	// Can be skipped by setting CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK=true
-	if err := checkCLIVersion(executable); err != nil {
+	if err := validateResourceLimits(config.ResourceLimits); err != nil {
 		return nil, err
 	}
 
-	cmd := createCommand(ctx, executable, config)
+	// Resolve the user and groups to switch to, if any (Unix-only), and
+	// make sure that identity can use the working directory.
+	id, err := resolveIdentity(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWorkingDirectory(config.Cwd, id); err != nil {
+		return nil, err
+	}
 
-	// Configure user credentials if specified (Unix-only)
-	if err := configureUserCredential(cmd, config.User); err != nil {
-		return nil, fmt.Errorf(errWrapFormat, ErrUserSwitchFailed, err)
+	// Verify CLI version compatibility before spawning process, running it
+	// as the process itself will run. Can be skipped by setting
+	// CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK=true
+	if err := checkCLIVersion(executable, config); err != nil {
+		return nil, err
+	}
+
+	var userEnv []string
+	if id != nil {
+		userEnv = id.env
+	}
+	cmd := createCommand(ctx, executable, config, userEnv)
+	configureUserCredential(cmd, id)
+	configureProcessGroup(cmd)
+
+	gate, err := newLimitGate(cmd, config.ResourceLimits)
+	if err != nil {
+		return nil, err
 	}
 
 	pipes, err := createPipes(cmd)
 	if err != nil {
+		gate.abort()
+
 		return nil, err
 	}
 
 	transport := NewStdioTransport(pipes.stdin, pipes.stdout, pipes.stderr, config.MaxBufferSize)
 
 	if err := cmd.Start(); err != nil {
+		pipes.closeAll()
+		gate.abort()
+
+		if permErr := userSwitchError(id, err); permErr != nil {
+			return nil, permErr
+		}
+
 		return nil, fmt.Errorf(errWrapFormat, ErrProcessStart, err)
 	}
+	pipes.closeChildEnds()
+	gate.started()
 
-	proc := &Process{
-		cmd:       cmd,
-		transport: transport,
-		done:      make(chan struct{}),
+	if err := gate.release(cmd.Process.Pid, config.ResourceLimits); err != nil {
+		_ = killProcessGroup(cmd, nil)
+		_ = cmd.Wait()
+		_ = transport.Close()
+
+		return nil, err
 	}
 
-	if config.StderrHandler != nil {
-		go proc.handleStderr(pipes.stderr, config.StderrHandler)
+	gracePeriod := config.ShutdownGracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultShutdownGracePeriod
 	}
 
-	go proc.waitInternal()
+	tailSize := config.StderrTailSize
+	if tailSize <= 0 {
+		tailSize = DefaultStderrTailSize
+	}
 
-	return proc, nil
-}
+	proc := &Process{
+		cmd:         cmd,
+		transport:   transport,
+		done:        make(chan struct{}),
+		gracePeriod: gracePeriod,
+		stderrTail:  newTailBuffer(tailSize),
+		stderrDone:  make(chan struct{}),
+		limits:      config.ResourceLimits,
+		executable:  found,
+	}
 
-// resolveExecutable determines the executable path to use.
-func resolveExecutable(executable string) (string, error) {
-	if executable != "" {
-		return executable, nil
+	if len(config.CommandWrapper) > 0 {
+		proc.wrapperGroups = make(map[int]bool)
 	}
 
-	path, err := exec.LookPath("claude")
-	if err != nil {
-		return "", fmt.Errorf(errWrapFormat, ErrClaudeExecutableNotFound, err)
+	// stderr is always drained, both to keep the CLI from blocking on a full
+	// pipe and to retain its tail for error reports.
+	go proc.handleStderr(pipes.stderr, config.StderrHandler)
+
+	go proc.waitInternal()
+
+	if limits := config.ResourceLimits; limits != nil && limits.WallTimeout > 0 {
+		go proc.enforceWallTimeout(limits.WallTimeout)
 	}
 
-	return path, nil
+	return proc, nil
 }
 
-// createCommand creates and configures the exec.Cmd.
+// createCommand creates and configures the exec.Cmd. userEnv holds the
+// identity variables of a switched user; config.Env overrides them.
 func createCommand(
 	ctx context.Context,
 	executable string,
 	config *ProcessConfig,
+	userEnv []string,
 ) *exec.Cmd {
-	cmd := exec.CommandContext(ctx, executable, config.Args...)
+	// Later entries win, so config.Env overrides userEnv, which overrides
+	// the base.
+	var env []string
+	switch {
+	case config.BaseEnv != nil:
+		// A non-nil cmd.Env, even an empty one, replaces the inherited
+		// environment.
+		env = slices.Concat(config.BaseEnv, userEnv, config.Env)
+	case len(userEnv) > 0 || len(config.Env) > 0:
+		env = slices.Concat(os.Environ(), userEnv, config.Env)
+	}
 
-	if config.Cwd != "" {
-		cmd.Dir = config.Cwd
+	// Environment templates see the environment the process gets.
+	templateEnv := env
+	if templateEnv == nil {
+		templateEnv = os.Environ()
 	}
+	name, args := wrapCommand(config.CommandWrapper, executable, config.Args, config.Cwd, templateEnv)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
 
-	if len(config.Env) > 0 {
-		cmd.Env = append(os.Environ(), config.Env...)
+	if config.Cwd != "" {
+		cmd.Dir = config.Cwd
 	}
 
 	return cmd
@@ -128,40 +252,95 @@ type pipeSet struct {
 	stdin  io.WriteCloser
 	stdout io.ReadCloser
 	stderr io.ReadCloser
+	// childEnds are the write ends handed to the child, closed in the parent
+	// once the process has started.
+	childEnds []io.Closer
 }
 
 // createPipes creates stdin, stdout, and stderr pipes for the command.
+//
+// stdout and stderr use os.Pipe rather than cmd.StdoutPipe: exec closes its
+// own pipes as soon as the process exits, which can drop output that has not
+// been read yet. With os.Pipe the read ends stay open until the transport is
+// closed, so everything the CLI wrote before exiting is read before io.EOF.
 func createPipes(cmd *exec.Cmd) (pipeSet, error) {
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return pipeSet{}, fmt.Errorf(errWrapFormat, ErrStdinPipe, err)
 	}
 
-	stdout, err := cmd.StdoutPipe()
+	stdoutR, stdoutW, err := os.Pipe()
 	if err != nil {
+		_ = stdin.Close()
+
 		return pipeSet{}, fmt.Errorf(errWrapFormat, ErrStdoutPipe, err)
 	}
+	cmd.Stdout = stdoutW
 
-	stderr, err := cmd.StderrPipe()
+	stderrR, stderrW, err := os.Pipe()
 	if err != nil {
+		_ = stdin.Close()
+		_ = stdoutR.Close()
+		_ = stdoutW.Close()
+
 		return pipeSet{}, fmt.Errorf(errWrapFormat, ErrStderrPipe, err)
 	}
+	cmd.Stderr = stderrW
 
 	return pipeSet{
-		stdin:  stdin,
-		stdout: stdout,
-		stderr: stderr,
+		stdin:     stdin,
+		stdout:    stdoutR,
+		stderr:    stderrR,
+		childEnds: []io.Closer{stdoutW, stderrW},
 	}, nil
 }
 
-// handleStderr reads from stderr and calls the handler for each line.
-func (*Process) handleStderr(stderr io.Reader, handler func(string)) {
-	scanner := bufio.NewScanner(stderr)
-	for scanner.Scan() {
-		handler(scanner.Text())
+// closeChildEnds closes the parent's copies of the child's pipe ends.
+func (p pipeSet) closeChildEnds() {
+	for _, c := range p.childEnds {
+		_ = c.Close()
 	}
 }
 
+// closeAll closes every pipe end, used when the process fails to start.
+func (p pipeSet) closeAll() {
+	_ = p.stdin.Close()
+	_ = p.stdout.Close()
+	_ = p.stderr.Close()
+	p.closeChildEnds()
+}
+
+// handleStderr reads stderr until EOF, keeping its tail and calling the
+// handler, if any, for each line.
+func (p *Process) handleStderr(stderr io.Reader, handler func(string)) {
+	defer close(p.stderrDone)
+
+	reader := bufio.NewReader(stderr)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			_, _ = p.stderrTail.Write([]byte(line))
+			if handler != nil {
+				handler(strings.TrimRight(line, "\r\n"))
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Executable returns the CLI executable the process runs and how it was
+// found.
+func (p *Process) Executable() Executable {
+	return p.executable
+}
+
+// StderrTail returns the most recent stderr output of the process.
+func (p *Process) StderrTail() string {
+	return p.stderrTail.String()
+}
+
 // waitInternal waits for the process to complete.
 func (p *Process) waitInternal() {
 	err := p.cmd.Wait()
@@ -176,35 +355,168 @@ func (p *Process) Transport() Transport {
 	return p.transport
 }
 
-// Wait waits for the process to complete.
+// Wait waits for the process to complete. An abnormal exit is reported as a
+// *clauderrs.ProcessError.
 func (p *Process) Wait(ctx context.Context) error {
 	select {
 	case <-p.done:
-		return p.err
+		return p.exitError(false)
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 }
 
-// Close kills the process and cleans up resources.
+// CloseInput closes the process's stdin so the CLI sees the end of input and
+// can finish once it has processed the messages already written.
+func (p *Process) CloseInput() error {
+	if err := p.transport.CloseWrite(); err != nil {
+		return fmt.Errorf(errWrapFormat, ErrTransportClose, err)
+	}
+
+	return nil
+}
+
+// Close shuts the process down and cleans up resources.
+//
+// Shutdown is graceful: stdin is closed so the CLI can flush its final output
+// and persist the session, and the process gets the grace period to exit on
+// its own. If it does not, its process group receives SIGTERM and, after
+// another grace period, SIGKILL. Once the CLI has exited, any children it
+// left behind in its process group are killed.
+//
+// Close returns a *clauderrs.ProcessError if the process exited abnormally or
+// had to be terminated. Calling Close again returns the same result.
 func (p *Process) Close() error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.closeOnce.Do(func() {
+		p.closeErr = p.shutdown()
+	})
+
+	return p.closeErr
+}
+
+func (p *Process) shutdown() error {
+	// Signal end of input; the CLI exits once it has finished the current turn.
+	_ = p.transport.CloseWrite()
+
+	forced := false
+	if !p.waitExit(p.gracePeriod) {
+		forced = true
+		p.terminate()
+
+		if !p.waitExit(p.gracePeriod) {
+			if err := p.kill(); err != nil {
+				return fmt.Errorf(errWrapFormat, ErrProcessKill, err)
+			}
+			<-p.done
+		}
+	}
+
+	// Reap tool subprocesses that outlived the CLI.
+	_ = p.kill()
 
-	// Close transport first
 	if err := p.transport.Close(); err != nil {
 		return fmt.Errorf(errWrapFormat, ErrTransportClose, err)
 	}
 
-	// Kill the process if it's still running
-	if p.cmd.Process != nil {
-		if err := p.cmd.Process.Kill(); err != nil {
-			return fmt.Errorf(errWrapFormat, ErrProcessKill, err)
-		}
+	return p.exitError(forced)
+}
+
+// terminate sends SIGTERM to the process group.
+func (p *Process) terminate() {
+	p.signalMu.Lock()
+	defer p.signalMu.Unlock()
+
+	_ = terminateProcessGroup(p.cmd, p.wrapperGroups)
+}
+
+// kill sends SIGKILL to the process group.
+func (p *Process) kill() error {
+	p.signalMu.Lock()
+	defer p.signalMu.Unlock()
+
+	return killProcessGroup(p.cmd, p.wrapperGroups)
+}
+
+// waitExit waits up to d for the process to exit and reports whether it did.
+func (p *Process) waitExit(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-p.done:
+		return true
+	case <-timer.C:
+		return false
 	}
+}
 
-	// Wait for completion
-	<-p.done
+// exitError converts the process exit status into a *clauderrs.ProcessError
+// carrying the exit code, the terminating signal and the stderr tail. It
+// returns nil for a clean exit that was not forced. Callers must only use it
+// after the process has exited.
+//
+// A process stopped by one of its resource limits is reported with
+// ErrCodeProcessLimitExceeded, one killed by a signal the SDK did not send
+// with ErrCodeProcessCrashed, and any other failure with ErrCodeProcessExited.
+func (p *Process) exitError(forced bool) error {
+	timedOut := p.timedOut.Load()
+	if p.err == nil && !forced && !timedOut {
+		return nil
+	}
 
-	return nil
+	exitCode := -1
+	signal := ""
+	if state := p.cmd.ProcessState; state != nil {
+		exitCode = state.ExitCode()
+		signal = exitSignal(state)
+	}
+
+	// Give the stderr reader a moment to pick up the last lines.
+	timer := time.NewTimer(stderrDrainTimeout)
+	select {
+	case <-p.stderrDone:
+	case <-timer.C:
+	}
+	timer.Stop()
+	stderr := p.StderrTail()
+
+	var limit clauderrs.ProcessLimit
+	if p.err != nil || timedOut {
+		limit = p.exceededLimit(signal, stderr)
+	}
+
+	code := clauderrs.ErrCodeProcessExited
+	var message string
+	switch {
+	case limit != "":
+		code = clauderrs.ErrCodeProcessLimitExceeded
+		message = p.limits.limitMessage(limit)
+	case forced:
+		message = fmt.Sprintf(
+			"Claude Code process did not exit within %s of closing stdin and was terminated",
+			p.gracePeriod,
+		)
+	case signal != "":
+		code = clauderrs.ErrCodeProcessCrashed
+		message = "Claude Code process was terminated by signal: " + signal
+	default:
+		message = fmt.Sprintf("Claude Code process exited with status %d", exitCode)
+	}
+
+	procErr := clauderrs.NewProcessError(
+		code,
+		message,
+		p.err,
+		exitCode,
+		stderr,
+	).WithCommand(p.cmd.String())
+	if signal != "" {
+		procErr = procErr.WithSignal(signal)
+	}
+	if limit != "" {
+		procErr = procErr.WithLimit(limit)
+	}
+	_ = procErr.WithMetadata("executable_source", string(p.executable.Source))
+
+	return procErr
 }
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// descendants returns the PIDs of all descendants of pid, found by scanning
+// /proc.
+func descendants(pid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name may contain spaces and parentheses; the fields
+		// after it start at the last ')': state, then the parent PID.
+		end := bytes.LastIndexByte(stat, ')')
+		if end < 0 {
+			continue
+		}
+		fields := bytes.Fields(stat[end+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		parent, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			continue
+		}
+		children[parent] = append(children[parent], child)
+	}
+
+	var result []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		result = append(result, next)
+		queue = append(queue, children[next]...)
+	}
+
+	return result
+}
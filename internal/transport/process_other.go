@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package transport
+
+// descendants is only implemented on Linux; elsewhere shutdown signals reach
+// the command's own process group only.
+func descendants(int) []int {
+	return nil
+}
@@ -0,0 +1,259 @@
+//go:build !windows
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// startScript starts a shell script as the CLI process.
+func startScript(t *testing.T, script string, grace time.Duration) *Process {
+	t.Helper()
+	t.Setenv(SkipVersionCheckEnvVar, "true")
+
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	proc, err := NewProcess(context.Background(), &ProcessConfig{
+		Executable:          path,
+		ShutdownGracePeriod: grace,
+	})
+	if err != nil {
+		t.Fatalf("NewProcess: %v", err)
+	}
+
+	return proc
+}
+
+func TestProcessClose_ExitsOnEndOfInput(t *testing.T) {
+	proc := startScript(t, "cat >/dev/null\n", time.Minute)
+
+	start := time.Now()
+	if err := proc.Close(); err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("close waited %s; expected the process to exit on EOF", elapsed)
+	}
+}
+
+func TestProcessClose_ReportsExitStatus(t *testing.T) {
+	proc := startScript(t, "cat >/dev/null\nexit 3\n", time.Minute)
+
+	err := proc.Close()
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) {
+		t.Fatalf("expected ProcessError, got %v", err)
+	}
+	if procErr.ExitCode() != 3 || procErr.Code() != clauderrs.ErrCodeProcessExited {
+		t.Fatalf("unexpected process error: %v (exit %d)", procErr, procErr.ExitCode())
+	}
+
+	if again := proc.Close(); again != err {
+		t.Fatalf("expected repeated Close to return the same error, got %v", again)
+	}
+}
+
+func TestProcessClose_EscalatesAndKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	proc := startScript(t, `trap '' TERM
+sleep 30 &
+echo $! > `+pidFile+`
+while :; do sleep 0.05; done
+`, 100*time.Millisecond)
+
+	var childPID int
+	deadline := time.Now().Add(5 * time.Second)
+	for childPID == 0 && time.Now().Before(deadline) {
+		data, _ := os.ReadFile(pidFile)
+		childPID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if childPID == 0 {
+		t.Fatal("child process did not start")
+	}
+
+	err := proc.Close()
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) || !strings.Contains(procErr.Error(), "terminated") {
+		t.Fatalf("expected forced-termination ProcessError, got %v", err)
+	}
+
+	// The child is reparented and reaped by init once killed; allow a moment.
+	deadline = time.Now().Add(5 * time.Second)
+	for syscall.Kill(childPID, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("child process %d survived Close", childPID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProcessWait_ReportsCrashWithSignalAndStderrTail(t *testing.T) {
+	proc := startScript(t, `echo "starting" >&2
+echo "fatal: out of memory" >&2
+kill -9 $$
+`, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := proc.Wait(ctx)
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) {
+		t.Fatalf("expected ProcessError, got %v", err)
+	}
+	if procErr.Code() != clauderrs.ErrCodeProcessCrashed || procErr.Signal() != "killed" {
+		t.Errorf("expected crash by SIGKILL, got code %s signal %q", procErr.Code(), procErr.Signal())
+	}
+	if !strings.HasSuffix(procErr.Stderr(), "fatal: out of memory\n") {
+		t.Errorf("expected stderr tail, got %q", procErr.Stderr())
+	}
+
+	_ = proc.Close()
+}
+
+// writeExecutable writes a shell script to dir and returns its path.
+func writeExecutable(t *testing.T, dir, name, script string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+
+	return path
+}
+
+func TestProcess_CommandWrapper(t *testing.T) {
+	t.Setenv(SkipVersionCheckEnvVar, "false")
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "wrapper.log")
+
+	// The fake wrapper logs its arguments and environment and runs
+	// everything after "--".
+	wrapper := writeExecutable(t, dir, "wrapper", `echo "$* A=$A" >> `+logFile+`
+while [ "$1" != "--" ]; do shift; done
+shift
+exec "$@"
+`)
+	cli := writeExecutable(t, dir, "claude", `if [ "$1" = "--version" ]; then
+	echo "claude version 2.1.0"
+	exit 0
+fi
+cat >/dev/null
+`)
+
+	proc, err := NewProcess(context.Background(), &ProcessConfig{
+		Executable:     cli,
+		Args:           []string{"--print"},
+		BaseEnv:        []string{"PATH=" + os.Getenv("PATH")},
+		Env:            []string{"A=1", "B=2"},
+		Cwd:            dir,
+		CommandWrapper: []string{wrapper, "--chdir={cwd}", "{env:--setenv NAME}", "--", "{executable}"},
+	})
+	if err != nil {
+		t.Fatalf("NewProcess: %v", err)
+	}
+	if err := proc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("read wrapper log: %v", err)
+	}
+	prefix := "--chdir=" + dir + " --setenv PATH --setenv A --setenv B -- " + cli
+	want := prefix + " --version A=1\n" + prefix + " --print A=1\n"
+	if string(data) != want {
+		t.Errorf("unexpected wrapper invocations:\n got: %q\nwant: %q", data, want)
+	}
+}
+
+func TestProcess_CommandWrapperVersionCheck(t *testing.T) {
+	t.Setenv(SkipVersionCheckEnvVar, "false")
+	dir := t.TempDir()
+
+	// The wrapper runs a different CLI than the one on the host, as a
+	// sandbox image might; the check must see the sandboxed version.
+	wrapper := writeExecutable(t, dir, "wrapper", `echo "claude version 1.0.0"
+`)
+	cli := writeExecutable(t, dir, "claude", `echo "claude version 2.1.0"
+`)
+
+	_, err := NewProcess(context.Background(), &ProcessConfig{
+		Executable:     cli,
+		CommandWrapper: []string{wrapper},
+	})
+
+	var versionErr *clauderrs.ClientError
+	if !errors.As(err, &versionErr) || versionErr.Code() != clauderrs.ErrCodeVersionMismatch {
+		t.Fatalf("expected the wrapped CLI's version to be rejected, got %v", err)
+	}
+}
+
+func TestProcessClose_ReachesCLIInWrapperSession(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("descendant signalling is Linux-only")
+	}
+	t.Setenv(SkipVersionCheckEnvVar, "true")
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "cli.pid")
+
+	// Like bwrap --new-session, the wrapper starts the CLI in its own
+	// session, outside the process group the SDK signals.
+	wrapper := writeExecutable(t, dir, "wrapper", `setsid "$@" &
+wait
+`)
+	cli := writeExecutable(t, dir, "claude", `trap '' TERM
+echo $$ > `+pidFile+`
+while :; do sleep 0.05; done
+`)
+
+	proc, err := NewProcess(context.Background(), &ProcessConfig{
+		Executable:          cli,
+		CommandWrapper:      []string{wrapper},
+		ShutdownGracePeriod: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewProcess: %v", err)
+	}
+
+	var cliPID int
+	deadline := time.Now().Add(5 * time.Second)
+	for cliPID == 0 && time.Now().Before(deadline) {
+		data, _ := os.ReadFile(pidFile)
+		cliPID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cliPID == 0 {
+		t.Fatal("CLI did not start")
+	}
+
+	_ = proc.Close()
+
+	deadline = time.Now().Add(5 * time.Second)
+	for syscall.Kill(cliPID, 0) == nil {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(cliPID, syscall.SIGKILL)
+			t.Fatalf("CLI process %d survived Close", cliPID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
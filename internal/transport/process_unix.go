@@ -0,0 +1,74 @@
+//go:build !windows
+
+package transport
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// configureProcessGroup starts the command in its own process group so that
+// shutdown signals also reach the children it spawns (for example Bash tool
+// invocations).
+func configureProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// terminateProcessGroup sends SIGTERM to the command's process group. If
+// groups is non-nil, the process groups of the command's descendants are
+// added to it and everything in it is signalled too.
+func terminateProcessGroup(cmd *exec.Cmd, groups map[int]bool) error {
+	return signalProcessGroup(cmd, syscall.SIGTERM, groups)
+}
+
+// killProcessGroup sends SIGKILL to the command's process group and, like
+// terminateProcessGroup, to groups.
+func killProcessGroup(cmd *exec.Cmd, groups map[int]bool) error {
+	return signalProcessGroup(cmd, syscall.SIGKILL, groups)
+}
+
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal, groups map[int]bool) error {
+	if cmd.Process == nil {
+		return nil
+	}
+
+	// A command wrapper may start the CLI in a new session or process group
+	// (bwrap --new-session, setsid), out of reach of the group signal.
+	// Collect its descendants' groups first: once the wrapper dies they are
+	// reparented and can no longer be found, so groups remembers them for
+	// later signals.
+	if groups != nil {
+		for _, pid := range descendants(cmd.Process.Pid) {
+			if pgid, err := syscall.Getpgid(pid); err == nil && pgid != cmd.Process.Pid {
+				groups[pgid] = true
+			}
+		}
+		for pgid := range groups {
+			_ = syscall.Kill(-pgid, sig)
+		}
+	}
+
+	err := syscall.Kill(-cmd.Process.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		// The group is already gone.
+		return nil
+	}
+
+	return err
+}
+
+// exitSignal describes the signal that terminated the process, or returns ""
+// if it exited normally.
+func exitSignal(state *os.ProcessState) string {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+
+	return status.Signal().String()
+}
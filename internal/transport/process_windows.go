@@ -0,0 +1,39 @@
+//go:build windows
+
+package transport
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// configureProcessGroup is a no-op on Windows, which has no POSIX process
+// groups.
+func configureProcessGroup(*exec.Cmd) {}
+
+// terminateProcessGroup kills the process. Windows has no SIGTERM, so the
+// graceful step is skipped.
+func terminateProcessGroup(cmd *exec.Cmd, groups map[int]bool) error {
+	return killProcessGroup(cmd, groups)
+}
+
+// killProcessGroup kills the process. Child processes are not tracked on
+// Windows.
+func killProcessGroup(cmd *exec.Cmd, _ map[int]bool) error {
+	if cmd.Process == nil {
+		return nil
+	}
+
+	err := cmd.Process.Kill()
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+
+	return err
+}
+
+// exitSignal returns "": processes on Windows do not exit by signal.
+func exitSignal(*os.ProcessState) string {
+	return ""
+}
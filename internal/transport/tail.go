@@ -0,0 +1,47 @@
+package transport
+
+import "sync"
+
+// DefaultStderrTailSize is how many bytes of stderr a process keeps for error
+// reports.
+const DefaultStderrTailSize = 32 * 1024
+
+// tailBuffer keeps the last size bytes written to it.
+type tailBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+// Write appends p, discarding the oldest bytes beyond the size limit. It never
+// fails.
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if n >= b.size {
+		b.buf = append(b.buf[:0], p[n-b.size:]...)
+
+		return n, nil
+	}
+
+	if overflow := len(b.buf) + n - b.size; overflow > 0 {
+		b.buf = append(b.buf[:0], b.buf[overflow:]...)
+	}
+	b.buf = append(b.buf, p...)
+
+	return n, nil
+}
+
+// String returns the retained bytes.
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return string(b.buf)
+}
@@ -0,0 +1,23 @@
+package transport
+
+import "testing"
+
+func TestTailBuffer_KeepsMostRecentBytes(t *testing.T) {
+	b := newTailBuffer(8)
+
+	_, _ = b.Write([]byte("abc"))
+	_, _ = b.Write([]byte("defg"))
+	if got := b.String(); got != "abcdefg" {
+		t.Fatalf("expected abcdefg, got %q", got)
+	}
+
+	_, _ = b.Write([]byte("hij"))
+	if got := b.String(); got != "cdefghij" {
+		t.Fatalf("expected cdefghij, got %q", got)
+	}
+
+	_, _ = b.Write([]byte("0123456789"))
+	if got := b.String(); got != "23456789" {
+		t.Fatalf("expected 23456789, got %q", got)
+	}
+}
@@ -2,10 +2,11 @@ package transport
 
 import (
 	"bufio"
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
 )
@@ -18,17 +19,53 @@ type Transport interface {
 	// Write writes a message to the transport
 	Write(ctx context.Context, data []byte) error
 
+	// CloseWrite closes the write side of the transport, signalling the end
+	// of input while leaving the read side open
+	CloseWrite() error
+
+	// Stats returns write and backpressure counters
+	Stats() WriteStats
+
 	// Close closes the transport
 	Close() error
 }
 
+// readerBufferSize is the chunk size used to scan stdout for frames.
+const readerBufferSize = 64 * 1024
+
+// frameQueueSize is how many frames the reader goroutine may read ahead.
+const frameQueueSize = 8
+
+// frame is a newline-delimited message, or the error that ended a read.
+type frame struct {
+	data []byte
+	err  error
+}
+
 // StdioTransport implements Transport using stdio.
+//
+// A single reader goroutine, started by the first Read, scans stdout and
+// delivers frames over a channel, so a Read abandoned through its context
+// never leaves a stray reader consuming the stream. Likewise a single writer
+// goroutine owns stdin.
 type StdioTransport struct {
 	stdin         io.WriteCloser
 	stdout        io.ReadCloser
 	stderr        io.ReadCloser
-	reader        *bufio.Reader
 	maxBufferSize int
+
+	stdinOnce sync.Once
+	stdinErr  error
+	writer    *frameWriter
+
+	readOnce sync.Once
+	frames   chan frame
+	// readErr is the error that ended the stream, set before frames is
+	// closed.
+	readErr error
+	done    chan struct{}
+	// closeOnce guards done.
+	closeOnce sync.Once
 }
 
 // NewStdioTransport creates a new stdio transport.
@@ -37,118 +74,134 @@ func NewStdioTransport(
 	stdout, stderr io.ReadCloser,
 	maxBufferSize int,
 ) *StdioTransport {
+	done := make(chan struct{})
+
 	return &StdioTransport{
 		stdin:         stdin,
 		stdout:        stdout,
 		stderr:        stderr,
-		reader:        bufio.NewReader(stdout),
 		maxBufferSize: maxBufferSize,
+		writer:        newFrameWriter(stdin, done),
+		frames:        make(chan frame, frameQueueSize),
+		done:          done,
 	}
 }
 
-// Read reads a line-delimited JSON message from stdout.
+// Read reads a line-delimited JSON message from stdout. The returned slice
+// includes the trailing newline, if any, and is owned by the caller.
+//
+// A frame longer than the maximum buffer size is reported as a
+// *clauderrs.BufferError; the rest of that frame is discarded and the next
+// Read continues with the following frame. After stdout ends, Read keeps
+// returning io.EOF (or the read error that ended it).
 func (t *StdioTransport) Read(ctx context.Context) ([]byte, error) {
-	// Create a channel to receive the result
-	type result struct {
-		data []byte
-		err  error
-	}
-	resultChan := make(chan result, 1)
-
-	go func() {
-		// If maxBufferSize is 0, no limit is enforced
-		if t.maxBufferSize == 0 {
-			line, err := t.reader.ReadBytes('\n')
-			if err != nil {
-				if err == io.EOF {
-					resultChan <- result{nil, err}
-					return
-				}
-				resultChan <- result{
-					nil,
-					fmt.Errorf(errWrapFormat, ErrReadFailed, err),
-				}
-				return
-			}
-			resultChan <- result{line, nil}
-			return
+	t.readOnce.Do(func() {
+		go t.readLoop()
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case f, ok := <-t.frames:
+		if !ok {
+			return nil, t.readErr
 		}
 
-		// With maxBufferSize limit, read with efficient buffer growth
-		// Pre-allocate buffer with reasonable initial size (4KB)
-		var buf bytes.Buffer
-		buf.Grow(4096)
-
-		for {
-			b, err := t.reader.ReadByte()
-			if err != nil {
-				if err == io.EOF {
-					resultChan <- result{nil, err}
-					return
-				}
-				resultChan <- result{
-					nil,
-					fmt.Errorf(errWrapFormat, ErrReadFailed, err),
-				}
+		return f.data, f.err
+	}
+}
+
+// readLoop scans stdout for frames until it ends or the transport is closed.
+func (t *StdioTransport) readLoop() {
+	reader := bufio.NewReaderSize(t.stdout, readerBufferSize)
+	var (
+		buf []byte
+		// discarding is set while skipping the rest of an oversized frame.
+		discarding bool
+		size       int
+	)
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		size += len(chunk)
+
+		switch {
+		case discarding:
+		case t.maxBufferSize > 0 && size > t.maxBufferSize:
+			discarding = true
+			buf = nil
+			if !t.deliver(frame{err: clauderrs.NewBufferSizeExceededError(t.maxBufferSize, size, "json_accumulation")}) {
 				return
 			}
+		default:
+			buf = append(buf, chunk...)
+		}
 
-			buf.WriteByte(b)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
 
-			// Check if we've exceeded the buffer size limit
-			if buf.Len() > t.maxBufferSize {
-				resultChan <- result{
-					nil,
-					clauderrs.NewBufferSizeExceededError(t.maxBufferSize, buf.Len(), "json_accumulation"),
-				}
+		if len(buf) > 0 && !discarding {
+			if !t.deliver(frame{data: buf}) {
 				return
 			}
+		}
+		buf, discarding, size = nil, false, 0
 
-			// Check if we've reached the end of the line
-			if b == '\n' {
-				resultChan <- result{buf.Bytes(), nil}
-				return
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				err = fmt.Errorf(errWrapFormat, ErrReadFailed, err)
 			}
+			t.readErr = err
+			close(t.frames)
+
+			return
 		}
-	}()
+	}
+}
 
+// deliver hands a frame to Read, reporting false if the transport closed.
+func (t *StdioTransport) deliver(f frame) bool {
 	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case res := <-resultChan:
-		return res.data, res.err
+	case t.frames <- f:
+		return true
+	case <-t.done:
+		return false
 	}
 }
 
 // Write writes a line-delimited JSON message to stdin.
+//
+// Writes from concurrent callers are serialized through a single writer
+// goroutine in the order they were queued. A write abandoned through ctx
+// before it starts is dropped; once started it is always completed, so the
+// stream never holds a partial frame.
 func (t *StdioTransport) Write(ctx context.Context, data []byte) error {
-	// Create a channel to signal completion
-	errChan := make(chan error, 1)
-
-	go func() {
-		// Add newline delimiter.
-		message := append([]byte(nil), data...)
-		message = append(message, '\n')
-		_, err := t.stdin.Write(message)
-		if err != nil {
-			errChan <- fmt.Errorf(errWrapFormat, ErrWriteFailed, err)
+	return t.writer.Write(ctx, data)
+}
 
-			return
-		}
-		errChan <- nil
-	}()
+// Stats returns stdin write and backpressure counters.
+func (t *StdioTransport) Stats() WriteStats {
+	return t.writer.Stats()
+}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-errChan:
-		return err
-	}
+// CloseWrite closes stdin. It is safe to call more than once and before
+// Close.
+func (t *StdioTransport) CloseWrite() error {
+	t.stdinOnce.Do(func() {
+		t.stdinErr = t.stdin.Close()
+	})
+
+	return t.stdinErr
 }
 
-// Close closes all streams.
+// Close closes all streams and stops the reader goroutine.
 func (t *StdioTransport) Close() error {
-	err := t.stdin.Close()
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+
+	err := t.CloseWrite()
 	if err != nil {
 		return err
 	}
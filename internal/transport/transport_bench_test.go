@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// benchmarkRead reads frames of frameSize bytes (newline included) through a
+// transport with the default 16 MB limit used for large tool outputs.
+func benchmarkRead(b *testing.B, frameSize int) {
+	const frames = 8
+	line := append(bytes.Repeat([]byte("a"), frameSize-1), '\n')
+	input := bytes.Repeat(line, frames)
+	ctx := context.Background()
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		stdout := io.NopCloser(bytes.NewReader(input))
+		stderr := io.NopCloser(bytes.NewReader(nil))
+		transport := NewStdioTransport(&mockWriteCloser{io.Discard}, stdout, stderr, 16*1024*1024)
+
+		for range frames {
+			if _, err := transport.Read(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+		_ = transport.Close()
+	}
+}
+
+func BenchmarkStdioTransportRead_1KB(b *testing.B) { benchmarkRead(b, 1024) }
+
+func BenchmarkStdioTransportRead_1MB(b *testing.B) { benchmarkRead(b, 1024*1024) }
+
+func BenchmarkStdioTransportRead_4MB(b *testing.B) { benchmarkRead(b, 4*1024*1024) }
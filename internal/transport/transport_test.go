@@ -148,3 +148,98 @@ func TestStdioTransport_MaxBufferSize_OneByteOver(t *testing.T) {
 		t.Errorf("Expected ErrBufferSizeExceeded, got: %v", err)
 	}
 }
+
+// countingWriteCloser counts Close calls.
+type countingWriteCloser struct {
+	io.Writer
+	closes int
+}
+
+func (c *countingWriteCloser) Close() error {
+	c.closes++
+
+	return nil
+}
+
+func TestStdioTransport_CloseWriteOnce(t *testing.T) {
+	stdin := &countingWriteCloser{Writer: io.Discard}
+	stdout := &mockReadCloser{strings.NewReader("")}
+	stderr := &mockReadCloser{strings.NewReader("")}
+
+	transport := NewStdioTransport(stdin, stdout, stderr, 0)
+
+	if err := transport.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite: %v", err)
+	}
+	if err := transport.CloseWrite(); err != nil {
+		t.Fatalf("second CloseWrite: %v", err)
+	}
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if stdin.closes != 1 {
+		t.Fatalf("expected stdin closed once, got %d", stdin.closes)
+	}
+}
+
+func TestStdioTransport_ReadCancelDoesNotLoseFrames(t *testing.T) {
+	pr, pw := io.Pipe()
+	stdin := &mockWriteCloser{io.Discard}
+	stderr := &mockReadCloser{strings.NewReader("")}
+
+	transport := NewStdioTransport(stdin, pr, stderr, 100)
+	defer transport.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := transport.Read(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+
+	go func() {
+		_, _ = pw.Write([]byte("{\"a\":1}\n{\"b\":2}\n"))
+		_ = pw.Close()
+	}()
+
+	for _, want := range []string{"{\"a\":1}\n", "{\"b\":2}\n"} {
+		data, err := transport.Read(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(data) != want {
+			t.Errorf("Expected %q, got %q", want, data)
+		}
+	}
+
+	for range 2 {
+		if _, err := transport.Read(context.Background()); err != io.EOF {
+			t.Fatalf("Expected io.EOF after the stream ended, got: %v", err)
+		}
+	}
+}
+
+func TestStdioTransport_SkipsOversizedFrame(t *testing.T) {
+	input := strings.Repeat("x", 200000) + "\n" + "{\"ok\":true}\n" + "{\"last\":true}"
+	stdout := &mockReadCloser{strings.NewReader(input)}
+	stdin := &mockWriteCloser{io.Discard}
+	stderr := &mockReadCloser{strings.NewReader("")}
+
+	transport := NewStdioTransport(stdin, stdout, stderr, 1024)
+	ctx := context.Background()
+
+	if _, err := transport.Read(ctx); !errors.Is(err, clauderrs.ErrBufferSizeExceeded) {
+		t.Fatalf("Expected ErrBufferSizeExceeded, got: %v", err)
+	}
+
+	data, err := transport.Read(ctx)
+	if err != nil || string(data) != "{\"ok\":true}\n" {
+		t.Fatalf("Expected the next frame after the oversized one, got %q, %v", data, err)
+	}
+
+	// A final frame without a trailing newline is still delivered.
+	data, err = transport.Read(ctx)
+	if err != nil || string(data) != "{\"last\":true}" {
+		t.Fatalf("Expected the unterminated final frame, got %q, %v", data, err)
+	}
+}
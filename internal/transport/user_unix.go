@@ -3,37 +3,43 @@
 package transport
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"os/user"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"syscall"
-)
 
-// resolveUserCredential resolves a username to syscall.Credential for process credentials.
-// This function looks up the user by username and returns the UID and GID needed
-// to set process credentials via SysProcAttr.Credential.
-//
-// Returns nil, nil if username is empty (no user switching requested).
-// Returns an error if the user cannot be found or the UID/GID cannot be parsed.
-func resolveUserCredential(username string) (*syscall.Credential, error) {
-	if username == "" {
-		return nil, nil
-	}
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
 
-	u, err := user.Lookup(username)
-	if err != nil {
-		return nil, fmt.Errorf("%w: user '%s': %v", ErrUserLookupFailed, username, err)
-	}
+// userIdentity is the user and groups the process is switched to.
+type userIdentity struct {
+	cred *syscall.Credential
+	// name describes the identity in errors, for example "user 'nobody'",
+	// and resource names it for a PermissionError.
+	name     string
+	resource string
+	// groups are the supplementary groups the process ends up with.
+	groups []uint32
+	// env holds HOME, USER and LOGNAME for the target user; empty when
+	// only the group changes.
+	env []string
+}
 
+// userCredential returns the UID and primary GID of u.
+func userCredential(u *user.User) (*syscall.Credential, error) {
 	uid, err := strconv.ParseUint(u.Uid, 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("%w: invalid UID '%s' for user '%s': %v", ErrInvalidUserID, u.Uid, username, err)
+		return nil, fmt.Errorf("%w: invalid UID '%s' for user '%s': %v", ErrInvalidUserID, u.Uid, u.Username, err)
 	}
 
 	gid, err := strconv.ParseUint(u.Gid, 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("%w: invalid GID '%s' for user '%s': %v", ErrInvalidUserID, u.Gid, username, err)
+		return nil, fmt.Errorf("%w: invalid GID '%s' for user '%s': %v", ErrInvalidUserID, u.Gid, u.Username, err)
 	}
 
 	return &syscall.Credential{
@@ -42,25 +48,250 @@ func resolveUserCredential(username string) (*syscall.Credential, error) {
 	}, nil
 }
 
-// configureUserCredential configures the command to run as the specified user.
-// If username is empty, this is a no-op.
-// Returns an error if user resolution fails.
-func configureUserCredential(cmd *exec.Cmd, username string) error {
+// resolveIdentity resolves the User, Group and SupplementaryGroups of config.
+//
+// A user switch also sets the user's supplementary groups, as login does,
+// unless SupplementaryGroups is given. A group switch without a user keeps
+// the current UID and supplementary groups.
+//
+// Returns nil, nil if no switch is requested. Failures are returned as
+// *clauderrs.PermissionError.
+func resolveIdentity(config *ProcessConfig) (*userIdentity, error) {
+	if config.User == "" && config.Group == "" && config.SupplementaryGroups == nil {
+		return nil, nil
+	}
+
+	id, err := lookupIdentity(config)
+	if err != nil {
+		return nil, clauderrs.NewPermissionError(
+			clauderrs.ErrCodeUserSwitchFailed,
+			"cannot run Claude Code as the requested user: "+err.Error(),
+			fmt.Errorf(errWrapFormat, ErrUserSwitchFailed, err),
+			identityResource(config),
+			"switch",
+		)
+	}
+	id.resource = identityResource(config)
+
+	return id, nil
+}
+
+// identityResource names the requested identity for a PermissionError.
+func identityResource(config *ProcessConfig) string {
+	if config.User != "" {
+		return "user:" + config.User
+	}
+
+	return "group:" + config.Group
+}
+
+func lookupIdentity(config *ProcessConfig) (*userIdentity, error) {
+	id := &userIdentity{}
+
+	if config.User != "" {
+		u, err := user.Lookup(config.User)
+		if err != nil {
+			return nil, fmt.Errorf("%w: user '%s': %v", ErrUserLookupFailed, config.User, err)
+		}
+		if id.cred, err = userCredential(u); err != nil {
+			return nil, err
+		}
+		id.name = fmt.Sprintf("user '%s'", config.User)
+		id.env = identityEnv(u)
+
+		if config.SupplementaryGroups == nil {
+			names, err := u.GroupIds()
+			if err != nil {
+				return nil, fmt.Errorf("%w: groups of user '%s': %v", ErrGroupLookupFailed, config.User, err)
+			}
+			if id.cred.Groups, err = lookupGroupIDs(names); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		id.cred = &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+		id.name = "the current user"
+
+		if config.SupplementaryGroups == nil {
+			id.cred.NoSetGroups = true
+			groups, err := os.Getgroups()
+			if err != nil {
+				return nil, fmt.Errorf("%w: current groups: %v", ErrGroupLookupFailed, err)
+			}
+			for _, gid := range groups {
+				id.groups = append(id.groups, uint32(gid))
+			}
+		}
+	}
+
+	if config.Group != "" {
+		gid, err := lookupGroupID(config.Group)
+		if err != nil {
+			return nil, err
+		}
+		id.cred.Gid = gid
+		id.name += fmt.Sprintf(" with group '%s'", config.Group)
+	}
+
+	if config.SupplementaryGroups != nil {
+		groups, err := lookupGroupIDs(config.SupplementaryGroups)
+		if err != nil {
+			return nil, err
+		}
+		// A non-nil empty slice clears the supplementary groups.
+		id.cred.Groups = append([]uint32{}, groups...)
+	}
+	if !id.cred.NoSetGroups {
+		id.groups = id.cred.Groups
+	}
+
+	return id, nil
+}
+
+// lookupGroupID resolves a group name or numeric GID.
+func lookupGroupID(group string) (uint32, error) {
+	if gid, err := strconv.ParseUint(group, 10, 32); err == nil {
+		return uint32(gid), nil
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("%w: group '%s': %v", ErrGroupLookupFailed, group, err)
+	}
+
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid GID '%s' for group '%s': %v", ErrInvalidUserID, g.Gid, group, err)
+	}
+
+	return uint32(gid), nil
+}
+
+func lookupGroupIDs(groups []string) ([]uint32, error) {
+	gids := make([]uint32, 0, len(groups))
+	for _, group := range groups {
+		gid, err := lookupGroupID(group)
+		if err != nil {
+			return nil, err
+		}
+		gids = append(gids, gid)
+	}
+
+	return gids, nil
+}
+
+// identityEnv returns HOME, USER and LOGNAME for u, so the CLI reads the
+// target user's ~/.claude rather than the parent's.
+func identityEnv(u *user.User) []string {
+	env := []string{"USER=" + u.Username, "LOGNAME=" + u.Username}
+	if u.HomeDir != "" {
+		env = append(env, "HOME="+u.HomeDir)
+	}
+
+	return env
+}
+
+// IdentityEnv returns the HOME, USER and LOGNAME entries the CLI is given
+// when it runs as username, or nil if the user cannot be found.
+func IdentityEnv(username string) []string {
 	if username == "" {
 		return nil
 	}
 
-	cred, err := resolveUserCredential(username)
+	u, err := user.Lookup(username)
 	if err != nil {
-		return err
+		return nil
 	}
 
-	if cred != nil {
-		if cmd.SysProcAttr == nil {
-			cmd.SysProcAttr = &syscall.SysProcAttr{}
+	return identityEnv(u)
+}
+
+// configureUserCredential configures the command to run with id's
+// credentials. If id is nil, this is a no-op.
+func configureUserCredential(cmd *exec.Cmd, id *userIdentity) {
+	if id == nil {
+		return
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = id.cred
+}
+
+// checkWorkingDirectory verifies that id can enter and list dir (the current
+// directory when empty), so a misconfigured switch fails with a clear error
+// instead of a CLI that cannot start. The check uses the permission bits of
+// dir and its parents; ACLs and other security modules are not consulted.
+func checkWorkingDirectory(dir string, id *userIdentity) error {
+	if id == nil || id.cred.Uid == 0 {
+		return nil
+	}
+
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return nil
+		}
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	// Every ancestor must be searchable; the directory itself must also be
+	// readable.
+	for p, need := abs, os.FileMode(0o5); ; p, need = filepath.Dir(p), 0o1 {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil
+		}
+		if !id.canAccess(info, need) {
+			return clauderrs.NewPermissionError(
+				clauderrs.ErrCodeDirectoryDenied,
+				fmt.Sprintf("%s cannot access working directory %s (denied at %s)", id.name, abs, p),
+				ErrWorkingDirectoryDenied,
+				abs,
+				"read",
+			)
 		}
-		cmd.SysProcAttr.Credential = cred
+		if filepath.Dir(p) == p {
+			return nil
+		}
+	}
+}
+
+// canAccess reports whether the permission bits of info grant id the
+// access in need (a combination of 4 read, 2 write and 1 execute).
+func (id *userIdentity) canAccess(info os.FileInfo, need os.FileMode) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+
+	perm := info.Mode().Perm()
+	switch {
+	case stat.Uid == id.cred.Uid:
+		perm >>= 6
+	case stat.Gid == id.cred.Gid || slices.Contains(id.groups, stat.Gid):
+		perm >>= 3
+	}
+
+	return perm&need == need
+}
+
+// userSwitchError reports a process start that failed because the parent may
+// not switch to id.
+func userSwitchError(id *userIdentity, err error) error {
+	if id == nil || !errors.Is(err, syscall.EPERM) {
+		return nil
 	}
 
-	return nil
+	return clauderrs.NewPermissionError(
+		clauderrs.ErrCodeUserSwitchFailed,
+		fmt.Sprintf("not permitted to run Claude Code as %s; this needs CAP_SETUID and CAP_SETGID", id.name),
+		fmt.Errorf(errWrapFormat, ErrUserSwitchFailed, err),
+		id.resource,
+		"switch",
+	)
 }
@@ -3,81 +3,67 @@
 package transport
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
 	"os/exec"
 	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
 )
 
-func TestResolveUserCredential_EmptyUsername(t *testing.T) {
-	cred, err := resolveUserCredential("")
+func TestResolveIdentity_NoSwitch(t *testing.T) {
+	id, err := resolveIdentity(&ProcessConfig{})
 	if err != nil {
-		t.Errorf("expected no error for empty username, got: %v", err)
+		t.Errorf("expected no error without a switch, got: %v", err)
 	}
-	if cred != nil {
-		t.Error("expected nil credential for empty username")
+	if id != nil {
+		t.Error("expected nil identity without a switch")
 	}
 }
 
-func TestResolveUserCredential_CurrentUser(t *testing.T) {
-	// Get current user
+func TestResolveIdentity_CurrentUser(t *testing.T) {
 	currentUser, err := user.Current()
 	if err != nil {
 		t.Skipf("could not get current user: %v", err)
 	}
 
-	cred, err := resolveUserCredential(currentUser.Username)
+	id, err := resolveIdentity(&ProcessConfig{User: currentUser.Username})
 	if err != nil {
-		t.Errorf("failed to resolve current user: %v", err)
-	}
-	if cred == nil {
-		t.Fatal("expected non-nil credential")
+		t.Fatalf("failed to resolve current user: %v", err)
 	}
 
-	// Verify UID matches
-	if cred.Uid == 0 && currentUser.Uid != "0" {
-		t.Error("expected non-root UID for non-root user")
-	}
-}
-
-func TestResolveUserCredential_InvalidUser(t *testing.T) {
-	// Use a username that definitely doesn't exist
-	_, err := resolveUserCredential("nonexistent_user_abc123xyz")
-	if err == nil {
-		t.Error("expected error for nonexistent user")
-	}
-
-	// Verify error wraps ErrUserLookupFailed
-	if !errors.Is(err, ErrUserLookupFailed) {
-		t.Errorf("expected error to wrap ErrUserLookupFailed, got: %v", err)
+	if fmt.Sprint(id.cred.Uid) != currentUser.Uid || fmt.Sprint(id.cred.Gid) != currentUser.Gid {
+		t.Errorf("expected %s:%s, got %d:%d", currentUser.Uid, currentUser.Gid, id.cred.Uid, id.cred.Gid)
 	}
 }
 
-func TestResolveUserCredential_RootUser(t *testing.T) {
-	// Try to resolve root user (should exist on all Unix systems)
-	cred, err := resolveUserCredential("root")
+func TestResolveIdentity_RootUser(t *testing.T) {
+	// Root should exist on all Unix systems.
+	id, err := resolveIdentity(&ProcessConfig{User: "root"})
 	if err != nil {
-		// On some systems root might not be accessible
 		t.Skipf("could not resolve root user: %v", err)
 	}
-	if cred == nil {
-		t.Fatal("expected non-nil credential for root")
-	}
-	if cred.Uid != 0 {
-		t.Errorf("expected UID 0 for root, got: %d", cred.Uid)
-	}
-	if cred.Gid != 0 {
-		t.Errorf("expected GID 0 for root, got: %d", cred.Gid)
+	if id.cred.Uid != 0 || id.cred.Gid != 0 {
+		t.Errorf("expected 0:0 for root, got %d:%d", id.cred.Uid, id.cred.Gid)
 	}
 }
 
-func TestConfigureUserCredential_EmptyUsername(t *testing.T) {
+func TestConfigureUserCredential_NoSwitch(t *testing.T) {
 	cmd := exec.Command("echo", "test")
 
-	err := configureUserCredential(cmd, "")
+	id, err := resolveIdentity(&ProcessConfig{})
 	if err != nil {
 		t.Errorf("expected no error for empty username, got: %v", err)
 	}
+	configureUserCredential(cmd, id)
 
 	// SysProcAttr should remain nil
 	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Credential != nil {
@@ -94,10 +80,11 @@ func TestConfigureUserCredential_CurrentUser(t *testing.T) {
 
 	cmd := exec.Command("echo", "test")
 
-	err = configureUserCredential(cmd, currentUser.Username)
+	id, err := resolveIdentity(&ProcessConfig{User: currentUser.Username})
 	if err != nil {
-		t.Errorf("failed to configure current user credential: %v", err)
+		t.Fatalf("failed to resolve current user: %v", err)
 	}
+	configureUserCredential(cmd, id)
 
 	// SysProcAttr should be set
 	if cmd.SysProcAttr == nil {
@@ -106,18 +93,214 @@ func TestConfigureUserCredential_CurrentUser(t *testing.T) {
 	if cmd.SysProcAttr.Credential == nil {
 		t.Fatal("expected Credential to be set")
 	}
-}
 
-func TestConfigureUserCredential_InvalidUser(t *testing.T) {
-	cmd := exec.Command("echo", "test")
+	want := []string{"USER=" + currentUser.Username, "LOGNAME=" + currentUser.Username, "HOME=" + currentUser.HomeDir}
+	if !slices.Equal(id.env, want) {
+		t.Errorf("expected identity env %v, got %v", want, id.env)
+	}
+}
 
-	err := configureUserCredential(cmd, "nonexistent_user_abc123xyz")
+func TestResolveIdentity_InvalidUser(t *testing.T) {
+	_, err := resolveIdentity(&ProcessConfig{User: "nonexistent_user_abc123xyz"})
 	if err == nil {
-		t.Error("expected error for nonexistent user")
+		t.Fatal("expected error for nonexistent user")
 	}
 
-	// Verify error wraps ErrUserLookupFailed
-	if !errors.Is(err, ErrUserLookupFailed) {
+	// Verify error wraps ErrUserLookupFailed and is a PermissionError
+	if !errors.Is(err, ErrUserLookupFailed) || !errors.Is(err, ErrUserSwitchFailed) {
 		t.Errorf("expected error to wrap ErrUserLookupFailed, got: %v", err)
 	}
+	var permErr *clauderrs.PermissionError
+	if !errors.As(err, &permErr) || permErr.Code() != clauderrs.ErrCodeUserSwitchFailed {
+		t.Fatalf("expected PermissionError, got %v", err)
+	}
+	if permErr.Resource() != "user:nonexistent_user_abc123xyz" {
+		t.Errorf("unexpected resource %q", permErr.Resource())
+	}
+}
+
+func TestResolveIdentity_InvalidGroup(t *testing.T) {
+	_, err := resolveIdentity(&ProcessConfig{Group: "nonexistent_group_abc123xyz"})
+	if !errors.Is(err, ErrGroupLookupFailed) || !clauderrs.IsPermissionError(err) {
+		t.Errorf("expected PermissionError wrapping ErrGroupLookupFailed, got: %v", err)
+	}
+}
+
+func TestResolveIdentity_GroupOnly(t *testing.T) {
+	id, err := resolveIdentity(&ProcessConfig{Group: "12345"})
+	if err != nil {
+		t.Fatalf("resolveIdentity: %v", err)
+	}
+
+	if id.cred.Uid != uint32(os.Getuid()) || id.cred.Gid != 12345 {
+		t.Errorf("expected current UID with GID 12345, got %d:%d", id.cred.Uid, id.cred.Gid)
+	}
+	if !id.cred.NoSetGroups {
+		t.Error("expected supplementary groups to be kept")
+	}
+	if len(id.env) != 0 {
+		t.Errorf("expected no identity env without a user, got %v", id.env)
+	}
+}
+
+func TestResolveIdentity_SupplementaryGroups(t *testing.T) {
+	id, err := resolveIdentity(&ProcessConfig{SupplementaryGroups: []string{"100", "200"}})
+	if err != nil {
+		t.Fatalf("resolveIdentity: %v", err)
+	}
+	if id.cred.NoSetGroups || !slices.Equal(id.cred.Groups, []uint32{100, 200}) {
+		t.Errorf("expected groups [100 200], got %v (NoSetGroups %v)", id.cred.Groups, id.cred.NoSetGroups)
+	}
+
+	// An empty list clears the groups rather than keeping them.
+	id, err = resolveIdentity(&ProcessConfig{SupplementaryGroups: []string{}})
+	if err != nil {
+		t.Fatalf("resolveIdentity: %v", err)
+	}
+	if id.cred.NoSetGroups || id.cred.Groups == nil || len(id.cred.Groups) != 0 {
+		t.Errorf("expected empty groups, got %v (NoSetGroups %v)", id.cred.Groups, id.cred.NoSetGroups)
+	}
+}
+
+func TestCheckWorkingDirectory(t *testing.T) {
+	base := sharedTempDir(t)
+	open := filepath.Join(base, "open")
+	private := filepath.Join(base, "private")
+	for dir, mode := range map[string]os.FileMode{open: 0o755, private: 0o700} {
+		if err := os.Mkdir(dir, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(dir, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inside := filepath.Join(private, "inside")
+	if err := os.Mkdir(inside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A UID that owns none of the directories and is in none of their
+	// groups.
+	stranger := &userIdentity{
+		cred: &syscall.Credential{Uid: 54321, Gid: 54321},
+		name: "user 'stranger'",
+	}
+
+	if err := checkWorkingDirectory(open, stranger); err != nil {
+		t.Errorf("expected open directory to be accessible, got %v", err)
+	}
+
+	for _, dir := range []string{private, inside} {
+		err := checkWorkingDirectory(dir, stranger)
+
+		var permErr *clauderrs.PermissionError
+		if !errors.As(err, &permErr) || permErr.Code() != clauderrs.ErrCodeDirectoryDenied {
+			t.Fatalf("expected directory denied for %s, got %v", dir, err)
+		}
+		if !errors.Is(err, ErrWorkingDirectoryDenied) || permErr.Resource() != dir {
+			t.Errorf("unexpected error %v for %s", err, dir)
+		}
+	}
+
+	// Group membership grants the group bits.
+	member := &userIdentity{
+		cred:   &syscall.Credential{Uid: 54321, Gid: 54321},
+		groups: []uint32{groupOf(t, private)},
+	}
+	if err := os.Chmod(private, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWorkingDirectory(inside, member); err != nil {
+		t.Errorf("expected group member to have access, got %v", err)
+	}
+}
+
+// sharedTempDir returns a temporary directory that other users can enter.
+func sharedTempDir(t *testing.T) string {
+	t.Helper()
+
+	// t.TempDir nests the directory in a private per-test directory.
+	dir := t.TempDir()
+	for _, d := range []string{filepath.Dir(dir), dir} {
+		if err := os.Chmod(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// groupOf returns the group that owns path.
+func groupOf(t *testing.T, path string) uint32 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return info.Sys().(*syscall.Stat_t).Gid
+}
+
+func TestProcess_RunsAsUserWithItsEnvironment(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skipf("no nobody user: %v", err)
+	}
+	t.Setenv(SkipVersionCheckEnvVar, "true")
+
+	dir := sharedTempDir(t)
+	cli := writeExecutable(t, dir, "claude", `echo "$(id -u) $USER $LOGNAME $HOME"
+cat >/dev/null
+`)
+
+	proc, err := NewProcess(context.Background(), &ProcessConfig{
+		Executable: cli,
+		Cwd:        dir,
+		User:       "nobody",
+		Env:        []string{"LOGNAME=override"},
+	})
+	if err != nil {
+		t.Fatalf("NewProcess: %v", err)
+	}
+	defer proc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	line, err := proc.Transport().Read(ctx)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	// Options.Env still overrides the identity variables.
+	want := fmt.Sprintf("%s nobody override %s", nobody.Uid, nobody.HomeDir)
+	if got := strings.TrimSpace(string(line)); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestProcess_RejectsInaccessibleWorkingDirectory(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+	if _, err := user.Lookup("nobody"); err != nil {
+		t.Skipf("no nobody user: %v", err)
+	}
+	t.Setenv(SkipVersionCheckEnvVar, "true")
+
+	dir := t.TempDir()
+	cli := writeExecutable(t, dir, "claude", "cat >/dev/null\n")
+
+	_, err := NewProcess(context.Background(), &ProcessConfig{
+		Executable: cli,
+		Cwd:        dir,
+		User:       "nobody",
+	})
+	if !clauderrs.IsPermissionError(err) || !errors.Is(err, ErrWorkingDirectoryDenied) {
+		t.Fatalf("expected working directory PermissionError, got %v", err)
+	}
 }
@@ -4,14 +4,34 @@ package transport
 
 import "os/exec"
 
-// configureUserCredential is a no-op on Windows.
+// userIdentity is empty on Windows.
 // User switching via SysProcAttr.Credential is not supported on Windows.
 // Windows requires different APIs (CreateProcessAsUser, LogonUser) which are
 // not implemented in this SDK.
-//
-// Returns nil for any input on Windows.
-func configureUserCredential(cmd *exec.Cmd, username string) error {
-	// Windows doesn't support syscall.Credential
-	// Silently ignore user switching on Windows
+type userIdentity struct {
+	env []string
+}
+
+// resolveIdentity returns nil, nil: User, Group and SupplementaryGroups are
+// silently ignored on Windows.
+func resolveIdentity(*ProcessConfig) (*userIdentity, error) {
+	return nil, nil
+}
+
+// IdentityEnv returns nil on Windows.
+func IdentityEnv(string) []string {
+	return nil
+}
+
+// configureUserCredential is a no-op on Windows.
+func configureUserCredential(*exec.Cmd, *userIdentity) {}
+
+// checkWorkingDirectory is a no-op on Windows.
+func checkWorkingDirectory(string, *userIdentity) error {
+	return nil
+}
+
+// userSwitchError returns nil on Windows.
+func userSwitchError(*userIdentity, error) error {
 	return nil
 }
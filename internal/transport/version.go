@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
@@ -47,28 +46,20 @@ var versionRegex = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
 
 // checkCLIVersion verifies that the Claude CLI version meets the minimum requirements.
 // It can be skipped by setting the CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK environment
-// variable to "true".
-func checkCLIVersion(executable string) error {
+// variable to "true". When config sets a command wrapper, the check runs inside it,
+// so the version reported is that of the CLI the session will actually use.
+// The version is probed once per executable; see ProbeCLI.
+func checkCLIVersion(executable string, config *ProcessConfig) error {
 	// Check if version check should be skipped
 	if strings.EqualFold(os.Getenv(SkipVersionCheckEnvVar), "true") {
 		return nil
 	}
 
-	// Execute claude --version
-	cmd := exec.Command(executable, "--version")
-	output, err := cmd.CombinedOutput()
+	currentVersion, err := cliVersion(executable, config)
 	if err != nil {
-		return fmt.Errorf(errWrapFormat, ErrVersionCheckFailed, err)
+		return err
 	}
 
-	// Parse the version from output
-	major, minor, patch, err := parseVersion(string(output))
-	if err != nil {
-		return fmt.Errorf(errWrapFormat, ErrVersionParseFailed, err)
-	}
-
-	currentVersion := fmt.Sprintf("%d.%d.%d", major, minor, patch)
-
 	// Compare versions
 	cmp, err := compareVersions(currentVersion, MinimumClaudeCodeVersion)
 	if err != nil {
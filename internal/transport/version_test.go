@@ -287,7 +287,7 @@ func TestCheckCLIVersionSkip(t *testing.T) {
 			// If skip is enabled, it should return nil without trying to execute
 			// If skip is disabled, it will try to execute and fail
 			nonExistentExec := "/tmp/definitely-not-a-real-claude-executable-12345"
-			err := checkCLIVersion(nonExistentExec)
+			err := checkCLIVersion(nonExistentExec, nil)
 
 			if tt.shouldSkip {
 				// When skip is enabled, should return nil even with non-existent executable
@@ -309,7 +309,7 @@ func TestCheckCLIVersionUnset(t *testing.T) {
 	// When the environment variable is not set, the version check should be performed.
 	// Using a non-existent executable should cause an error.
 	nonExistentExec := "/tmp/definitely-not-a-real-claude-executable-99999"
-	err := checkCLIVersion(nonExistentExec)
+	err := checkCLIVersion(nonExistentExec, nil)
 
 	if err == nil {
 		t.Error("checkCLIVersion() with unset env var and non-existent executable should return error, got nil")
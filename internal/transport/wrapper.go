@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"os"
+	"strings"
+)
+
+// Placeholders expanded in ProcessConfig.CommandWrapper.
+const (
+	// WrapperExecutablePlaceholder is replaced with the CLI executable. If
+	// no wrapper argument contains it, the executable follows the wrapper.
+	// The CLI arguments always follow the wrapper.
+	WrapperExecutablePlaceholder = "{executable}"
+	// WrapperCwdPlaceholder is replaced with the working directory.
+	WrapperCwdPlaceholder = "{cwd}"
+	// WrapperEnvPlaceholder starts an environment template, an argument of
+	// the form "{env:WORDS}". It expands, once per variable of the CLI's
+	// environment (the base environment, the identity variables and
+	// ProcessConfig.Env, later entries winning), to the space-separated
+	// WORDS as separate arguments, with NAME replaced by the variable's name
+	// and VALUE by its value: "{env:--setenv=NAME}" for systemd-run, or
+	// "{env:--setenv NAME VALUE}" for bwrap --clearenv.
+	WrapperEnvPlaceholder = "{env:"
+)
+
+// expandEnvTemplate returns the arguments an environment template expands
+// to, and false if arg is not a template.
+func expandEnvTemplate(arg string, env []string) ([]string, bool) {
+	if !strings.HasPrefix(arg, WrapperEnvPlaceholder) || !strings.HasSuffix(arg, "}") {
+		return nil, false
+	}
+	words := strings.Fields(arg[len(WrapperEnvPlaceholder) : len(arg)-1])
+
+	// The last entry for a name is the one the process sees.
+	last := make(map[string]int, len(env))
+	for i, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		last[name] = i
+	}
+
+	var argv []string
+	for i, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		if last[name] != i {
+			continue
+		}
+		replacer := strings.NewReplacer("NAME", name, "VALUE", value)
+		for _, word := range words {
+			argv = append(argv, replacer.Replace(word))
+		}
+	}
+
+	return argv, true
+}
+
+// wrapCommand returns the program and arguments that run executable with args
+// inside wrapper. Without a wrapper it returns executable and args unchanged.
+func wrapCommand(wrapper []string, executable string, args []string, cwd string, env []string) (string, []string) {
+	if len(wrapper) == 0 {
+		return executable, args
+	}
+
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+
+	argv := make([]string, 0, len(wrapper)+len(args)+1)
+	placed := false
+	for _, arg := range wrapper {
+		if expanded, ok := expandEnvTemplate(arg, env); ok {
+			argv = append(argv, expanded...)
+
+			continue
+		}
+		if strings.Contains(arg, WrapperExecutablePlaceholder) {
+			arg = strings.ReplaceAll(arg, WrapperExecutablePlaceholder, executable)
+			placed = true
+		}
+		argv = append(argv, strings.ReplaceAll(arg, WrapperCwdPlaceholder, cwd))
+	}
+	if !placed {
+		argv = append(argv, executable)
+	}
+	argv = append(argv, args...)
+
+	return argv[0], argv[1:]
+}
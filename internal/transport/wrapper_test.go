@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWrapCommand(t *testing.T) {
+	// A later entry overrides an earlier one, as in the environment.
+	env := []string{"A=0", "A=1", "B=2"}
+	tests := []struct {
+		name     string
+		wrapper  []string
+		wantName string
+		wantArgs []string
+	}{
+		{
+			name:     "no wrapper",
+			wantName: "claude",
+			wantArgs: []string{"--print"},
+		},
+		{
+			name:     "executable appended",
+			wrapper:  []string{"firejail", "--quiet"},
+			wantName: "firejail",
+			wantArgs: []string{"--quiet", "claude", "--print"},
+		},
+		{
+			name:     "placeholders",
+			wrapper:  []string{"systemd-run", "--user", "--working-directory={cwd}", "{env:--setenv=NAME}", "{executable}"},
+			wantName: "systemd-run",
+			wantArgs: []string{"--user", "--working-directory=/work", "--setenv=A", "--setenv=B", "claude", "--print"},
+		},
+		{
+			name:     "env template expands to separate arguments",
+			wrapper:  []string{"bwrap", "--clearenv", "{env:--setenv NAME VALUE}", "--", "{executable}"},
+			wantName: "bwrap",
+			wantArgs: []string{"--clearenv", "--setenv", "A", "1", "--setenv", "B", "2", "--", "claude", "--print"},
+		},
+		{
+			name:     "env without a template is left alone",
+			wrapper:  []string{"env", "{env}", "{executable}"},
+			wantName: "env",
+			wantArgs: []string{"{env}", "claude", "--print"},
+		},
+		{
+			name:     "executable inside an argument",
+			wrapper:  []string{"sh", "-c", "exec {executable} \"$@\"", "sh"},
+			wantName: "sh",
+			wantArgs: []string{"-c", "exec claude \"$@\"", "sh", "--print"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := wrapCommand(tt.wrapper, "claude", []string{"--print"}, "/work", env)
+			if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("wrapCommand() = %q %q, want %q %q", name, args, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCreateCommand_EnvTemplateSeesChildEnvironment(t *testing.T) {
+	cmd := createCommand(context.Background(), "claude", &ProcessConfig{
+		BaseEnv:        []string{"PATH=/usr/bin", "LANG=C"},
+		Env:            []string{"LANG=C.UTF-8"},
+		CommandWrapper: []string{"bwrap", "--clearenv", "{env:--setenv NAME VALUE}", "--"},
+	}, []string{"HOME=/home/agent"})
+
+	want := []string{
+		"bwrap", "--clearenv",
+		"--setenv", "PATH", "/usr/bin",
+		"--setenv", "HOME", "/home/agent",
+		"--setenv", "LANG", "C.UTF-8",
+		"--", "claude",
+	}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("expected %q, got %q", want, cmd.Args)
+	}
+	if !reflect.DeepEqual(cmd.Env, []string{"PATH=/usr/bin", "LANG=C", "HOME=/home/agent", "LANG=C.UTF-8"}) {
+		t.Errorf("unexpected environment %q", cmd.Env)
+	}
+}
@@ -0,0 +1,215 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// writeQueueSize is how many frames may wait for the writer goroutine before
+// Write blocks.
+const writeQueueSize = 16
+
+// Write request states. A request moves from pending to either writing or
+// cancelled exactly once, so a cancelled write is never started and a started
+// write is always finished.
+const (
+	writePending int32 = iota
+	writeStarted
+	writeCancelled
+)
+
+// WriteStats reports stdin write activity and backpressure.
+type WriteStats struct {
+	// QueueDepth is the number of frames waiting to be written.
+	QueueDepth int
+	// MaxQueueDepth is the highest QueueDepth observed.
+	MaxQueueDepth int
+	// FramesWritten and BytesWritten count completed writes, newline
+	// delimiters included.
+	FramesWritten uint64
+	BytesWritten  uint64
+	// WritesCancelled counts writes abandoned through their context or by
+	// Close before they started.
+	WritesCancelled uint64
+	// EnqueueWait is the total time callers spent blocked on a full queue.
+	EnqueueWait time.Duration
+	// WriteTime is the total time spent writing to stdin, which grows when
+	// the CLI is slow to read.
+	WriteTime time.Duration
+}
+
+// writeRequest is one frame queued for the writer goroutine.
+type writeRequest struct {
+	data   []byte
+	state  atomic.Int32
+	result chan error
+}
+
+// frameWriter serializes writes to stdin through a single goroutine so that
+// frames from concurrent callers never interleave, and are written in the
+// order they were queued.
+type frameWriter struct {
+	w     io.Writer
+	queue chan *writeRequest
+	done  <-chan struct{}
+	once  sync.Once
+
+	// err is the error that broke the stream. It is only accessed by the
+	// writer goroutine.
+	err error
+
+	depth       atomic.Int64
+	maxDepth    atomic.Int64
+	frames      atomic.Uint64
+	bytes       atomic.Uint64
+	cancelled   atomic.Uint64
+	enqueueWait atomic.Int64
+	writeTime   atomic.Int64
+}
+
+func newFrameWriter(w io.Writer, done <-chan struct{}) *frameWriter {
+	return &frameWriter{
+		w:     w,
+		queue: make(chan *writeRequest, writeQueueSize),
+		done:  done,
+	}
+}
+
+// Write queues data as one newline-terminated frame and waits for it to be
+// written.
+//
+// If ctx is done or the transport closes while the frame is still queued, the
+// frame is dropped and the context error (or ErrTransportClosed) is returned.
+// Once the frame has started, it is always written in full and Write returns
+// the write's result, so the stream is never left with a partial frame.
+func (fw *frameWriter) Write(ctx context.Context, data []byte) error {
+	fw.once.Do(func() {
+		go fw.run()
+	})
+
+	message := make([]byte, len(data)+1)
+	copy(message, data)
+	message[len(data)] = '\n'
+
+	req := &writeRequest{data: message, result: make(chan error, 1)}
+	if err := fw.enqueue(ctx, req); err != nil {
+		return err
+	}
+
+	var abandon error
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		abandon = ctx.Err()
+	case <-fw.done:
+		abandon = ErrTransportClosed
+	}
+
+	if req.state.CompareAndSwap(writePending, writeCancelled) {
+		fw.cancelled.Add(1)
+
+		return abandon
+	}
+
+	return <-req.result
+}
+
+// enqueue adds req to the queue, blocking while it is full.
+func (fw *frameWriter) enqueue(ctx context.Context, req *writeRequest) error {
+	depth := fw.depth.Add(1)
+	for {
+		maxDepth := fw.maxDepth.Load()
+		if depth <= maxDepth || fw.maxDepth.CompareAndSwap(maxDepth, depth) {
+			break
+		}
+	}
+
+	select {
+	case <-fw.done:
+		fw.depth.Add(-1)
+
+		return ErrTransportClosed
+	default:
+	}
+
+	select {
+	case fw.queue <- req:
+		return nil
+	default:
+	}
+
+	start := time.Now()
+	defer func() {
+		fw.enqueueWait.Add(int64(time.Since(start)))
+	}()
+
+	select {
+	case fw.queue <- req:
+		return nil
+	case <-ctx.Done():
+		fw.depth.Add(-1)
+		fw.cancelled.Add(1)
+
+		return ctx.Err()
+	case <-fw.done:
+		fw.depth.Add(-1)
+
+		return ErrTransportClosed
+	}
+}
+
+// run writes queued frames until the transport closes.
+func (fw *frameWriter) run() {
+	for {
+		select {
+		case req := <-fw.queue:
+			fw.depth.Add(-1)
+			if !req.state.CompareAndSwap(writePending, writeStarted) {
+				continue
+			}
+			req.result <- fw.writeFrame(req.data)
+		case <-fw.done:
+			return
+		}
+	}
+}
+
+// writeFrame writes one frame. After a failed write the stream may hold a
+// partial frame, so every later frame fails with the same error.
+func (fw *frameWriter) writeFrame(data []byte) error {
+	if fw.err != nil {
+		return fw.err
+	}
+
+	start := time.Now()
+	n, err := fw.w.Write(data)
+	fw.writeTime.Add(int64(time.Since(start)))
+	fw.bytes.Add(uint64(n))
+
+	if err != nil {
+		fw.err = fmt.Errorf(errWrapFormat, ErrWriteFailed, err)
+
+		return fw.err
+	}
+	fw.frames.Add(1)
+
+	return nil
+}
+
+// Stats returns a snapshot of the writer's counters.
+func (fw *frameWriter) Stats() WriteStats {
+	return WriteStats{
+		QueueDepth:      int(fw.depth.Load()),
+		MaxQueueDepth:   int(fw.maxDepth.Load()),
+		FramesWritten:   fw.frames.Load(),
+		BytesWritten:    fw.bytes.Load(),
+		WritesCancelled: fw.cancelled.Load(),
+		EnqueueWait:     time.Duration(fw.enqueueWait.Load()),
+		WriteTime:       time.Duration(fw.writeTime.Load()),
+	}
+}
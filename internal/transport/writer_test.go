@@ -0,0 +1,152 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowWriter records writes in small pieces, yielding between them so that
+// unsynchronized concurrent writers would interleave.
+type slowWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	for i := 0; i < len(p); i += 7 {
+		end := min(i+7, len(p))
+		w.mu.Lock()
+		w.buf.Write(p[i:end])
+		w.mu.Unlock()
+		time.Sleep(time.Microsecond)
+	}
+
+	return len(p), nil
+}
+
+func (w *slowWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.String()
+}
+
+func TestFrameWriter_ConcurrentWritesDoNotInterleave(t *testing.T) {
+	w := &slowWriter{}
+	done := make(chan struct{})
+	defer close(done)
+	fw := newFrameWriter(w, done)
+
+	const writers, perWriter = 8, 10
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range perWriter {
+				frame := fmt.Sprintf(`{"writer":%d,"seq":%d,"pad":%q}`, i, j, strings.Repeat("x", 50))
+				if err := fw.Write(context.Background(), []byte(frame)); err != nil {
+					t.Errorf("write: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	if len(lines) != writers*perWriter {
+		t.Fatalf("expected %d frames, got %d", writers*perWriter, len(lines))
+	}
+
+	// Frames from one writer keep their order and arrive intact.
+	next := make(map[int]int)
+	for _, line := range lines {
+		var writer, seq int
+		if _, err := fmt.Sscanf(line, `{"writer":%d,"seq":%d,`, &writer, &seq); err != nil {
+			t.Fatalf("corrupted frame %q: %v", line, err)
+		}
+		if seq != next[writer] {
+			t.Fatalf("writer %d: expected seq %d, got %d", writer, next[writer], seq)
+		}
+		next[writer]++
+	}
+
+	stats := fw.Stats()
+	if stats.FramesWritten != writers*perWriter || stats.QueueDepth != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.started <- struct{}{}
+	<-w.release
+	w.buf.Write(p)
+
+	return len(p), nil
+}
+
+func TestFrameWriter_CancelledQueuedWriteIsDropped(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}, 2), release: make(chan struct{})}
+	done := make(chan struct{})
+	defer close(done)
+	fw := newFrameWriter(w, done)
+
+	// The first write starts and blocks in the underlying writer.
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() { firstErr <- fw.Write(firstCtx, []byte("first")) }()
+	<-w.started
+
+	// The second write is queued behind it, then cancelled.
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	secondErr := make(chan error, 1)
+	go func() { secondErr <- fw.Write(secondCtx, []byte("second")) }()
+	for fw.Stats().QueueDepth == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancelSecond()
+	if err := <-secondErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected queued write to be cancelled, got %v", err)
+	}
+
+	// Cancelling a started write still lets it finish in full.
+	cancelFirst()
+	close(w.release)
+	if err := <-firstErr; err != nil {
+		t.Fatalf("expected started write to complete, got %v", err)
+	}
+
+	if err := fw.Write(context.Background(), []byte("third")); err != nil {
+		t.Fatalf("write after cancellation: %v", err)
+	}
+	if got := w.buf.String(); got != "first\nthird\n" {
+		t.Fatalf("unexpected stream %q", got)
+	}
+	if stats := fw.Stats(); stats.WritesCancelled != 1 || stats.FramesWritten != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestFrameWriter_WriteAfterCloseFails(t *testing.T) {
+	done := make(chan struct{})
+	fw := newFrameWriter(io.Discard, done)
+	close(done)
+
+	if err := fw.Write(context.Background(), []byte("x")); !errors.Is(err, ErrTransportClosed) {
+		t.Fatalf("expected ErrTransportClosed, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Package cassette records Claude Code sessions to JSONL cassettes and
+// replays them offline through the same claude.Query interface.
+//
+// A Recorder wraps a transport factory and writes every frame exchanged with
+// the CLI (user messages, SDK messages and control traffic in both
+// directions) with its timestamp and direction, redacting secrets as it goes:
+//
+//	f, _ := os.Create("testdata/session.jsonl")
+//	rec := cassette.NewRecorder(f, nil)
+//	q, err := claude.QueryFunc(prompt, &claude.Options{
+//		TransportFactory: rec.Factory(nil),
+//	})
+//
+// A loaded Cassette acts as the CLI on replay. Outgoing frames are matched
+// against the recording, CLI messages are played back in order, and control
+// request IDs are remapped so the SDK's fresh IDs line up with the recorded
+// responses:
+//
+//	c, err := cassette.LoadFile("testdata/session.jsonl")
+//	q, err := claude.QueryFunc(prompt, &claude.Options{
+//		TransportFactory: c.Factory(&cassette.ReplayOptions{Match: cassette.MatchStrict}),
+//	})
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Direction is the direction of a frame.
+type Direction string
+
+const (
+	// DirectionStdin is a frame written by the SDK to the CLI.
+	DirectionStdin Direction = "stdin"
+	// DirectionStdout is a frame read by the SDK from the CLI.
+	DirectionStdout Direction = "stdout"
+)
+
+// Event marks a frame that records a stream event rather than a message.
+type Event string
+
+const (
+	// EventStart is the first frame of a cassette; it carries the CLI
+	// arguments.
+	EventStart Event = "start"
+	// EventCloseInput records the SDK closing its input.
+	EventCloseInput Event = "close_input"
+	// EventEOF records the end of the CLI's output.
+	EventEOF Event = "eof"
+)
+
+// Frame is one line of a cassette.
+type Frame struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"direction,omitempty"`
+	Event     Event     `json:"event,omitempty"`
+	// Data is the JSON message, for message frames.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Text holds a line that was not valid JSON.
+	Text string `json:"text,omitempty"`
+	// Args are the CLI arguments, on the start frame.
+	Args []string `json:"args,omitempty"`
+}
+
+// Cassette is a recorded session.
+type Cassette struct {
+	Frames []Frame
+}
+
+// Load reads a cassette in JSONL form.
+func Load(r io.Reader) (*Cassette, error) {
+	reader := bufio.NewReader(r)
+	c := &Cassette{}
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var f Frame
+			if jsonErr := json.Unmarshal(line, &f); jsonErr != nil {
+				return nil, fmt.Errorf("cassette: line %d: %w", n, jsonErr)
+			}
+			c.Frames = append(c.Frames, f)
+		}
+		if errors.Is(err, io.EOF) {
+			return c, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+	}
+}
+
+// LoadFile reads a cassette from a file.
+func LoadFile(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// Args returns the CLI arguments recorded on the start frame.
+func (c *Cassette) Args() []string {
+	for _, f := range c.Frames {
+		if f.Event == EventStart {
+			return f.Args
+		}
+	}
+
+	return nil
+}
+
+// frameKind identifies a message by its type and, for control traffic, its
+// subtype.
+func frameKind(data []byte) string {
+	var envelope struct {
+		Type    string `json:"type"`
+		Subtype string `json:"subtype"`
+		Request struct {
+			Subtype string `json:"subtype"`
+		} `json:"request"`
+		Response struct {
+			Subtype string `json:"subtype"`
+		} `json:"response"`
+	}
+	if json.Unmarshal(data, &envelope) != nil {
+		return ""
+	}
+
+	switch envelope.Type {
+	case "control_request":
+		return envelope.Type + ":" + envelope.Request.Subtype
+	case "control_response":
+		return envelope.Type + ":" + envelope.Response.Subtype
+	}
+
+	return envelope.Type
+}
@@ -0,0 +1,276 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	claude "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/claudetest"
+)
+
+const secretPrompt = "deploy with key sk-ant-api03-abcDEF123"
+
+// permissionOptions returns options whose CanUseTool allows every tool and
+// counts the calls.
+func permissionOptions(calls *int) *claude.Options {
+	cont := true
+
+	return &claude.Options{
+		CanUseTool: func(
+			context.Context, string, map[string]claude.JSONValue, []claude.PermissionUpdate,
+			string, *string, *string, *string,
+		) (claude.PermissionResult, error) {
+			*calls++
+
+			return claude.PermissionAllow{Behavior: claude.PermissionBehaviorAllow}, nil
+		},
+		// Hooks make the SDK send an initialize control request, whose
+		// response must be remapped to the SDK's request ID on replay.
+		Hooks: map[claude.HookEvent][]claude.HookCallbackMatcher{
+			claude.HookEventPreToolUse: {{
+				Hooks: []claude.HookCallback{
+					func(context.Context, claude.HookInput, *string) (claude.HookJSONOutput, error) {
+						return claude.SyncHookOutput{Continue: &cont}, nil
+					},
+				},
+			}},
+		},
+	}
+}
+
+// collect reads messages until the result.
+func collect(t *testing.T, q claude.Query) ([]string, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var types []string
+	for {
+		msg, err := q.Next(ctx)
+		if err != nil {
+			return types, err
+		}
+		types = append(types, msg.Type())
+		if msg.Type() == "result" {
+			return types, nil
+		}
+	}
+}
+
+// record runs a scripted session through a Recorder and returns the cassette.
+func record(t *testing.T) []byte {
+	t.Helper()
+
+	cli := claudetest.New(t,
+		claudetest.ExpectUserMessage("deploy"),
+		claudetest.SystemInit("claude-test"),
+		claudetest.ToolUse("tool_1", "Bash", map[string]any{"command": "make deploy"}),
+		claudetest.CanUseTool("Bash", "tool_1", map[string]any{"command": "make deploy"}, nil),
+		claudetest.Assistant("Deployed."),
+		claudetest.Result("done"),
+		claudetest.Exit(),
+	)
+
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf, nil)
+
+	calls := 0
+	opts := permissionOptions(&calls)
+	opts.TransportFactory = rec.Factory(cli.Factory)
+	q, err := claude.QueryFunc(secretPrompt, opts)
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	if _, err := collect(t, q); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	// Read the end of the stream so the cassette records it.
+	if _, err := q.Next(context.Background()); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF after Exit, got %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("Recorder: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestRecordReplay(t *testing.T) {
+	data := record(t)
+
+	if strings.Contains(string(data), "sk-ant-") {
+		t.Fatalf("expected the API key to be redacted:\n%s", data)
+	}
+
+	c, err := Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Frames[0].Event != EventStart || len(c.Args()) == 0 {
+		t.Errorf("expected a start frame with args, got %+v", c.Frames[0])
+	}
+	var stdin, stdout int
+	for _, f := range c.Frames {
+		switch {
+		case f.Event != "":
+		case f.Direction == DirectionStdin:
+			stdin++
+		case f.Direction == DirectionStdout:
+			stdout++
+		}
+	}
+	// initialize, user message and the permission response; the initialize
+	// response, init, tool use, permission request, assistant and result.
+	if stdin != 3 || stdout != 6 {
+		t.Errorf("expected 3 stdin and 6 stdout frames, got %d and %d", stdin, stdout)
+	}
+
+	for _, mode := range []MatchMode{MatchStrict, MatchLenient} {
+		t.Run(string(mode), func(t *testing.T) {
+			calls := 0
+			opts := permissionOptions(&calls)
+			opts.TransportFactory = c.Factory(&ReplayOptions{Match: mode})
+			q, err := claude.QueryFunc(secretPrompt, opts)
+			if err != nil {
+				t.Fatalf("QueryFunc: %v", err)
+			}
+			defer q.Close()
+
+			types, err := collect(t, q)
+			if err != nil {
+				t.Fatalf("replay: %v", err)
+			}
+			if want := []string{"system", "assistant", "assistant", "result"}; strings.Join(types, ",") != strings.Join(want, ",") {
+				t.Errorf("expected %v, got %v", want, types)
+			}
+			if calls != 1 {
+				t.Errorf("expected CanUseTool to be called once, got %d", calls)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := q.Next(ctx); !errors.Is(err, io.EOF) {
+				t.Errorf("expected io.EOF at the end of the cassette, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReplay_StrictMismatch(t *testing.T) {
+	c, err := Load(bytes.NewReader(record(t)))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	calls := 0
+	opts := permissionOptions(&calls)
+	opts.TransportFactory = c.Factory(&ReplayOptions{Match: MatchStrict})
+	q, err := claude.QueryFunc("something else", opts)
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	_, err = collect(t, q)
+	var protoErr *clauderrs.ProtocolError
+	if !errors.As(err, &protoErr) || !strings.Contains(err.Error(), "cassette mismatch") {
+		t.Fatalf("expected a cassette mismatch, got %v", err)
+	}
+
+	// Lenient matching only compares message types.
+	opts = permissionOptions(&calls)
+	opts.TransportFactory = c.Factory(nil)
+	q, err = claude.QueryFunc("something else", opts)
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	if _, err := collect(t, q); err != nil {
+		t.Fatalf("lenient replay: %v", err)
+	}
+}
+
+func TestReplay_Realtime(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	frames := []Frame{
+		{Time: start, Event: EventStart},
+		{Time: start, Direction: DirectionStdin, Data: json.RawMessage(`{"type":"user","message":{"role":"user","content":[]}}`)},
+		{Time: start.Add(200 * time.Millisecond), Direction: DirectionStdout,
+			Data: json.RawMessage(`{"type":"result","subtype":"success","session_id":"s","num_turns":1,"result":"ok"}`)},
+		{Time: start.Add(200 * time.Millisecond), Direction: DirectionStdout, Event: EventEOF},
+	}
+
+	for _, realtime := range []bool{false, true} {
+		c := &Cassette{Frames: frames}
+		q, err := claude.QueryFunc("hi", &claude.Options{
+			TransportFactory: c.Factory(&ReplayOptions{Realtime: realtime}),
+		})
+		if err != nil {
+			t.Fatalf("QueryFunc: %v", err)
+		}
+
+		began := time.Now()
+		if _, err := collect(t, q); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		elapsed := time.Since(began)
+		_ = q.Close()
+
+		if realtime && elapsed < 150*time.Millisecond {
+			t.Errorf("expected realtime replay to take about 200ms, took %v", elapsed)
+		}
+		if !realtime && elapsed > 150*time.Millisecond {
+			t.Errorf("expected fast replay, took %v", elapsed)
+		}
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	r := newRedactor(nil)
+
+	got := string(r.json([]byte(`{"env":{"API_KEY":"abc","token":"xyz"},"text":"Authorization: Bearer abc.def","n":1}`)))
+	for _, secret := range []string{"abc", "xyz"} {
+		if strings.Contains(got, `"`+secret+`"`) || strings.Contains(got, "abc.def") {
+			t.Errorf("expected %q to be redacted, got %s", secret, got)
+		}
+	}
+	if !strings.Contains(got, `"n":1`) {
+		t.Errorf("expected other fields kept, got %s", got)
+	}
+
+	clean := []byte(`{"type":"user","n":1}`)
+	if out := r.json(clean); string(out) != string(clean) {
+		t.Errorf("expected frames without secrets unchanged, got %s", out)
+	}
+
+	if got := newRedactor(&RecordOptions{RedactKeys: []string{}, RedactPatterns: nil}).json([]byte(`{"token":"x"}`)); string(got) != `{"token":"x"}` {
+		t.Errorf("expected key redaction disabled, got %s", got)
+	}
+}
+
+func TestRedactor_KeepsLargeIntegers(t *testing.T) {
+	r := newRedactor(nil)
+
+	// 2^53 + 1 cannot be represented as a float64.
+	got := string(r.json([]byte(`{"token":"xyz","id":9007199254740993,"ratio":0.25}`)))
+	want := `{"id":9007199254740993,"ratio":0.25,"token":"` + Redacted + `"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if got := r.json([]byte(`{"token":"x"} trailing`)); strings.Contains(string(got), Redacted) {
+		t.Errorf("expected data after the value to be treated as text, got %s", got)
+	}
+}
@@ -0,0 +1,158 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	claude "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+)
+
+// RecordOptions configures recording.
+type RecordOptions struct {
+	// RedactKeys lists JSON object keys, matched case-insensitively, whose
+	// string values are replaced with Redacted. Nil uses DefaultRedactKeys;
+	// an empty slice disables key redaction.
+	RedactKeys []string
+	// RedactPatterns are replaced with Redacted in every string, including
+	// the CLI arguments. Nil uses DefaultRedactPatterns; an empty slice
+	// disables pattern redaction.
+	RedactPatterns []*regexp.Regexp
+}
+
+// Recorder writes the frames of a session to a cassette as they happen, so a
+// crashed session still leaves a usable recording.
+type Recorder struct {
+	redact *redactor
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a Recorder writing JSONL frames to w.
+func NewRecorder(w io.Writer, opts *RecordOptions) *Recorder {
+	return &Recorder{
+		redact: newRedactor(opts),
+		enc:    json.NewEncoder(w),
+	}
+}
+
+// Factory wraps inner so every transport it creates is recorded. A nil inner
+// uses claude.DefaultTransportFactory.
+func (r *Recorder) Factory(inner claude.TransportFactory) claude.TransportFactory {
+	if inner == nil {
+		inner = claude.DefaultTransportFactory
+	}
+
+	return func(ctx context.Context, config *claude.TransportConfig) (claude.Transport, error) {
+		t, err := inner(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+
+		args := make([]string, len(config.Args))
+		for i, arg := range config.Args {
+			args[i] = r.redact.text(arg)
+		}
+		r.write(Frame{Event: EventStart, Args: args})
+
+		return &recordingTransport{inner: t, rec: r}, nil
+	}
+}
+
+// Err returns the first error writing the cassette.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// record writes a message frame.
+func (r *Recorder) record(dir Direction, data []byte) {
+	data = bytes.TrimRight(data, "\r\n")
+	f := Frame{Direction: dir}
+	if json.Valid(data) {
+		f.Data = r.redact.json(data)
+	} else {
+		f.Text = r.redact.text(string(data))
+	}
+	r.write(f)
+}
+
+func (r *Recorder) write(f Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	f.Time = time.Now()
+	r.err = r.enc.Encode(f)
+}
+
+// recordingTransport records the traffic of another transport.
+type recordingTransport struct {
+	inner claude.Transport
+	rec   *Recorder
+
+	eofOnce   sync.Once
+	closeOnce sync.Once
+}
+
+func (t *recordingTransport) Read(ctx context.Context) ([]byte, error) {
+	data, err := t.inner.Read(ctx)
+	switch {
+	case err == nil:
+		t.rec.record(DirectionStdout, data)
+	case err == io.EOF:
+		t.eofOnce.Do(func() {
+			t.rec.write(Frame{Direction: DirectionStdout, Event: EventEOF})
+		})
+	}
+
+	return data, err
+}
+
+func (t *recordingTransport) Write(ctx context.Context, data []byte) error {
+	err := t.inner.Write(ctx, data)
+	if err == nil {
+		t.rec.record(DirectionStdin, data)
+	}
+
+	return err
+}
+
+func (t *recordingTransport) CloseInput() error {
+	t.closeOnce.Do(func() {
+		t.rec.write(Frame{Direction: DirectionStdin, Event: EventCloseInput})
+	})
+
+	return t.inner.CloseInput()
+}
+
+func (t *recordingTransport) Close() error {
+	return t.inner.Close()
+}
+
+// Wait reports the wrapped process's exit, if it is one.
+func (t *recordingTransport) Wait(ctx context.Context) error {
+	if proc, ok := t.inner.(claude.ProcessTransport); ok {
+		return proc.Wait(ctx)
+	}
+
+	return nil
+}
+
+func (t *recordingTransport) TransportStats() claude.TransportStats {
+	if provider, ok := t.inner.(claude.TransportStatsProvider); ok {
+		return provider.TransportStats()
+	}
+
+	return claude.TransportStats{}
+}
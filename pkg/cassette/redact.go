@@ -0,0 +1,140 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secrets in recorded frames.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys are the JSON keys whose string values are redacted when
+// RecordOptions.RedactKeys is nil.
+var DefaultRedactKeys = []string{
+	"api_key",
+	"apiKey",
+	"authorization",
+	"password",
+	"secret",
+	"token",
+	"access_token",
+	"refresh_token",
+}
+
+// DefaultRedactPatterns are redacted from every string when
+// RecordOptions.RedactPatterns is nil: Anthropic API keys and bearer tokens.
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]+`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=\-]+`),
+}
+
+// redactor removes secrets from frames.
+type redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(opts *RecordOptions) *redactor {
+	keys, patterns := DefaultRedactKeys, DefaultRedactPatterns
+	if opts != nil {
+		if opts.RedactKeys != nil {
+			keys = opts.RedactKeys
+		}
+		if opts.RedactPatterns != nil {
+			patterns = opts.RedactPatterns
+		}
+	}
+
+	r := &redactor{keys: make(map[string]bool, len(keys)), patterns: patterns}
+	for _, key := range keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+
+	return r
+}
+
+// json returns data with secrets redacted, or data itself if it holds none.
+func (r *redactor) json(data []byte) []byte {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return []byte(r.text(string(data)))
+	}
+
+	v, changed := r.value(v)
+	if !changed {
+		return data
+	}
+
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+
+	return redacted
+}
+
+// decodeJSON decodes a single JSON value, keeping numbers as json.Number so
+// integers above 2^53 survive re-encoding unchanged.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	return v, nil
+}
+
+func (r *redactor) text(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, Redacted)
+	}
+
+	return s
+}
+
+func (r *redactor) value(v any) (any, bool) {
+	switch v := v.(type) {
+	case string:
+		redacted := r.text(v)
+
+		return redacted, redacted != v
+	case []any:
+		changed := false
+		for i, item := range v {
+			var c bool
+			v[i], c = r.value(item)
+			changed = changed || c
+		}
+
+		return v, changed
+	case map[string]any:
+		changed := false
+		for key, item := range v {
+			if s, ok := item.(string); ok && r.keys[strings.ToLower(key)] {
+				if s != Redacted {
+					v[key] = Redacted
+					changed = true
+				}
+
+				continue
+			}
+			var c bool
+			v[key], c = r.value(item)
+			changed = changed || c
+		}
+
+		return v, changed
+	}
+
+	return v, false
+}
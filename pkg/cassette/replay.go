@@ -0,0 +1,399 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	claude "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// MatchMode selects how frames written by the SDK are matched against the
+// recording during replay.
+type MatchMode string
+
+const (
+	// MatchLenient matches frames by message type and control subtype only.
+	// Frames the recording does not expect are ignored, and recorded frames
+	// the SDK never sends are skipped once it closes its input. This is the
+	// default.
+	MatchLenient MatchMode = "lenient"
+	// MatchStrict requires every frame to equal the recording, ignoring
+	// UUIDs, session IDs and control request IDs, and in the same order. A
+	// mismatch ends the stream with a *clauderrs.ProtocolError.
+	MatchStrict MatchMode = "strict"
+)
+
+// ReplayOptions configures replay.
+type ReplayOptions struct {
+	// Match is the matching mode. Empty uses MatchLenient.
+	Match MatchMode
+	// Realtime reproduces the recorded delays between frames. By default
+	// frames are played back as fast as the SDK reads them.
+	Realtime bool
+	// Redact is the redaction used when recording. Strict matching applies
+	// it to outgoing frames before comparing them. Nil uses the defaults.
+	Redact *RecordOptions
+}
+
+// errReplayClosed is returned by writes after the transport was closed.
+var errReplayClosed = errors.New("cassette: transport closed")
+
+// Factory returns a transport factory that replays the cassette. Every
+// transport it creates plays the cassette from the start.
+func (c *Cassette) Factory(opts *ReplayOptions) claude.TransportFactory {
+	var o ReplayOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Match == "" {
+		o.Match = MatchLenient
+	}
+
+	return func(context.Context, *claude.TransportConfig) (claude.Transport, error) {
+		p := &player{
+			frames:  c.Frames,
+			opts:    o,
+			redact:  newRedactor(o.Redact),
+			out:     make(chan []byte),
+			closed:  make(chan struct{}),
+			done:    make(chan struct{}),
+			notify:  make(chan struct{}),
+			ids:     make(map[string]string),
+			started: time.Now(),
+		}
+		go p.play()
+
+		return p, nil
+	}
+}
+
+// player is a replaying transport.
+type player struct {
+	frames []Frame
+	opts   ReplayOptions
+	redact *redactor
+	out    chan []byte
+	// closed is closed by Close; done when playback has ended.
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+
+	// Realtime anchor: the recorded time that corresponds to wall time
+	// started.
+	started  time.Time
+	recorded time.Time
+
+	mu          sync.Mutex
+	writes      [][]byte
+	inputClosed bool
+	notify      chan struct{}
+	// ids maps recorded control request IDs to the SDK's IDs on replay.
+	ids map[string]string
+}
+
+// play walks the cassette, emitting CLI frames and matching SDK frames.
+func (p *player) play() {
+	for i, f := range p.frames {
+		var err error
+		switch {
+		case f.Event == EventStart:
+			p.anchor(f.Time)
+		case f.Event == EventEOF:
+			p.finish(nil)
+
+			return
+		case f.Event == EventCloseInput:
+			if p.opts.Match == MatchStrict {
+				err = p.expectCloseInput(i)
+			}
+		case f.Direction == DirectionStdout:
+			err = p.emit(f)
+		case f.Direction == DirectionStdin:
+			if err = p.expect(i, f); err == nil && !f.Time.IsZero() {
+				// Delays are measured from when the SDK actually sent the
+				// frame, as the CLI's were.
+				p.anchor(f.Time)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, errReplayClosed) {
+				err = nil
+			}
+			p.finish(err)
+
+			return
+		}
+	}
+
+	// The recording ended without the CLI exiting; wait for Close.
+	<-p.closed
+	p.finish(nil)
+}
+
+func (p *player) finish(err error) {
+	p.err = err
+	close(p.done)
+}
+
+func (p *player) anchor(recorded time.Time) {
+	p.started, p.recorded = time.Now(), recorded
+}
+
+// emit sends a CLI frame to the SDK, after its recorded delay in realtime
+// mode.
+func (p *player) emit(f Frame) error {
+	if p.opts.Realtime && !p.recorded.IsZero() && !f.Time.IsZero() {
+		delay := time.Until(p.started.Add(f.Time.Sub(p.recorded)))
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-p.closed:
+				timer.Stop()
+
+				return errReplayClosed
+			}
+		}
+	}
+
+	var line []byte
+	if f.Data != nil {
+		line = p.remapResponse(f.Data)
+	} else {
+		line = []byte(f.Text)
+	}
+	line = append(line, '\n')
+
+	select {
+	case p.out <- line:
+		return nil
+	case <-p.closed:
+		return errReplayClosed
+	}
+}
+
+// remapResponse rewrites the request_id of a recorded control response to
+// the ID the SDK used on replay.
+func (p *player) remapResponse(data json.RawMessage) []byte {
+	if !strings.HasPrefix(frameKind(data), "control_response:") {
+		return data
+	}
+
+	var msg map[string]any
+	if json.Unmarshal(data, &msg) != nil {
+		return data
+	}
+	response, _ := msg["response"].(map[string]any)
+	recordedID, _ := response["request_id"].(string)
+
+	p.mu.Lock()
+	id, ok := p.ids[recordedID]
+	p.mu.Unlock()
+	if !ok {
+		return data
+	}
+
+	response["request_id"] = id
+	remapped, err := json.Marshal(msg)
+	if err != nil {
+		return data
+	}
+
+	return remapped
+}
+
+// expect waits for the SDK to write the recorded frame f.
+func (p *player) expect(index int, f Frame) error {
+	for {
+		written, ok, err := p.nextWrite()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Input closed with nothing left to match.
+			if p.opts.Match == MatchStrict {
+				return p.mismatch(index, f, nil)
+			}
+
+			return nil
+		}
+
+		if p.opts.Match == MatchStrict {
+			if !equalFrames(f.Data, p.redact.json(written)) {
+				return p.mismatch(index, f, written)
+			}
+		} else if frameKind(f.Data) != frameKind(written) {
+			continue
+		}
+
+		p.mapRequestID(f.Data, written)
+
+		return nil
+	}
+}
+
+// expectCloseInput waits for the SDK to close its input.
+func (p *player) expectCloseInput(index int) error {
+	written, ok, err := p.nextWrite()
+	if err != nil {
+		return err
+	}
+	if ok {
+		return p.mismatch(index, Frame{Event: EventCloseInput}, written)
+	}
+
+	return nil
+}
+
+// nextWrite returns the oldest unmatched SDK frame. ok is false once the
+// input is closed and every frame has been taken.
+func (p *player) nextWrite() (data []byte, ok bool, err error) {
+	for {
+		p.mu.Lock()
+		if len(p.writes) > 0 {
+			data = p.writes[0]
+			p.writes = p.writes[1:]
+			p.mu.Unlock()
+
+			return data, true, nil
+		}
+		inputClosed, notify := p.inputClosed, p.notify
+		p.mu.Unlock()
+
+		if inputClosed {
+			return nil, false, nil
+		}
+
+		select {
+		case <-notify:
+		case <-p.closed:
+			return nil, false, errReplayClosed
+		}
+	}
+}
+
+func (p *player) mismatch(index int, f Frame, written []byte) error {
+	expected := string(f.Data)
+	if f.Event != "" {
+		expected = string(f.Event)
+	}
+	got := string(written)
+	if written == nil {
+		got = string(EventCloseInput)
+	}
+
+	return clauderrs.NewProtocolError(
+		clauderrs.ErrCodeProtocolError,
+		fmt.Sprintf("cassette mismatch at frame %d: expected %s, got %s", index+1, expected, got),
+		nil,
+	).
+		WithMessageType(frameKind(f.Data))
+}
+
+// mapRequestID remembers the SDK's ID for a recorded control request.
+func (p *player) mapRequestID(recorded, written []byte) {
+	var r, w struct {
+		Type      string `json:"type"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(recorded, &r) != nil || json.Unmarshal(written, &w) != nil {
+		return
+	}
+	if r.Type != "control_request" || r.RequestID == "" || w.RequestID == "" {
+		return
+	}
+
+	p.mu.Lock()
+	p.ids[r.RequestID] = w.RequestID
+	p.mu.Unlock()
+}
+
+// equalFrames compares two messages, ignoring fields that differ between
+// runs.
+func equalFrames(a, b []byte) bool {
+	var x, y map[string]any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return string(a) == string(b)
+	}
+
+	return reflect.DeepEqual(normalize(x), normalize(y))
+}
+
+func normalize(msg map[string]any) map[string]any {
+	delete(msg, "uuid")
+	delete(msg, "session_id")
+	if msg["type"] == "control_request" {
+		delete(msg, "request_id")
+	}
+
+	return msg
+}
+
+func (p *player) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case line := <-p.out:
+		return line, nil
+	case <-p.done:
+		if p.err != nil {
+			return nil, p.err
+		}
+
+		return nil, io.EOF
+	case <-p.closed:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *player) Write(_ context.Context, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.closed:
+		return errReplayClosed
+	default:
+	}
+	if p.inputClosed {
+		return errors.New("cassette: input closed")
+	}
+
+	p.writes = append(p.writes, append([]byte(nil), data...))
+	p.signal()
+
+	return nil
+}
+
+func (p *player) CloseInput() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.inputClosed = true
+	p.signal()
+
+	return nil
+}
+
+func (p *player) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
+
+	return nil
+}
+
+// signal wakes waiters. Callers hold p.mu.
+func (p *player) signal() {
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
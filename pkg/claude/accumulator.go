@@ -0,0 +1,473 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// AccumulatorEventType identifies a change applied by a MessageAccumulator.
+type AccumulatorEventType string
+
+const (
+	// AccumulatorMessageStarted is emitted on message_start.
+	AccumulatorMessageStarted AccumulatorEventType = "message_started"
+	// AccumulatorTextAppended is emitted for every text delta.
+	AccumulatorTextAppended AccumulatorEventType = "text_appended"
+	// AccumulatorThinkingAppended is emitted for every thinking delta.
+	AccumulatorThinkingAppended AccumulatorEventType = "thinking_appended"
+	// AccumulatorToolCallStarted is emitted when a tool_use or
+	// server_tool_use block starts.
+	AccumulatorToolCallStarted AccumulatorEventType = "tool_call_started"
+	// AccumulatorToolInputDelta is emitted for every tool input fragment.
+	AccumulatorToolInputDelta AccumulatorEventType = "tool_input_delta"
+	// AccumulatorToolInputComplete is emitted when a tool block stops and
+	// its input has been parsed.
+	AccumulatorToolInputComplete AccumulatorEventType = "tool_input_complete"
+	// AccumulatorMessageCompleted is emitted on message_stop.
+	AccumulatorMessageCompleted AccumulatorEventType = "message_completed"
+)
+
+// AccumulatorEvent describes a change applied by a MessageAccumulator.
+type AccumulatorEvent struct {
+	Type AccumulatorEventType
+	// ParentToolUseID identifies the subagent the message belongs to, or nil
+	// for the main agent.
+	ParentToolUseID *string
+	// MessageID is the ID of the message being assembled.
+	MessageID string
+	// Index is the content block index; -1 for message-level events.
+	Index int
+	// Delta holds the appended text, thinking or tool input fragment.
+	Delta string
+	// ToolUseID and ToolName are set for tool events.
+	ToolUseID string
+	ToolName  string
+	// Input is the parsed tool input for AccumulatorToolInputComplete.
+	Input JSONValue
+	// Err is set for AccumulatorToolInputComplete when the streamed input
+	// was not valid JSON. Input then holds the best-effort repair.
+	Err error
+}
+
+// MessageAccumulator reassembles assistant messages from SDKStreamEvent
+// deltas (see Options.IncludePartialMessages).
+//
+// Messages are tracked per parent tool use ID, so main-agent and subagent
+// streams that interleave are assembled independently. Snapshot returns the
+// message as received so far; tool inputs that are still streaming are
+// repaired into valid JSON for the snapshot.
+//
+// A MessageAccumulator is safe for concurrent use. The event callback is
+// invoked synchronously after the event has been applied, so it may call
+// Snapshot to render the updated message.
+type MessageAccumulator struct {
+	mu       sync.Mutex
+	messages map[string]*partialMessage
+	onEvent  func(AccumulatorEvent)
+}
+
+type partialMessage struct {
+	message APIAssistantMessage // Content is rebuilt from blocks
+	blocks  []*partialBlock
+	done    bool
+}
+
+type partialBlock struct {
+	block     ContentBlock
+	text      strings.Builder
+	thinking  strings.Builder
+	input     strings.Builder
+	signature string
+	citations []Citation
+	// parsedInput is set once a tool block stops with valid JSON.
+	parsedInput JSONValue
+	stopped     bool
+}
+
+// NewMessageAccumulator creates an accumulator. onEvent may be nil.
+func NewMessageAccumulator(onEvent func(AccumulatorEvent)) *MessageAccumulator {
+	return &MessageAccumulator{
+		messages: make(map[string]*partialMessage),
+		onEvent:  onEvent,
+	}
+}
+
+// Observe feeds a message from the query stream into the accumulator.
+// Messages other than *SDKStreamEvent are ignored.
+func (a *MessageAccumulator) Observe(msg SDKMessage) {
+	evt, ok := msg.(*SDKStreamEvent)
+	if !ok || evt.Event == nil {
+		return
+	}
+
+	a.mu.Lock()
+	events := a.apply(evt.ParentToolUseID, evt.Event)
+	a.mu.Unlock()
+
+	if a.onEvent == nil {
+		return
+	}
+	for _, e := range events {
+		a.onEvent(e)
+	}
+}
+
+// Snapshot returns the message being assembled for the given parent tool use
+// ID (nil for the main agent). The second return value is false when no
+// message has started for that stream.
+func (a *MessageAccumulator) Snapshot(parentToolUseID *string) (APIAssistantMessage, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pm, ok := a.messages[accumulatorKey(parentToolUseID)]
+	if !ok {
+		return APIAssistantMessage{}, false
+	}
+
+	return pm.snapshot(), true
+}
+
+// Done reports whether the message for the given parent tool use ID has
+// received message_stop.
+func (a *MessageAccumulator) Done(parentToolUseID *string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pm, ok := a.messages[accumulatorKey(parentToolUseID)]
+
+	return ok && pm.done
+}
+
+func accumulatorKey(parentToolUseID *string) string {
+	if parentToolUseID == nil {
+		return ""
+	}
+
+	return *parentToolUseID
+}
+
+func (a *MessageAccumulator) apply(parent *string, raw RawMessageStreamEvent) []AccumulatorEvent {
+	key := accumulatorKey(parent)
+	base := AccumulatorEvent{ParentToolUseID: parent, Index: -1}
+
+	if start, ok := raw.(MessageStartEvent); ok {
+		msg := start.Message
+		msg.Content = nil
+		a.messages[key] = &partialMessage{message: msg}
+		base.Type = AccumulatorMessageStarted
+		base.MessageID = msg.ID
+
+		return []AccumulatorEvent{base}
+	}
+
+	pm, ok := a.messages[key]
+	if !ok {
+		// Deltas without message_start cannot be placed; ignore them.
+		return nil
+	}
+	base.MessageID = pm.message.ID
+
+	switch evt := raw.(type) {
+	case ContentBlockStartEvent:
+		return pm.startBlock(base, evt)
+	case ContentBlockDeltaEvent:
+		return pm.applyDelta(base, evt)
+	case ContentBlockStopEvent:
+		return pm.stopBlock(base, evt.Index)
+	case MessageDeltaEvent:
+		if evt.Delta.StopReason != nil {
+			pm.message.StopReason = evt.Delta.StopReason
+		}
+		if evt.Delta.StopSequence != nil {
+			pm.message.StopSequence = evt.Delta.StopSequence
+		}
+		if evt.Usage != nil {
+			mergeStreamUsage(&pm.message.Usage, *evt.Usage)
+		}
+	case MessageStopEvent:
+		pm.done = true
+		base.Type = AccumulatorMessageCompleted
+
+		return []AccumulatorEvent{base}
+	}
+
+	return nil
+}
+
+func (pm *partialMessage) block(index int) *partialBlock {
+	if index < 0 {
+		return nil
+	}
+	for len(pm.blocks) <= index {
+		pm.blocks = append(pm.blocks, nil)
+	}
+	if pm.blocks[index] == nil {
+		pm.blocks[index] = &partialBlock{}
+	}
+
+	return pm.blocks[index]
+}
+
+func (pm *partialMessage) startBlock(base AccumulatorEvent, evt ContentBlockStartEvent) []AccumulatorEvent {
+	b := pm.block(evt.Index)
+	if b == nil {
+		return nil
+	}
+	b.block = evt.ContentBlock
+	base.Index = evt.Index
+
+	switch block := evt.ContentBlock.(type) {
+	case TextContentBlock:
+		b.text.WriteString(block.Text)
+		b.citations = append(b.citations, block.Citations...)
+	case ThinkingBlock:
+		b.thinking.WriteString(block.Thinking)
+		b.signature = block.Signature
+	case ToolUseContentBlock:
+		base.Type = AccumulatorToolCallStarted
+		base.ToolUseID = block.ID
+		base.ToolName = block.Name
+
+		return []AccumulatorEvent{base}
+	case ServerToolUseBlock:
+		base.Type = AccumulatorToolCallStarted
+		base.ToolUseID = block.ID
+		base.ToolName = block.Name
+
+		return []AccumulatorEvent{base}
+	}
+
+	return nil
+}
+
+func (pm *partialMessage) applyDelta(base AccumulatorEvent, evt ContentBlockDeltaEvent) []AccumulatorEvent {
+	b := pm.block(evt.Index)
+	if b == nil {
+		return nil
+	}
+	base.Index = evt.Index
+	d := evt.Delta
+
+	switch {
+	case d.TextDelta != nil:
+		b.text.WriteString(*d.TextDelta)
+		base.Type = AccumulatorTextAppended
+		base.Delta = *d.TextDelta
+	case d.Thinking != nil:
+		b.thinking.WriteString(*d.Thinking)
+		base.Type = AccumulatorThinkingAppended
+		base.Delta = *d.Thinking
+	case d.Signature != nil:
+		b.signature = *d.Signature
+
+		return nil
+	case d.Citation != nil:
+		b.citations = append(b.citations, *d.Citation)
+
+		return nil
+	case d.PartialJSON != nil:
+		b.input.WriteString(*d.PartialJSON)
+		base.Type = AccumulatorToolInputDelta
+		base.Delta = *d.PartialJSON
+		base.ToolUseID, base.ToolName = toolIdentity(b.block)
+	default:
+		return nil
+	}
+
+	return []AccumulatorEvent{base}
+}
+
+func (pm *partialMessage) stopBlock(base AccumulatorEvent, index int) []AccumulatorEvent {
+	b := pm.block(index)
+	if b == nil || b.stopped {
+		return nil
+	}
+	b.stopped = true
+
+	id, name := toolIdentity(b.block)
+	if id == "" {
+		return nil
+	}
+
+	base.Type = AccumulatorToolInputComplete
+	base.Index = index
+	base.ToolUseID = id
+	base.ToolName = name
+
+	input := strings.TrimSpace(b.input.String())
+	switch {
+	case input == "":
+		b.parsedInput = startInput(b.block)
+	case json.Valid([]byte(input)):
+		b.parsedInput = JSONValue(input)
+	default:
+		var v any
+		base.Err = json.Unmarshal([]byte(input), &v)
+		b.parsedInput = repairPartialJSON(input)
+	}
+	base.Input = b.parsedInput
+
+	return []AccumulatorEvent{base}
+}
+
+// snapshot materializes the partial message.
+func (pm *partialMessage) snapshot() APIAssistantMessage {
+	msg := pm.message
+	msg.Content = make([]ContentBlock, 0, len(pm.blocks))
+
+	for _, b := range pm.blocks {
+		if b == nil || b.block == nil {
+			continue
+		}
+		msg.Content = append(msg.Content, b.materialize())
+	}
+
+	return msg
+}
+
+func (b *partialBlock) materialize() ContentBlock {
+	switch block := b.block.(type) {
+	case TextContentBlock:
+		block.Text = b.text.String()
+		block.Citations = append([]Citation(nil), b.citations...)
+
+		return block
+	case ThinkingBlock:
+		block.Thinking = b.thinking.String()
+		block.Signature = b.signature
+
+		return block
+	case ToolUseContentBlock:
+		block.Input = b.currentInput()
+
+		return block
+	case ServerToolUseBlock:
+		block.Input = b.currentInput()
+
+		return block
+	default:
+		return b.block
+	}
+}
+
+func (b *partialBlock) currentInput() JSONValue {
+	if b.parsedInput != nil {
+		return b.parsedInput
+	}
+	if b.input.Len() == 0 {
+		return startInput(b.block)
+	}
+
+	return repairPartialJSON(b.input.String())
+}
+
+// startInput returns the input carried by content_block_start, which the API
+// sends as an empty object before streaming the real input.
+func startInput(block ContentBlock) JSONValue {
+	var input JSONValue
+	switch b := block.(type) {
+	case ToolUseContentBlock:
+		input = b.Input
+	case ServerToolUseBlock:
+		input = b.Input
+	}
+	if len(input) == 0 {
+		return JSONValue("{}")
+	}
+
+	return input
+}
+
+func toolIdentity(block ContentBlock) (id, name string) {
+	switch b := block.(type) {
+	case ToolUseContentBlock:
+		return b.ID, b.Name
+	case ServerToolUseBlock:
+		return b.ID, b.Name
+	}
+
+	return "", ""
+}
+
+// mergeStreamUsage applies message_delta usage, whose counts are cumulative
+// for the message.
+func mergeStreamUsage(dst *Usage, u Usage) {
+	if u.InputTokens > 0 {
+		dst.InputTokens = u.InputTokens
+	}
+	if u.OutputTokens > 0 {
+		dst.OutputTokens = u.OutputTokens
+	}
+	if u.CacheReadInputTokens > 0 {
+		dst.CacheReadInputTokens = u.CacheReadInputTokens
+	}
+	if u.CacheCreationInputTokens > 0 {
+		dst.CacheCreationInputTokens = u.CacheCreationInputTokens
+	}
+}
+
+// repairPartialJSON turns a truncated JSON document into valid JSON by
+// closing open strings, arrays and objects. Trailing fragments that cannot be
+// completed (a dangling key, colon or partial literal) are dropped. It
+// returns "{}" when nothing can be salvaged.
+func repairPartialJSON(partial string) JSONValue {
+	for end := len(partial); end > 0; end-- {
+		candidate := closePartialJSON(partial[:end])
+		if candidate != "" && json.Valid([]byte(candidate)) {
+			return JSONValue(candidate)
+		}
+	}
+
+	return JSONValue("{}")
+}
+
+// closePartialJSON appends the closing quote and brackets a truncated JSON
+// document needs.
+func closePartialJSON(s string) string {
+	var (
+		stack    []byte
+		inString bool
+		escaped  bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(s)
+	if inString {
+		sb.WriteByte('"')
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		sb.WriteByte(stack[i])
+	}
+
+	return sb.String()
+}
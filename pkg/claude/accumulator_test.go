@@ -0,0 +1,27 @@
+package claude
+
+import "testing"
+
+func TestRepairPartialJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{``, `{}`},
+		{`{`, `{}`},
+		{`{"path": "/tmp/fi`, `{"path": "/tmp/fi"}`},
+		{`{"path": "/tmp/file", `, `{"path": "/tmp/file"}`},
+		{`{"path": "/tmp/file", "lim`, `{"path": "/tmp/file"}`},
+		{`{"path": "/tmp/file", "limit":`, `{"path": "/tmp/file"}`},
+		{`{"flag": tr`, `{}`},
+		{`{"items": [1, 2, {"a": "b`, `{"items": [1, 2, {"a": "b"}]}`},
+		{`{"text": "quote \"inner\" and \`, `{"text": "quote \"inner\" and "}`},
+		{`{"done": true}`, `{"done": true}`},
+	}
+
+	for _, tt := range tests {
+		if got := string(repairPartialJSON(tt.in)); got != tt.want {
+			t.Errorf("repairPartialJSON(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package claude
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/internal/transport"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+	"github.com/google/uuid"
+)
+
+// CLICapabilities describes what the installed CLI supports.
+type CLICapabilities struct {
+	// Version is the CLI version, for example "2.0.14".
+	Version string
+	// Flags lists the long flags from the CLI's --help output, sorted. It is
+	// empty when the help output could not be parsed, in which case every
+	// flag is assumed supported.
+	Flags []string
+}
+
+// SupportsFlag reports whether the CLI accepts flag, for example
+// "--include-partial-messages".
+func (c *CLICapabilities) SupportsFlag(flag string) bool {
+	info := transport.CLIInfo{Version: c.Version, Flags: c.Flags}
+
+	return info.HasFlag(flag)
+}
+
+// ProbeCLICapabilities runs the CLI that opts selects (see
+// FindClaudeExecutable) with --version and --help and reports what it
+// supports. The probe runs once per executable; later calls, and queries,
+// reuse the result until the executable changes on disk.
+func ProbeCLICapabilities(opts *Options) (*CLICapabilities, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	found, err := transport.FindExecutable(opts.PathToClaudeCodeExecutable, opts.Cwd)
+	if err != nil {
+		return nil, executableError(err)
+	}
+
+	info, err := transport.ProbeCLI(found.Path, probeConfig(opts))
+	if err != nil {
+		return nil, clauderrs.CreateProcessError(
+			clauderrs.ErrCodeProcessSpawnFailed,
+			"failed to probe Claude Code CLI capabilities",
+			err,
+			0,
+			"",
+		).WithCommand(found.Path)
+	}
+
+	return &CLICapabilities{Version: info.Version, Flags: info.Flags}, nil
+}
+
+// probeConfig is the process configuration a query with opts would use, so
+// the probe runs the same CLI, as the same user, with the same environment.
+func probeConfig(opts *Options) *transport.ProcessConfig {
+	return processConfig(transportConfig(opts, nil))
+}
+
+// optionFlag ties a CLI flag to the option that produces it.
+type optionFlag struct {
+	flag string
+	// option names the Options field, or is empty for the stream-json
+	// protocol flags every query needs.
+	option string
+	set    func(*Options) bool
+	// optional flags are dropped with an SDKWarningMessage when the CLI
+	// lacks them, because the query still works without them; the others
+	// fail the query. Optional flags take no value.
+	optional bool
+}
+
+func always(*Options) bool { return true }
+
+// optionFlags lists every flag buildArgs can pass.
+var optionFlags = []optionFlag{
+	{flag: "--print", set: always},
+	{flag: "--output-format", set: always},
+	{flag: "--input-format", set: always},
+	{flag: "--verbose", set: always},
+	{flag: "--model", option: "Model", set: func(o *Options) bool { return o.Model != "" }},
+	{flag: "--continue", option: "Continue", set: func(o *Options) bool { return o.Continue }},
+	{flag: "--resume", option: "Resume", set: func(o *Options) bool { return o.Resume != "" }},
+	{flag: "--permission-mode", option: "PermissionMode", set: func(o *Options) bool { return o.PermissionMode != "" }},
+	{flag: "--settings", option: "Settings", set: func(o *Options) bool { return o.Settings != "" }},
+	{flag: "--setting-sources", option: "SettingSources", set: always},
+	{flag: "--add-dir", option: "AdditionalDirectories", set: func(o *Options) bool { return len(o.AdditionalDirectories) > 0 }},
+	{flag: "--tools", option: "Tools", set: func(o *Options) bool { return len(o.Tools) > 0 }},
+	{flag: "--allowed-tools", option: "AllowedTools", set: func(o *Options) bool { return len(o.AllowedTools) > 0 }},
+	{flag: "--disallowed-tools", option: "DisallowedTools", set: func(o *Options) bool { return len(o.DisallowedTools) > 0 }},
+	{
+		flag:     "--include-partial-messages",
+		option:   "IncludePartialMessages",
+		set:      func(o *Options) bool { return o.IncludePartialMessages },
+		optional: true,
+	},
+}
+
+// checkCapabilities matches args against what the CLI supports. Optional
+// flags the CLI lacks are removed from args and reported as warnings; any
+// other unsupported flag fails with a ClientError with code
+// clauderrs.ErrCodeUnsupportedOption.
+//
+// The check needs the default transport, and is skipped along with the
+// version check when CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK is "true". When the
+// CLI cannot be probed, args are returned unchanged and starting the process
+// reports the problem.
+func (q *queryImpl) checkCapabilities(args []string) ([]string, []SDKMessage, error) {
+	if q.opts.TransportFactory != nil || strings.EqualFold(os.Getenv(transport.SkipVersionCheckEnvVar), "true") {
+		return args, nil, nil
+	}
+
+	caps, err := ProbeCLICapabilities(q.opts)
+	if err != nil {
+		return args, nil, nil
+	}
+
+	var unsupported []string
+	var warnings []SDKMessage
+	for _, of := range optionFlags {
+		if !of.set(q.opts) || caps.SupportsFlag(of.flag) {
+			continue
+		}
+
+		name := "the stream-json protocol"
+		if of.option != "" {
+			name = "Options." + of.option
+		}
+		if !of.optional {
+			unsupported = append(unsupported, name+" ("+of.flag+")")
+
+			continue
+		}
+
+		args = slices.DeleteFunc(args, func(arg string) bool { return arg == of.flag })
+		warnings = append(warnings, &SDKWarningMessage{
+			BaseMessage: BaseMessage{UUIDField: uuid.New(), SessionIDField: q.sessionID},
+			Option:      of.option,
+			Flag:        of.flag,
+			Message: "Claude Code CLI version " + caps.Version + " does not support " +
+				of.flag + "; " + name + " is ignored",
+		})
+	}
+
+	if len(unsupported) > 0 {
+		return nil, nil, clauderrs.NewUnsupportedOptionsError(caps.Version, unsupported).
+			WithSessionID(q.sessionID)
+	}
+
+	return args, warnings, nil
+}
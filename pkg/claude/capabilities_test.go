@@ -0,0 +1,138 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// oldCLIScript is a CLI whose --help lacks --tools and
+// --include-partial-messages. It records its arguments next to itself and
+// answers each user message with a result.
+const oldCLIScript = `#!/bin/sh
+case "$1" in
+--version) echo "2.0.1 (Claude Code)"; exit 0 ;;
+--help) cat <<'EOF'
+Options:
+  -p, --print
+  --output-format <format>
+  --input-format <format>
+  --verbose
+  --model <model>
+  --setting-sources <sources>
+  --allowed-tools <tools...>
+EOF
+exit 0 ;;
+esac
+echo "$@" > "$(dirname "$0")/args"
+while read -r line; do
+	case "$line" in
+	*'"type":"user"'*)
+		echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":1,"result":"ok"}'
+		;;
+	esac
+done
+`
+
+func TestQuery_UnsupportedOptionFails(t *testing.T) {
+	cli := writeFakeCLI(t, oldCLIScript)
+
+	_, err := QueryFunc("hello", &Options{
+		PathToClaudeCodeExecutable: cli,
+		Tools:                      []string{"Read"},
+	})
+
+	var clientErr *clauderrs.ClientError
+	if !errors.As(err, &clientErr) || clientErr.Code() != clauderrs.ErrCodeUnsupportedOption {
+		t.Fatalf("expected unsupported option error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Options.Tools (--tools)") || !strings.Contains(err.Error(), "2.0.1") {
+		t.Errorf("expected the option, flag and version in %q", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(cli), "args")); err == nil {
+		t.Error("expected the CLI not to be started")
+	}
+}
+
+func TestQuery_UnsupportedOptionalFlagWarns(t *testing.T) {
+	cli := writeFakeCLI(t, oldCLIScript)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	q, err := QueryFunc("hello", &Options{
+		PathToClaudeCodeExecutable: cli,
+		IncludePartialMessages:     true,
+		AllowedTools:               []string{"Read"},
+	})
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	msg, err := q.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	warning, ok := msg.(*SDKWarningMessage)
+	if !ok {
+		t.Fatalf("expected *SDKWarningMessage first, got %T", msg)
+	}
+	if warning.Option != "IncludePartialMessages" || warning.Flag != "--include-partial-messages" {
+		t.Errorf("unexpected warning %+v", warning)
+	}
+	if sessionID := q.(*queryImpl).sessionID; warning.SessionID() != sessionID {
+		t.Errorf("expected the warning in session %q, got %q", sessionID, warning.SessionID())
+	}
+
+	if msg, err = q.Next(ctx); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if _, ok := msg.(*SDKResultMessage); !ok {
+		t.Fatalf("expected the CLI's result next, got %T", msg)
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(cli), "args"))
+	if err != nil {
+		t.Fatalf("read args: %v", err)
+	}
+	args := strings.Fields(string(data))
+	if slices.Contains(args, "--include-partial-messages") || !slices.Contains(args, "--allowed-tools") {
+		t.Errorf("expected only the unsupported flag to be dropped, got %v", args)
+	}
+}
+
+func TestQuery_CapabilityCheckSkipped(t *testing.T) {
+	cli := writeFakeCLI(t, oldCLIScript)
+	t.Setenv("CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK", "true")
+
+	q, err := QueryFunc("hello", &Options{
+		PathToClaudeCodeExecutable: cli,
+		Tools:                      []string{"Read"},
+	})
+	if err != nil {
+		t.Fatalf("expected the check to be skipped, got %v", err)
+	}
+	_ = q.Close()
+}
+
+func TestProbeCLICapabilities(t *testing.T) {
+	cli := writeFakeCLI(t, oldCLIScript)
+
+	caps, err := ProbeCLICapabilities(&Options{PathToClaudeCodeExecutable: cli})
+	if err != nil {
+		t.Fatalf("ProbeCLICapabilities: %v", err)
+	}
+	if caps.Version != "2.0.1" {
+		t.Errorf("expected version 2.0.1, got %q", caps.Version)
+	}
+	if !caps.SupportsFlag("--allowed-tools") || caps.SupportsFlag("--tools") {
+		t.Errorf("unexpected flags %v", caps.Flags)
+	}
+}
@@ -2,6 +2,7 @@ package claude
 
 import (
 	"context"
+	"errors"
 	"io"
 	"sync"
 
@@ -22,6 +23,16 @@ type ClaudeSDKClient struct {
 	query  Query
 	mu     sync.Mutex
 	closed bool
+	// responseErr ended the last ReceiveResponse stream; see ResponseErr.
+	responseErr error
+
+	// Turn dispatch state used by Send (see turn.go).
+	turnsMu        sync.Mutex
+	turns          []*Turn
+	turnCount      int
+	dispatching    bool
+	dispatchErr    error
+	dispatchCancel context.CancelFunc
 }
 
 // NewClient creates a new Claude SDK client.
@@ -50,7 +61,7 @@ func (c *ClaudeSDKClient) Query(ctx context.Context, prompt string) error {
 	}
 
 	if c.query == nil {
-		q, err := QueryFunc(prompt, c.opts)
+		q, err := c.startQuery(prompt)
 		if err != nil {
 			// Preserve and wrap underlying errors from query
 			// creation
@@ -74,6 +85,16 @@ func (c *ClaudeSDKClient) Query(ctx context.Context, prompt string) error {
 	return c.query.SendUserMessage(ctx, prompt)
 }
 
+// startQuery starts the client's query, under the restart supervisor when
+// Options.Restart is set.
+func (c *ClaudeSDKClient) startQuery(prompt string) (Query, error) {
+	if c.opts.Restart != nil {
+		return newSupervisedQuery(prompt, c.opts, QueryFunc)
+	}
+
+	return QueryFunc(prompt, c.opts)
+}
+
 // SendMessage sends a message with structured content blocks to Claude.
 //
 // This is a convenience method for sending complex messages with images, tool
@@ -156,39 +177,98 @@ func (c *ClaudeSDKClient) ReceiveMessages(
 //
 // This is a convenience method for single-response workflows.
 //
-// The channel automatically closes after receiving a result message.
+// The channel automatically closes after receiving a result message. If the
+// stream fails first, the channel closes early and ResponseErr reports why;
+// ReceiveResponseWithErrors delivers the error on a channel instead.
 func (c *ClaudeSDKClient) ReceiveResponse(
 	ctx context.Context,
 ) <-chan SDKMessage {
 	msgChan := make(chan SDKMessage, defaultMessageChannelBuffer)
+	c.setResponseErr(nil)
 
 	go func() {
 		defer close(msgChan)
 
-		if c.query == nil {
-			return
+		c.setResponseErr(c.receiveResponse(ctx, msgChan))
+	}()
+
+	return msgChan
+}
+
+// ReceiveResponseWithErrors is ReceiveResponse with the error that ended the
+// response early, if any, delivered on the error channel. Stream errors keep
+// their typed form, for example *clauderrs.ProcessError.
+func (c *ClaudeSDKClient) ReceiveResponseWithErrors(
+	ctx context.Context,
+) (<-chan SDKMessage, <-chan error) {
+	msgChan := make(chan SDKMessage, defaultMessageChannelBuffer)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(msgChan)
+		defer close(errChan)
+
+		if err := c.receiveResponse(ctx, msgChan); err != nil {
+			errChan <- err
 		}
+	}()
 
-		for {
-			msg, err := c.query.Next(ctx)
-			if err != nil {
-				return
-			}
+	return msgChan, errChan
+}
 
-			select {
-			case msgChan <- msg:
-			case <-ctx.Done():
-				return
-			}
+// ResponseErr returns the error that ended the most recent ReceiveResponse
+// stream before its result message, or nil. It is set by the time that
+// stream's channel is closed.
+func (c *ClaudeSDKClient) ResponseErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-			// Check if this is a result message (end of query)
-			if _, ok := msg.(*SDKResultMessage); ok {
-				return
+	return c.responseErr
+}
+
+func (c *ClaudeSDKClient) setResponseErr(err error) {
+	c.mu.Lock()
+	c.responseErr = err
+	c.mu.Unlock()
+}
+
+// receiveResponse sends the messages of the current response to out, up to
+// and including the result message. It returns the error that ended the
+// response early; a stream that ends without a result is not an error.
+func (c *ClaudeSDKClient) receiveResponse(ctx context.Context, out chan<- SDKMessage) error {
+	c.mu.Lock()
+	q := c.query
+	c.mu.Unlock()
+
+	if q == nil {
+		return clauderrs.NewClientError(
+			clauderrs.ErrCodeNoActiveQuery,
+			errNoActiveQuery,
+			nil,
+		)
+	}
+
+	for {
+		msg, err := q.Next(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
 			}
+
+			return err
 		}
-	}()
 
-	return msgChan
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		// Check if this is a result message (end of query)
+		if _, ok := msg.(*SDKResultMessage); ok {
+			return nil
+		}
+	}
 }
 
 // Interrupt interrupts the current query.
@@ -296,6 +376,23 @@ func (c *ClaudeSDKClient) McpServerStatus(
 	return c.query.McpServerStatus(ctx)
 }
 
+// Compact asks Claude to compact the conversation history, optionally guided
+// by custom instructions.
+func (c *ClaudeSDKClient) Compact(ctx context.Context, instructions string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.query == nil {
+		return clauderrs.NewClientError(
+			clauderrs.ErrCodeNoActiveQuery,
+			errNoActiveQuery,
+			nil,
+		)
+	}
+
+	return compactQuery(ctx, c.query, instructions)
+}
+
 // GetServerInfo returns server information from the query.
 func (c *ClaudeSDKClient) GetServerInfo() (map[string]any, error) {
 	c.mu.Lock()
@@ -312,6 +409,22 @@ func (c *ClaudeSDKClient) GetServerInfo() (map[string]any, error) {
 	return c.query.GetServerInfo()
 }
 
+// TransportStats returns counters for writes to the CLI's stdin.
+func (c *ClaudeSDKClient) TransportStats() (TransportStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.query == nil {
+		return TransportStats{}, clauderrs.NewClientError(
+			clauderrs.ErrCodeNoActiveQuery,
+			errNoActiveQuery,
+			nil,
+		)
+	}
+
+	return queryTransportStats(c.query), nil
+}
+
 // Close closes the client and cleans up resources.
 func (c *ClaudeSDKClient) Close() error {
 	c.mu.Lock()
@@ -323,6 +436,10 @@ func (c *ClaudeSDKClient) Close() error {
 
 	c.closed = true
 
+	if c.dispatchCancel != nil {
+		c.dispatchCancel()
+	}
+
 	if c.query != nil {
 		return c.query.Close()
 	}
@@ -0,0 +1,77 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// stubClientQuery serves a scripted stream to a ClaudeSDKClient.
+type stubClientQuery struct {
+	Query
+	stub *stubSimpleQuery
+}
+
+func (q stubClientQuery) Next(ctx context.Context) (SDKMessage, error) {
+	return q.stub.Next(ctx)
+}
+
+func (q stubClientQuery) Close() error {
+	return q.stub.Close()
+}
+
+func failingResponseClient() (*ClaudeSDKClient, error) {
+	streamErr := clauderrs.NewProcessError(clauderrs.ErrCodeProcessExited, "Claude Code process exited", nil, 2, "")
+	client := &ClaudeSDKClient{opts: &Options{}}
+	client.query = stubClientQuery{stub: &stubSimpleQuery{responses: []stubSimpleQueryResponse{
+		{msg: &SDKAssistantMessage{}},
+		{err: streamErr},
+	}}}
+
+	return client, streamErr
+}
+
+func TestReceiveResponse_ReportsStreamError(t *testing.T) {
+	client, streamErr := failingResponseClient()
+
+	var msgs int
+	for range client.ReceiveResponse(context.Background()) {
+		msgs++
+	}
+
+	if msgs != 1 {
+		t.Fatalf("expected 1 message before the error, got %d", msgs)
+	}
+	var procErr *clauderrs.ProcessError
+	if err := client.ResponseErr(); !errors.As(err, &procErr) || err != error(streamErr) {
+		t.Fatalf("expected the stream's ProcessError, got %v", err)
+	}
+}
+
+func TestReceiveResponseWithErrors_DeliversStreamError(t *testing.T) {
+	client, streamErr := failingResponseClient()
+
+	msgChan, errChan := client.ReceiveResponseWithErrors(context.Background())
+	for range msgChan {
+	}
+
+	if err := <-errChan; err != error(streamErr) {
+		t.Fatalf("expected the stream error, got %v", err)
+	}
+}
+
+func TestReceiveResponse_NoActiveQuery(t *testing.T) {
+	client := &ClaudeSDKClient{opts: &Options{}}
+
+	for range client.ReceiveResponse(context.Background()) {
+		t.Fatal("expected no messages")
+	}
+
+	var clientErr *clauderrs.ClientError
+	if err := client.ResponseErr(); !errors.As(err, &clientErr) ||
+		clientErr.Code() != clauderrs.ErrCodeNoActiveQuery {
+		t.Fatalf("expected no active query error, got %v", err)
+	}
+}
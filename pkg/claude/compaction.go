@@ -0,0 +1,335 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// compactCommand is the slash command that asks the CLI to compact the
+// conversation history.
+const compactCommand = "/compact"
+
+// CompactionOptions configures compaction callbacks and the automatic
+// compaction policy.
+//
+// The callbacks run on their own goroutine, one at a time and in the order
+// the CLI reported the events, so they may block without stalling the
+// message stream.
+//
+// Example:
+//
+//	opts := &claude.Options{
+//	    Compaction: &claude.CompactionOptions{
+//	        AutoCompactThreshold:    0.8,
+//	        AutoCompactInstructions: "Keep the list of modified files",
+//	        OnAfterCompact: func(ctx context.Context, info claude.CompactionInfo) {
+//	            log.Printf("compacted %d tokens (%s)", info.PreTokens, info.Trigger)
+//	        },
+//	    },
+//	}
+type CompactionOptions struct {
+	// OnBeforeCompact is called when the CLI reports that compaction started.
+	// PreTokens holds the SDK's estimate of the current context size.
+	OnBeforeCompact func(ctx context.Context, info CompactionInfo)
+
+	// OnAfterCompact is called when the CLI emits a compact boundary.
+	// PreTokens holds the token count reported by the CLI.
+	OnAfterCompact func(ctx context.Context, info CompactionInfo)
+
+	// AutoCompactThreshold enables automatic compaction when the context in
+	// use reaches this fraction of the model's context window (e.g. 0.8 for
+	// 80%). The check runs after every result message. A value of 0 disables
+	// the policy; values outside (0, 1] are rejected.
+	AutoCompactThreshold float64
+
+	// AutoCompactInstructions are passed to /compact when the automatic
+	// policy triggers.
+	AutoCompactInstructions string
+}
+
+// CompactionInfo describes a compaction for the compaction callbacks.
+type CompactionInfo struct {
+	// Trigger is CompactTriggerManual for compactions requested through
+	// Compactor.Compact and CompactTriggerAuto for those started by
+	// AutoCompactThreshold. Compactions started by the CLI itself report
+	// the CLI's trigger once the boundary is seen.
+	Trigger CompactTrigger
+	// CustomInstructions are the instructions passed to /compact, if any.
+	CustomInstructions string
+	// PreTokens is the number of context tokens before compaction.
+	PreTokens int
+	// ContextWindow is the model's context window, or 0 when not yet known.
+	ContextWindow int
+}
+
+// validateCompactionOptions checks the compaction configuration.
+func validateCompactionOptions(opts *CompactionOptions) error {
+	if opts == nil {
+		return nil
+	}
+
+	if opts.AutoCompactThreshold < 0 || opts.AutoCompactThreshold > 1 {
+		return clauderrs.NewValidationError(
+			clauderrs.ErrCodeRangeViolation,
+			"auto compact threshold must be between 0 and 1",
+			nil,
+			"Compaction.AutoCompactThreshold",
+			opts.AutoCompactThreshold,
+		)
+	}
+
+	return nil
+}
+
+// compactPrompt builds the /compact command text.
+func compactPrompt(instructions string) string {
+	instructions = strings.TrimSpace(instructions)
+	if instructions == "" {
+		return compactCommand
+	}
+
+	return compactCommand + " " + instructions
+}
+
+// compactionMonitor observes the message stream to drive the compaction
+// callbacks and the automatic compaction policy.
+type compactionMonitor struct {
+	opts *CompactionOptions
+	// compact sends a /compact command to the CLI.
+	compact func(ctx context.Context, instructions string) error
+
+	// ctx is canceled by stop, so an automatic /compact that has not been
+	// written yet is dropped when the query closes.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// auto tracks the goroutine sending an automatic /compact.
+	auto sync.WaitGroup
+
+	mu      sync.Mutex
+	stopped bool
+	// pending describes a compaction that was requested or announced but
+	// whose boundary has not been observed yet.
+	pending        *CompactionInfo
+	requestedBySDK bool
+	beforeFired    bool
+	contextTokens  int
+	model          string
+	contextWindow  int
+
+	// callbacks run the user callbacks in order on their own goroutine, so a
+	// slow callback never holds up reading the CLI's output.
+	callbackMu  sync.Mutex
+	callbacks   []func()
+	dispatching bool
+}
+
+func newCompactionMonitor(
+	opts *CompactionOptions,
+	compact func(ctx context.Context, instructions string) error,
+) *compactionMonitor {
+	if opts == nil {
+		opts = &CompactionOptions{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &compactionMonitor{opts: opts, compact: compact, ctx: ctx, cancel: cancel}
+}
+
+// stop keeps the automatic policy from sending /compact and abandons a
+// request that is still queued. Call wait once the transport is closed.
+func (m *compactionMonitor) stop() {
+	m.mu.Lock()
+	m.stopped = true
+	m.mu.Unlock()
+
+	m.cancel()
+}
+
+// wait blocks until an automatic /compact started before stop returns.
+func (m *compactionMonitor) wait() {
+	m.auto.Wait()
+}
+
+// requested records a manual compaction request so the callbacks can report
+// its trigger and instructions.
+func (m *compactionMonitor) requested(trigger CompactTrigger, instructions string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = &CompactionInfo{
+		Trigger:            trigger,
+		CustomInstructions: instructions,
+	}
+	m.requestedBySDK = true
+	m.beforeFired = false
+}
+
+// cancelRequest forgets a compaction request that could not be sent.
+func (m *compactionMonitor) cancelRequest() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = nil
+	m.requestedBySDK = false
+}
+
+// observe inspects a message from the stream. Callbacks and the automatic
+// /compact request run asynchronously; callbacks still run in stream order.
+func (m *compactionMonitor) observe(ctx context.Context, msg SDKMessage) {
+	switch msg := msg.(type) {
+	case *SDKAssistantMessage:
+		m.observeAssistant(msg)
+	case *SDKStatusMessage:
+		if msg.Status == SDKStatusCompacting {
+			m.fireBefore(ctx)
+		}
+	case *SDKCompactBoundaryMessage:
+		m.fireBefore(ctx)
+		m.fireAfter(ctx, msg.CompactMetadata)
+	case *SDKResultMessage:
+		m.observeResult(msg)
+	}
+}
+
+func (m *compactionMonitor) observeAssistant(msg *SDKAssistantMessage) {
+	// Subagents run in their own context window.
+	if msg.ParentToolUseID != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := msg.Message.Usage
+	m.contextTokens = u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens + u.OutputTokens
+	if msg.Message.Model != "" {
+		m.model = msg.Message.Model
+	}
+}
+
+func (m *compactionMonitor) fireBefore(ctx context.Context) {
+	m.mu.Lock()
+	if m.pending == nil {
+		m.pending = &CompactionInfo{Trigger: CompactTriggerAuto}
+	}
+	if m.beforeFired {
+		m.mu.Unlock()
+
+		return
+	}
+	m.beforeFired = true
+	info := *m.pending
+	info.PreTokens = m.contextTokens
+	info.ContextWindow = m.contextWindow
+	m.mu.Unlock()
+
+	if m.opts.OnBeforeCompact != nil {
+		m.dispatch(func() { m.opts.OnBeforeCompact(ctx, info) })
+	}
+}
+
+func (m *compactionMonitor) fireAfter(ctx context.Context, meta CompactMetadata) {
+	m.mu.Lock()
+	info := CompactionInfo{Trigger: CompactTriggerAuto}
+	if m.pending != nil {
+		info = *m.pending
+	}
+	// Compactions the SDK did not request report the CLI's own trigger.
+	if !m.requestedBySDK && meta.Trigger != "" {
+		info.Trigger = CompactTrigger(meta.Trigger)
+	}
+	info.PreTokens = meta.PreTokens
+	info.ContextWindow = m.contextWindow
+	m.pending = nil
+	m.requestedBySDK = false
+	m.beforeFired = false
+	// The context was just replaced by a summary; the next assistant
+	// message reports the new size.
+	m.contextTokens = 0
+	m.mu.Unlock()
+
+	if m.opts.OnAfterCompact != nil {
+		m.dispatch(func() { m.opts.OnAfterCompact(ctx, info) })
+	}
+}
+
+// dispatch queues fn behind any callbacks still running.
+func (m *compactionMonitor) dispatch(fn func()) {
+	m.callbackMu.Lock()
+	m.callbacks = append(m.callbacks, fn)
+	if m.dispatching {
+		m.callbackMu.Unlock()
+
+		return
+	}
+	m.dispatching = true
+	m.callbackMu.Unlock()
+
+	go m.runCallbacks()
+}
+
+func (m *compactionMonitor) runCallbacks() {
+	for {
+		m.callbackMu.Lock()
+		if len(m.callbacks) == 0 {
+			m.dispatching = false
+			m.callbackMu.Unlock()
+
+			return
+		}
+		fn := m.callbacks[0]
+		m.callbacks[0] = nil
+		m.callbacks = m.callbacks[1:]
+		m.callbackMu.Unlock()
+
+		fn()
+	}
+}
+
+func (m *compactionMonitor) observeResult(msg *SDKResultMessage) {
+	m.mu.Lock()
+	if usage, ok := msg.ModelUsage[m.model]; ok && usage.ContextWindow > 0 {
+		m.contextWindow = usage.ContextWindow
+	} else {
+		for _, usage := range msg.ModelUsage {
+			if usage.ContextWindow > m.contextWindow {
+				m.contextWindow = usage.ContextWindow
+			}
+		}
+	}
+
+	threshold := m.opts.AutoCompactThreshold
+	trigger := threshold > 0 &&
+		!m.stopped &&
+		m.compact != nil &&
+		m.pending == nil &&
+		m.contextWindow > 0 &&
+		float64(m.contextTokens) >= threshold*float64(m.contextWindow)
+	if trigger {
+		m.pending = &CompactionInfo{
+			Trigger:            CompactTriggerAuto,
+			CustomInstructions: m.opts.AutoCompactInstructions,
+		}
+		m.requestedBySDK = true
+		m.beforeFired = false
+		// Added under mu so wait sees every goroutine started before stop.
+		m.auto.Add(1)
+	}
+	m.mu.Unlock()
+
+	if !trigger {
+		return
+	}
+
+	go func() {
+		defer m.auto.Done()
+
+		if err := m.compact(m.ctx, m.opts.AutoCompactInstructions); err != nil {
+			m.cancelRequest()
+		}
+	}()
+}
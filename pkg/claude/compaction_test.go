@@ -0,0 +1,233 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestCompactPrompt(t *testing.T) {
+	if got := compactPrompt("  "); got != "/compact" {
+		t.Fatalf("expected bare /compact, got %q", got)
+	}
+	if got := compactPrompt("keep file list"); got != "/compact keep file list" {
+		t.Fatalf("unexpected prompt %q", got)
+	}
+}
+
+func TestCompactionMonitor_ManualCallbacks(t *testing.T) {
+	var before, after []CompactionInfo
+	done := make(chan struct{})
+	m := newCompactionMonitor(&CompactionOptions{
+		OnBeforeCompact: func(_ context.Context, info CompactionInfo) { before = append(before, info) },
+		OnAfterCompact: func(_ context.Context, info CompactionInfo) {
+			after = append(after, info)
+			close(done)
+		},
+	}, nil)
+
+	ctx := context.Background()
+	m.observe(ctx, &SDKAssistantMessage{Message: APIAssistantMessage{
+		Model: "claude-sonnet",
+		Usage: Usage{InputTokens: 1000, CacheReadInputTokens: 500, OutputTokens: 100},
+	}})
+	m.requested(CompactTriggerManual, "focus on tests")
+	m.observe(ctx, &SDKStatusMessage{Status: SDKStatusCompacting})
+	m.observe(ctx, &SDKCompactBoundaryMessage{
+		SDKSystemMessage: SDKSystemMessage{Subtype: SystemSubtypeCompactBoundary},
+		CompactMetadata:  CompactMetadata{Trigger: "manual", PreTokens: 1650},
+	})
+	waitClosed(t, done)
+
+	if len(before) != 1 || len(after) != 1 {
+		t.Fatalf("expected one before and one after callback, got %d/%d", len(before), len(after))
+	}
+	if before[0].PreTokens != 1600 {
+		t.Errorf("expected estimated pre tokens 1600, got %d", before[0].PreTokens)
+	}
+	if before[0].Trigger != CompactTriggerManual || before[0].CustomInstructions != "focus on tests" {
+		t.Errorf("unexpected before info: %+v", before[0])
+	}
+	if after[0].PreTokens != 1650 || after[0].CustomInstructions != "focus on tests" {
+		t.Errorf("unexpected after info: %+v", after[0])
+	}
+}
+
+func TestCompactionMonitor_CLIInitiated(t *testing.T) {
+	var after []CompactionInfo
+	done := make(chan struct{})
+	m := newCompactionMonitor(&CompactionOptions{
+		OnAfterCompact: func(_ context.Context, info CompactionInfo) {
+			after = append(after, info)
+			close(done)
+		},
+	}, nil)
+
+	// A boundary without a preceding status still reports the compaction.
+	m.observe(context.Background(), &SDKCompactBoundaryMessage{
+		CompactMetadata: CompactMetadata{Trigger: "auto", PreTokens: 190000},
+	})
+	waitClosed(t, done)
+
+	if len(after) != 1 || after[0].Trigger != CompactTriggerAuto || after[0].PreTokens != 190000 {
+		t.Fatalf("unexpected after callbacks: %+v", after)
+	}
+}
+
+func TestCompactionMonitor_AutoPolicy(t *testing.T) {
+	sent := make(chan string, 1)
+	m := newCompactionMonitor(&CompactionOptions{
+		AutoCompactThreshold:    0.8,
+		AutoCompactInstructions: "summarize decisions",
+	}, func(_ context.Context, instructions string) error {
+		sent <- instructions
+
+		return nil
+	})
+
+	ctx := context.Background()
+	result := &SDKResultMessage{ModelUsage: map[string]ModelUsage{
+		"claude-sonnet": {ContextWindow: 200000},
+	}}
+
+	m.observe(ctx, &SDKAssistantMessage{Message: APIAssistantMessage{
+		Model: "claude-sonnet",
+		Usage: Usage{InputTokens: 100000},
+	}})
+	m.observe(ctx, result)
+
+	select {
+	case got := <-sent:
+		t.Fatalf("compaction triggered below threshold with %q", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	m.observe(ctx, &SDKAssistantMessage{Message: APIAssistantMessage{
+		Model: "claude-sonnet",
+		Usage: Usage{InputTokens: 150000, CacheReadInputTokens: 10000},
+	}})
+	m.observe(ctx, result)
+
+	select {
+	case got := <-sent:
+		if got != "summarize decisions" {
+			t.Fatalf("unexpected instructions %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected automatic compaction at threshold")
+	}
+
+	// No second request while the first is still pending.
+	m.observe(ctx, result)
+	select {
+	case <-sent:
+		t.Fatal("compaction requested twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestValidateCompactionOptions(t *testing.T) {
+	if err := validateCompactionOptions(&CompactionOptions{AutoCompactThreshold: 1.5}); err == nil {
+		t.Fatal("expected error for threshold above 1")
+	}
+	if err := validateCompactionOptions(&CompactionOptions{AutoCompactThreshold: 0.9}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCompactionMonitor_SlowCallbackDoesNotBlockObserve(t *testing.T) {
+	release := make(chan struct{})
+	var order []string
+	done := make(chan struct{})
+	m := newCompactionMonitor(&CompactionOptions{
+		OnBeforeCompact: func(context.Context, CompactionInfo) {
+			<-release
+			order = append(order, "before")
+		},
+		OnAfterCompact: func(context.Context, CompactionInfo) {
+			order = append(order, "after")
+			close(done)
+		},
+	}, nil)
+
+	observed := make(chan struct{})
+	go func() {
+		m.observe(context.Background(), &SDKCompactBoundaryMessage{})
+		close(observed)
+	}()
+
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		t.Fatal("observe blocked on a slow callback")
+	}
+
+	close(release)
+	waitClosed(t, done)
+	if len(order) != 2 || order[0] != "before" || order[1] != "after" {
+		t.Fatalf("expected callbacks in stream order, got %v", order)
+	}
+}
+
+func TestCompactionMonitor_StopDropsAutoCompact(t *testing.T) {
+	started := make(chan struct{})
+	m := newCompactionMonitor(&CompactionOptions{AutoCompactThreshold: 0.5},
+		func(ctx context.Context, _ string) error {
+			close(started)
+			<-ctx.Done()
+
+			return ctx.Err()
+		})
+
+	usage := &SDKAssistantMessage{Message: APIAssistantMessage{
+		Model: "claude-sonnet",
+		Usage: Usage{InputTokens: 150000},
+	}}
+	result := &SDKResultMessage{ModelUsage: map[string]ModelUsage{
+		"claude-sonnet": {ContextWindow: 200000},
+	}}
+	m.observe(context.Background(), usage)
+	m.observe(context.Background(), result)
+	waitClosed(t, started)
+
+	// stop cancels the request in flight and wait joins it.
+	m.stop()
+	m.wait()
+
+	// A result seen after stop, as the reader may still deliver one while
+	// the query closes, no longer triggers the policy.
+	m.observe(context.Background(), usage)
+	m.observe(context.Background(), result)
+	m.wait()
+}
+
+// waitClosed fails the test if ch is not closed within a second.
+func waitClosed(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+// Every Query this package returns can compact.
+var (
+	_ Compactor = (*queryImpl)(nil)
+	_ Compactor = (*supervisedQuery)(nil)
+)
+
+// plainQuery implements only Query, as an external mock would.
+type plainQuery struct{ Query }
+
+func TestCompactQuery_WithoutCompactor(t *testing.T) {
+	var clientErr *clauderrs.ClientError
+	err := compactQuery(context.Background(), plainQuery{}, "")
+	if !errors.As(err, &clientErr) || clientErr.Code() != clauderrs.ErrCodeInvalidState {
+		t.Fatalf("expected invalid state error, got %v", err)
+	}
+}
@@ -51,12 +51,49 @@
 //	for msg := range client.ReceiveResponse(ctx) {
 //	    // Handle messages...
 //	}
+//	if err := client.ResponseErr(); err != nil {
+//	    log.Fatal(err)
+//	}
 //
 //	// Send follow-up
 //	if err := client.Query(ctx, "Can you explain the error handling?"); err != nil {
 //	    log.Fatal(err)
 //	}
 //
+// # Iterating with range
+//
+// [Messages], [Query] and [ClaudeSDKClient] also expose range-over-func
+// iterators that yield stream errors in their original typed form. Breaking
+// out of the loop closes the underlying process:
+//
+//	for msg, err := range claude.Messages(ctx, "What is 2+2?", nil) {
+//	    if err != nil {
+//	        log.Fatal(err)
+//	    }
+//	    // Handle msg...
+//	}
+//
+// # Streaming input
+//
+// [QueryStream] and [QueryChan] take the prompt as a stream of user messages,
+// for example from a queue consumer. Messages are written as they arrive and
+// stdin is closed when the source ends, so the CLI finishes on its own:
+//
+//	in := make(chan claude.SDKUserMessage)
+//	q, err := claude.QueryChan(ctx, in, nil)
+//	// ...
+//	in <- claude.NewUserMessage("Summarize the next ticket")
+//	close(in)
+//
+// # Custom transports
+//
+// By default the CLI runs as a subprocess. Set [Options].TransportFactory to
+// reach it some other way, such as through a wrapper command, over a Unix
+// socket to a sidecar, or through an in-memory fake in tests. The factory
+// receives the arguments and environment the SDK would have used; all query
+// and control handling stays the same. [DefaultTransportFactory] can be called
+// from a factory that only adjusts the configuration.
+//
 // # Choosing Between SimpleQuery and ClaudeSDKClient
 //
 //	| Feature                  | SimpleQuery | ClaudeSDKClient |
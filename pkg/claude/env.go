@@ -0,0 +1,193 @@
+package claude
+
+import (
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/internal/transport"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// EnvBase selects the environment the CLI subprocess starts from, before
+// EnvPolicy.Allow and Options.Env are added.
+type EnvBase string
+
+const (
+	// EnvBaseInherit starts from the SDK process's whole environment. This
+	// is the default.
+	EnvBaseInherit EnvBase = "inherit"
+	// EnvBaseMinimal starts from MinimalEnvVars only.
+	EnvBaseMinimal EnvBase = "minimal"
+	// EnvBaseEmpty starts from an empty environment.
+	EnvBaseEmpty EnvBase = "empty"
+)
+
+// MinimalEnvVars are the variables EnvBaseMinimal keeps from the SDK
+// process's environment.
+var MinimalEnvVars = []string{"PATH", "HOME", "LANG"}
+
+// SecretEnvPatterns are common patterns for variables holding credentials,
+// for use in EnvPolicy.Scrub.
+var SecretEnvPatterns = []string{
+	"*_TOKEN",
+	"*_SECRET",
+	"*_SECRET_*",
+	"*_PASSWORD",
+	"*_KEY",
+	"*_CREDENTIALS",
+	"DATABASE_URL",
+}
+
+// EnvPolicy controls which of the SDK process's environment variables reach
+// the CLI, and through it every tool the agent runs.
+//
+// The environment is built in three layers: the Base, then the variables
+// named in Allow, then Options.Env. Scrub removes matching variables from the
+// first two layers; Options.Env is always passed as given.
+//
+// With a minimal or empty base the CLI still needs credentials, for example:
+//
+//	opts := &claude.Options{
+//	    EnvPolicy: &claude.EnvPolicy{
+//	        Base:  claude.EnvBaseMinimal,
+//	        Allow: []string{"ANTHROPIC_API_KEY", "LC_*"},
+//	    },
+//	}
+type EnvPolicy struct {
+	// Base is the starting environment. Empty uses EnvBaseInherit.
+	Base EnvBase
+	// Allow lists variables copied from the SDK process's environment on
+	// top of Base. Entries may be glob patterns such as "LC_*". Variables
+	// named exactly are kept even if they match Scrub.
+	Allow []string
+	// Scrub lists glob patterns, such as "*_TOKEN", of variables to drop
+	// from Base and from pattern matches in Allow. See SecretEnvPatterns.
+	Scrub []string
+}
+
+// envAlwaysShown are variables EffectiveEnv shows unredacted.
+var envAlwaysShown = []string{"PATH", "HOME", "LANG", "LC_*", "TERM", "USER", "LOGNAME", "SHELL", "TMPDIR", "PWD"}
+
+// validateEnvPolicy checks the base and the patterns of a policy.
+func validateEnvPolicy(policy *EnvPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	switch policy.Base {
+	case "", EnvBaseInherit, EnvBaseMinimal, EnvBaseEmpty:
+	default:
+		return clauderrs.NewValidationError(
+			clauderrs.ErrCodeInvalidFormat,
+			"unknown environment base: "+string(policy.Base),
+			nil,
+			"EnvPolicy.Base",
+			policy.Base,
+		)
+	}
+
+	for _, patterns := range [][]string{policy.Allow, policy.Scrub} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return clauderrs.NewValidationError(
+					clauderrs.ErrCodeInvalidFormat,
+					"invalid environment variable pattern: "+pattern,
+					err,
+					"EnvPolicy",
+					pattern,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// baseEnv returns the environment the CLI starts from under policy, taken from
+// environ, or nil to inherit it unchanged.
+func (policy *EnvPolicy) baseEnv(environ []string) []string {
+	if policy == nil || (policy.Base != EnvBaseMinimal && policy.Base != EnvBaseEmpty && len(policy.Allow) == 0 && len(policy.Scrub) == 0) {
+		return nil
+	}
+
+	env := make([]string, 0, len(environ))
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+
+		var keep bool
+		switch {
+		case slices.Contains(policy.Allow, name):
+			keep = true
+		case matchEnvName(policy.Scrub, name):
+			keep = false
+		case policy.Base == EnvBaseMinimal:
+			keep = slices.Contains(MinimalEnvVars, name) || matchEnvName(policy.Allow, name)
+		case policy.Base == EnvBaseEmpty:
+			keep = matchEnvName(policy.Allow, name)
+		default:
+			keep = true
+		}
+
+		if keep {
+			env = append(env, entry)
+		}
+	}
+
+	return env
+}
+
+// matchEnvName reports whether name matches one of patterns.
+func matchEnvName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EffectiveEnv returns the environment the CLI would be started with under
+// opts, including the HOME, USER and LOGNAME of Options.User, sorted by name,
+// for debugging. Values are replaced with "[REDACTED]"
+// except for a few non-sensitive variables such as PATH, HOME and LANG.
+func EffectiveEnv(opts *Options) []string {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	base := opts.EnvPolicy.baseEnv(os.Environ())
+	if base == nil {
+		base = os.Environ()
+	}
+
+	// Later entries win, as they do for exec.Cmd.
+	values := make(map[string]string)
+	for _, entry := range slices.Concat(base, transport.IdentityEnv(opts.User), envEntries(opts.Env)) {
+		name, value, _ := strings.Cut(entry, "=")
+		values[name] = value
+	}
+
+	env := make([]string, 0, len(values))
+	for name, value := range values {
+		if !matchEnvName(envAlwaysShown, name) {
+			value = "[REDACTED]"
+		}
+		env = append(env, name+"="+value)
+	}
+	slices.Sort(env)
+
+	return env
+}
+
+// envEntries converts an environment map to KEY=VALUE entries.
+func envEntries(vars map[string]string) []string {
+	env := make([]string, 0, len(vars))
+	for key, value := range vars {
+		env = append(env, key+"="+value)
+	}
+
+	return env
+}
@@ -0,0 +1,199 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestEnvPolicy_BaseEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/bin",
+		"HOME=/home/svc",
+		"LANG=C.UTF-8",
+		"LC_ALL=C",
+		"DATABASE_URL=postgres://secret",
+		"GITHUB_TOKEN=ghp_x",
+		"ANTHROPIC_API_KEY=sk-ant-x",
+		"APP_MODE=prod",
+	}
+
+	tests := []struct {
+		name   string
+		policy *EnvPolicy
+		want   []string
+	}{
+		{
+			name: "nil policy inherits",
+		},
+		{
+			name:   "plain inherit inherits",
+			policy: &EnvPolicy{Base: EnvBaseInherit},
+		},
+		{
+			name:   "inherit with scrubbing",
+			policy: &EnvPolicy{Scrub: SecretEnvPatterns},
+			want:   []string{"PATH=/bin", "HOME=/home/svc", "LANG=C.UTF-8", "LC_ALL=C", "APP_MODE=prod"},
+		},
+		{
+			name:   "exact allow overrides scrubbing",
+			policy: &EnvPolicy{Allow: []string{"ANTHROPIC_API_KEY"}, Scrub: SecretEnvPatterns},
+			want:   []string{"PATH=/bin", "HOME=/home/svc", "LANG=C.UTF-8", "LC_ALL=C", "ANTHROPIC_API_KEY=sk-ant-x", "APP_MODE=prod"},
+		},
+		{
+			name:   "minimal",
+			policy: &EnvPolicy{Base: EnvBaseMinimal},
+			want:   []string{"PATH=/bin", "HOME=/home/svc", "LANG=C.UTF-8"},
+		},
+		{
+			name:   "minimal with allowlist",
+			policy: &EnvPolicy{Base: EnvBaseMinimal, Allow: []string{"LC_*", "*_KEY"}, Scrub: []string{"*_KEY"}},
+			want:   []string{"PATH=/bin", "HOME=/home/svc", "LANG=C.UTF-8", "LC_ALL=C"},
+		},
+		{
+			name:   "empty",
+			policy: &EnvPolicy{Base: EnvBaseEmpty},
+			want:   []string{},
+		},
+		{
+			name:   "empty with allowlist",
+			policy: &EnvPolicy{Base: EnvBaseEmpty, Allow: []string{"APP_MODE"}},
+			want:   []string{"APP_MODE=prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.baseEnv(environ)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("baseEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEnvPolicy(t *testing.T) {
+	for _, policy := range []*EnvPolicy{
+		{Base: "bogus"},
+		{Scrub: []string{"[A-"}},
+	} {
+		err := validateEnvPolicy(policy)
+		var validationErr *clauderrs.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("expected a ValidationError for %+v, got %v", policy, err)
+		}
+	}
+
+	if err := validateEnvPolicy(&EnvPolicy{Base: EnvBaseMinimal, Allow: []string{"LC_*"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEffectiveEnv(t *testing.T) {
+	t.Setenv("SVC_DB_PASSWORD", "hunter2")
+	t.Setenv("APP_MODE", "prod")
+	t.Setenv("LANG", "C.UTF-8")
+
+	env := EffectiveEnv(&Options{
+		Env:       map[string]string{"APP_MODE": "test", "EXTRA": "value"},
+		EnvPolicy: &EnvPolicy{Scrub: SecretEnvPatterns},
+	})
+
+	if !slices.IsSorted(env) {
+		t.Errorf("expected sorted output, got %q", env)
+	}
+	for _, want := range []string{"APP_MODE=[REDACTED]", "EXTRA=[REDACTED]", "LANG=C.UTF-8"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("expected %q in %q", want, env)
+		}
+	}
+	for _, entry := range env {
+		if strings.HasPrefix(entry, "SVC_DB_PASSWORD=") || strings.Contains(entry, "test") {
+			t.Errorf("unexpected entry %q", entry)
+		}
+	}
+}
+
+func TestEffectiveEnv_UserIdentity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("user switching is Unix-only")
+	}
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("could not get current user: %v", err)
+	}
+	t.Setenv("HOME", "/parent/home")
+	t.Setenv("USER", "parent")
+
+	env := EffectiveEnv(&Options{User: u.Username})
+
+	for _, want := range []string{"HOME=" + u.HomeDir, "USER=" + u.Username, "LOGNAME=" + u.Username} {
+		if !slices.Contains(env, want) {
+			t.Errorf("expected %q in %q", want, env)
+		}
+	}
+}
+
+func TestQuery_UnknownUserIsPermissionError(t *testing.T) {
+	cli := writeFakeCLI(t, fakeCLIScript)
+
+	q, err := QueryFunc("hello", &Options{
+		PathToClaudeCodeExecutable: cli,
+		User:                       "nonexistent_user_abc123xyz",
+	})
+	if err == nil {
+		_ = q.Close()
+		t.Fatal("expected an error for an unknown user")
+	}
+
+	var permErr *clauderrs.PermissionError
+	if !errors.As(err, &permErr) || permErr.Code() != clauderrs.ErrCodeUserSwitchFailed {
+		t.Fatalf("expected user switch PermissionError, got %v", err)
+	}
+	if clauderrs.IsProcessError(err) {
+		t.Errorf("expected no ProcessError wrapper, got %v", err)
+	}
+}
+
+func TestQuery_EnvPolicyReachesCLI(t *testing.T) {
+	t.Setenv("SVC_API_TOKEN", "secret")
+	t.Setenv("APP_MODE", "prod")
+	envFile := filepath.Join(t.TempDir(), "env")
+	cli := writeFakeCLI(t, strings.Replace(fakeCLIScript, "n=0\n", "/usr/bin/env > "+envFile+"\nn=0\n", 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	q, err := QueryStream(ctx, func(func(SDKUserMessage) bool) {}, &Options{
+		PathToClaudeCodeExecutable: cli,
+		Env:                        map[string]string{"EXTRA": "1"},
+		EnvPolicy:                  &EnvPolicy{Base: EnvBaseEmpty, Allow: []string{"APP_MODE"}},
+	})
+	if err != nil {
+		t.Fatalf("QueryStream: %v", err)
+	}
+	defer q.Close()
+	drainResults(ctx, t, q)
+
+	data, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("read env: %v", err)
+	}
+	env := string(data)
+	if !strings.Contains(env, "APP_MODE=prod\n") || !strings.Contains(env, "EXTRA=1\n") {
+		t.Errorf("expected allowlisted and explicit variables, got:\n%s", env)
+	}
+	if strings.Contains(env, "SVC_API_TOKEN") || strings.Contains(env, "HOME=") {
+		t.Errorf("expected other variables to be dropped, got:\n%s", env)
+	}
+}
@@ -0,0 +1,90 @@
+package claude
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/internal/transport"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// ExecutablePathEnvVar names the environment variable that overrides CLI
+// discovery when Options.PathToClaudeCodeExecutable is empty.
+const ExecutablePathEnvVar = transport.ExecutablePathEnvVar
+
+// ExecutableSource says how the CLI executable was found.
+type ExecutableSource string
+
+// Executable sources, in the order discovery checks them.
+const (
+	// ExecutableFromOption is Options.PathToClaudeCodeExecutable.
+	ExecutableFromOption ExecutableSource = "option"
+	// ExecutableFromEnv is the ExecutablePathEnvVar override.
+	ExecutableFromEnv ExecutableSource = "env"
+	// ExecutableFromPath is a "claude" found on PATH.
+	ExecutableFromPath ExecutableSource = "path"
+	// ExecutableFromLocalInstall is ~/.claude/local/claude, where the
+	// CLI's own installer puts it.
+	ExecutableFromLocalInstall ExecutableSource = "local_install"
+	// ExecutableFromNpmPrefix is bin/claude under npm's global prefix:
+	// NPM_CONFIG_PREFIX, the prefix in ~/.npmrc, or `npm prefix -g`.
+	ExecutableFromNpmPrefix ExecutableSource = "npm_prefix"
+	// ExecutableFromNpmGlobal is ~/.npm-global/bin/claude.
+	ExecutableFromNpmGlobal ExecutableSource = "npm_global"
+	// ExecutableFromSystem is /usr/local/bin/claude.
+	ExecutableFromSystem ExecutableSource = "system"
+	// ExecutableFromNodeModules is node_modules/.bin/claude under Cwd.
+	ExecutableFromNodeModules ExecutableSource = "node_modules"
+)
+
+// ClaudeExecutable is the CLI executable a query runs.
+type ClaudeExecutable struct {
+	Path   string
+	Source ExecutableSource
+}
+
+// FindClaudeExecutable reports which CLI executable DefaultTransportFactory
+// would run for opts, and how it was found.
+//
+// Options.PathToClaudeCodeExecutable is used as given, then the
+// ExecutablePathEnvVar override. Otherwise the first executable "claude" is
+// taken from PATH, ~/.claude/local, the bin directory of npm's global
+// prefix, ~/.npm-global/bin, /usr/local/bin and node_modules/.bin under
+// Cwd, so the CLI is found even when services run with a minimal PATH.
+// Discovered paths are cached for the life of the process.
+//
+// If nothing is found, the error is a *clauderrs.ProcessError with code
+// clauderrs.ErrCodeProcessNotFound that lists every path tried.
+func FindClaudeExecutable(opts *Options) (ClaudeExecutable, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	found, err := transport.FindExecutable(opts.PathToClaudeCodeExecutable, opts.Cwd)
+	if err != nil {
+		return ClaudeExecutable{}, executableError(err)
+	}
+
+	return ClaudeExecutable{Path: found.Path, Source: ExecutableSource(found.Source)}, nil
+}
+
+// executableError converts a failed discovery into a ProcessError, or
+// returns nil for any other error.
+func executableError(err error) *clauderrs.ProcessError {
+	var notFound *transport.ExecutableNotFoundError
+	if !errors.As(err, &notFound) {
+		return nil
+	}
+
+	procErr := clauderrs.NewProcessError(
+		clauderrs.ErrCodeProcessNotFound,
+		"Claude Code executable not found; set PathToClaudeCodeExecutable or "+
+			ExecutablePathEnvVar+". Tried: "+strings.Join(notFound.Tried, ", "),
+		err,
+		-1,
+		"",
+	)
+	_ = procErr.WithMetadata("tried", notFound.Tried)
+
+	return procErr
+}
@@ -0,0 +1,41 @@
+package claude
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/internal/transport"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestFindClaudeExecutable_Overrides(t *testing.T) {
+	t.Setenv(ExecutablePathEnvVar, "/srv/claude")
+
+	found, err := FindClaudeExecutable(nil)
+	if err != nil || found != (ClaudeExecutable{"/srv/claude", ExecutableFromEnv}) {
+		t.Errorf("expected env override, got %+v, %v", found, err)
+	}
+
+	found, err = FindClaudeExecutable(&Options{PathToClaudeCodeExecutable: "/opt/claude"})
+	if err != nil || found != (ClaudeExecutable{"/opt/claude", ExecutableFromOption}) {
+		t.Errorf("expected option to win, got %+v, %v", found, err)
+	}
+}
+
+func TestExecutableError_ListsTriedPaths(t *testing.T) {
+	tried := []string{"/usr/bin/claude", "/home/svc/.claude/local/claude"}
+	err := executableError(&transport.ExecutableNotFoundError{Tried: tried})
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) || procErr.Code() != clauderrs.ErrCodeProcessNotFound {
+		t.Fatalf("expected process not found error, got %v", err)
+	}
+	if !strings.Contains(procErr.Error(), strings.Join(tried, ", ")) {
+		t.Errorf("expected tried paths in %q", procErr.Error())
+	}
+
+	if executableError(errors.New("other")) != nil {
+		t.Error("expected nil for unrelated errors")
+	}
+}
@@ -0,0 +1,232 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// QueryStream starts a query whose prompt is a stream of user messages, the
+// Go counterpart of passing an async iterable as the prompt in the TypeScript
+// SDK.
+//
+// Messages are written to the CLI one at a time as input yields them. Each
+// write blocks until the CLI has accepted the message, so a slow CLI slows the
+// source down rather than buffering without bound. When input ends, stdin is
+// closed and the CLI finishes after answering the messages it has received;
+// Next then returns io.EOF. If hooks, CanUseTool or SDK MCP servers are
+// configured, stdin stays open until the CLI has answered every message,
+// because the CLI answers those callbacks over the same pipe; messages sent
+// with SendUserMessage count too. If the CLI merges queued messages into one
+// turn, fewer results arrive and stdin stays open until ctx is done or the
+// query is closed.
+//
+// Cancelling ctx stops reading from input and closes stdin. Messages only need
+// Message.Content to be set; the type, role, UUID and session ID are filled in
+// when empty. SendUserMessage may still be used while the stream is running.
+func QueryStream(ctx context.Context, input iter.Seq[SDKUserMessage], opts *Options) (Query, error) {
+	q, err := newQueryImpl("", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	go q.streamInput(ctx, input)
+
+	return q, nil
+}
+
+// QueryChan is like QueryStream but reads user messages from a channel. The
+// input ends when the channel is closed.
+func QueryChan(ctx context.Context, input <-chan SDKUserMessage, opts *Options) (Query, error) {
+	return QueryStream(ctx, channelSeq(ctx, input), opts)
+}
+
+// NewUserMessage returns a user message with a single text block, suitable for
+// QueryStream and QueryChan.
+func NewUserMessage(text string) SDKUserMessage {
+	return SDKUserMessage{
+		TypeField: "user",
+		Message: APIUserMessage{
+			Role: "user",
+			Content: []ContentBlock{
+				TextContentBlock{Type: "text", Text: text},
+			},
+		},
+	}
+}
+
+// channelSeq adapts a channel to an iterator that stops when the channel is
+// closed or ctx is done.
+func channelSeq(ctx context.Context, ch <-chan SDKUserMessage) iter.Seq[SDKUserMessage] {
+	return func(yield func(SDKUserMessage) bool) {
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok || !yield(msg) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// streamInput pumps messages from input to the CLI, then closes stdin.
+func (q *queryImpl) streamInput(ctx context.Context, input iter.Seq[SDKUserMessage]) {
+	for msg := range input {
+		if ctx.Err() != nil || q.isClosed() {
+			break
+		}
+
+		if err := q.writeUserMessage(ctx, msg); err != nil {
+			if ctx.Err() == nil {
+				q.reportInputError(err)
+			}
+
+			break
+		}
+	}
+
+	if q.needsInputOpen() {
+		_ = q.results.wait(ctx, q.closeChan)
+	}
+
+	_ = q.transport.CloseInput()
+}
+
+// writeUserMessage fills in defaults and writes msg to the CLI.
+func (q *queryImpl) writeUserMessage(ctx context.Context, msg SDKUserMessage) error {
+	if msg.TypeField == "" {
+		msg.TypeField = "user"
+	}
+	if msg.Message.Role == "" {
+		msg.Message.Role = "user"
+	}
+	if msg.UUIDField == uuid.Nil {
+		msg.UUIDField = uuid.New()
+	}
+	if msg.SessionIDField == "" {
+		msg.SessionIDField = q.sessionID
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return clauderrs.NewProtocolError(clauderrs.ErrCodeMessageParseFailed, "failed to marshal user message", err).
+			WithSessionID(q.sessionID).
+			WithMessageType("user")
+	}
+
+	return q.writeUser(ctx, data)
+}
+
+// writeUser writes an encoded user message and counts it for
+// resultCounter.wait.
+func (q *queryImpl) writeUser(ctx context.Context, data []byte) error {
+	if err := q.transport.Write(ctx, data); err != nil {
+		return err
+	}
+	q.results.written()
+
+	return nil
+}
+
+// reportInputError surfaces a failed input write on the message stream, unless
+// the reader has already reported an error.
+func (q *queryImpl) reportInputError(err error) {
+	if q.isClosed() {
+		return
+	}
+
+	select {
+	case q.errChan <- clauderrs.NewTransportError(
+		clauderrs.ErrCodeWriteFailed,
+		"failed to stream user message",
+		err,
+	):
+	default:
+	}
+}
+
+// needsInputOpen reports whether the CLI may send control requests that must
+// be answered over stdin.
+func (q *queryImpl) needsInputOpen() bool {
+	if len(q.opts.Hooks) > 0 || q.opts.CanUseTool != nil {
+		return true
+	}
+	for _, server := range q.opts.McpServers {
+		if _, ok := server.(McpSdkServerConfig); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *queryImpl) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.closed
+}
+
+// resultCounter counts the user messages written to the CLI and the result
+// messages that answer them, so input streaming can wait for the CLI to finish
+// before closing stdin.
+type resultCounter struct {
+	mu sync.Mutex
+	// sent counts user messages from every source: the input stream,
+	// SendUserMessage and Compact.
+	sent   int
+	n      int
+	notify chan struct{}
+}
+
+func newResultCounter() *resultCounter {
+	return &resultCounter{notify: make(chan struct{})}
+}
+
+// written records a user message written to the CLI.
+func (c *resultCounter) written() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sent++
+}
+
+func (c *resultCounter) add() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.n++
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// wait blocks until the CLI has answered every user message written to it,
+// ctx is done or closed is closed.
+func (c *resultCounter) wait(ctx context.Context, closed <-chan struct{}) error {
+	for {
+		c.mu.Lock()
+		if c.n >= c.sent {
+			c.mu.Unlock()
+
+			return nil
+		}
+		notify := c.notify
+		c.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-closed:
+			return context.Canceled
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeCLIScript answers every user message with a result and exits when stdin
+// is closed.
+const fakeCLIScript = `#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "claude version 2.1.0"
+	exit 0
+fi
+n=0
+while read -r line; do
+	case "$line" in
+	*'"type":"user"'*)
+		n=$((n + 1))
+		echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":'$n',"result":"ok"}'
+		;;
+	esac
+done
+`
+
+// writeFakeCLI writes script to an executable file and returns its path.
+func writeFakeCLI(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLI scripts require a POSIX shell")
+	}
+
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake CLI: %v", err)
+	}
+
+	return path
+}
+
+// drainResults reads until EOF and returns the number of result messages.
+func drainResults(ctx context.Context, t *testing.T, q Query) int {
+	t.Helper()
+
+	results := 0
+	for {
+		msg, err := q.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return results
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := msg.(*SDKResultMessage); ok {
+			results++
+		}
+	}
+}
+
+func TestQueryStream_ClosesInputWhenSourceEnds(t *testing.T) {
+	cli := writeFakeCLI(t, fakeCLIScript)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	input := func(yield func(SDKUserMessage) bool) {
+		for _, prompt := range []string{"one", "two", "three"} {
+			if !yield(NewUserMessage(prompt)) {
+				return
+			}
+		}
+	}
+
+	q, err := QueryStream(ctx, input, &Options{PathToClaudeCodeExecutable: cli})
+	if err != nil {
+		t.Fatalf("QueryStream: %v", err)
+	}
+	defer q.Close()
+
+	if got := drainResults(ctx, t, q); got != 3 {
+		t.Fatalf("expected 3 results before EOF, got %d", got)
+	}
+}
+
+func TestQueryChan_WaitsForResultsWhenCallbacksConfigured(t *testing.T) {
+	cli := writeFakeCLI(t, fakeCLIScript)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	input := make(chan SDKUserMessage)
+	q, err := QueryChan(ctx, input, &Options{
+		PathToClaudeCodeExecutable: cli,
+		CanUseTool: func(
+			context.Context, string, map[string]JSONValue, []PermissionUpdate,
+			string, *string, *string, *string,
+		) (PermissionResult, error) {
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryChan: %v", err)
+	}
+	defer q.Close()
+
+	input <- SDKUserMessage{Message: APIUserMessage{Content: []ContentBlock{
+		TextContentBlock{Type: "text", Text: "hello"},
+	}}}
+	input <- NewUserMessage("again")
+	close(input)
+
+	if got := drainResults(ctx, t, q); got != 2 {
+		t.Fatalf("expected 2 results before EOF, got %d", got)
+	}
+}
+
+func TestQueryChan_KeepsInputOpenWhenMessagesMerge(t *testing.T) {
+	// Answers both user messages with a single result, as the CLI does when
+	// it merges queued messages into one turn, and records when stdin ends.
+	cli := writeFakeCLI(t, `#!/bin/sh
+case "$1" in
+--version) echo "claude version 2.1.0"; exit 0 ;;
+--help) exit 0 ;;
+esac
+read -r first
+read -r second
+echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":1,"result":"ok"}'
+cat > /dev/null
+touch "$(dirname "$0")/eof"
+`)
+	eof := filepath.Join(filepath.Dir(cli), "eof")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	input := make(chan SDKUserMessage, 2)
+	input <- NewUserMessage("one")
+	input <- NewUserMessage("two")
+	close(input)
+
+	q, err := QueryChan(ctx, input, &Options{
+		PathToClaudeCodeExecutable: cli,
+		CanUseTool: func(
+			context.Context, string, map[string]JSONValue, []PermissionUpdate,
+			string, *string, *string, *string,
+		) (PermissionResult, error) {
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryChan: %v", err)
+	}
+
+	msg, err := q.Next(ctx)
+	if _, ok := msg.(*SDKResultMessage); !ok || err != nil {
+		t.Fatalf("expected the merged result, got %T, %v", msg, err)
+	}
+
+	// The CLI may still need to ask for permissions, so a missing result
+	// must not close stdin.
+	time.Sleep(200 * time.Millisecond)
+	if _, err := os.Stat(eof); err == nil {
+		t.Fatal("expected stdin to stay open while a message is unanswered")
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(eof); err != nil {
+		t.Errorf("expected Close to end stdin: %v", err)
+	}
+}
+
+func TestResultCounter_CountsEveryWrittenMessage(t *testing.T) {
+	c := newResultCounter()
+	closed := make(chan struct{})
+
+	// A streamed message and one sent with SendUserMessage both need
+	// an answer.
+	c.written()
+	c.written()
+	c.add()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.wait(ctx, closed); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to keep waiting for the second result, got %v", err)
+	}
+
+	c.add()
+	if err := c.wait(context.Background(), closed); err != nil {
+		t.Fatalf("expected every message to be answered, got %v", err)
+	}
+}
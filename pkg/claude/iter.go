@@ -0,0 +1,136 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// messageSource is the subset of Query needed to iterate messages.
+type messageSource interface {
+	Next(context.Context) (SDKMessage, error)
+	Close() error
+}
+
+// iterateMessages adapts a message source to a range-over-func iterator.
+// Iteration ends at EOF, at the first error (which is yielded), after a result
+// message when untilResult is set, or when the caller stops. The source is
+// closed when iteration stops early or fails; closeOnFinish also closes it
+// when iteration completes normally.
+func iterateMessages(
+	ctx context.Context,
+	src messageSource,
+	untilResult bool,
+	closeOnFinish bool,
+) iter.Seq2[SDKMessage, error] {
+	return func(yield func(SDKMessage, error) bool) {
+		finished := false
+		defer func() {
+			if !finished || closeOnFinish {
+				_ = src.Close()
+			}
+		}()
+
+		for {
+			msg, err := src.Next(ctx)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					finished = true
+
+					return
+				}
+				yield(nil, err)
+
+				return
+			}
+
+			if !yield(msg, nil) {
+				return
+			}
+
+			if _, ok := msg.(*SDKResultMessage); ok && untilResult {
+				finished = true
+
+				return
+			}
+		}
+	}
+}
+
+// Messages returns an iterator over the remaining messages of the query.
+func (q *queryImpl) Messages(ctx context.Context) iter.Seq2[SDKMessage, error] {
+	return iterateMessages(ctx, q, false, true)
+}
+
+// Messages starts a one-shot query and returns an iterator over its messages.
+// The query starts when iteration begins and is closed when the loop ends,
+// after the result message or when the caller breaks out early. A failure to
+// start and any stream error are yielded as the final error.
+//
+//	for msg, err := range claude.Messages(ctx, "What is 2+2?", nil) {
+//	    if err != nil {
+//	        log.Fatal(err)
+//	    }
+//	    if m, ok := msg.(*claude.SDKAssistantMessage); ok {
+//	        fmt.Println(m.Message.Content)
+//	    }
+//	}
+func Messages(ctx context.Context, prompt string, opts *Options) iter.Seq2[SDKMessage, error] {
+	return func(yield func(SDKMessage, error) bool) {
+		q, err := newQueryImpl(prompt, opts)
+		if err != nil {
+			yield(nil, err)
+
+			return
+		}
+
+		iterateMessages(ctx, q, true, true)(yield)
+	}
+}
+
+// Messages returns an iterator over the messages of the current response,
+// ending after its result message. Stream errors are yielded in their
+// original typed form.
+//
+// Breaking out of the loop before the result, or a stream error, closes the
+// whole client, as Close does: the unread remainder of the response would
+// otherwise be delivered as the start of the next one. Call Query to start
+// a new conversation afterwards.
+func (c *ClaudeSDKClient) Messages(ctx context.Context) iter.Seq2[SDKMessage, error] {
+	return func(yield func(SDKMessage, error) bool) {
+		c.mu.Lock()
+		q := c.query
+		c.mu.Unlock()
+
+		if q == nil {
+			yield(nil, clauderrs.NewClientError(
+				clauderrs.ErrCodeNoActiveQuery,
+				errNoActiveQuery,
+				nil,
+			))
+
+			return
+		}
+
+		iterateMessages(ctx, clientMessageSource{c: c, q: q}, true, false)(yield)
+	}
+}
+
+// clientMessageSource reads from the client's query. Closing it closes the
+// whole client, not just the query, so the client does not keep a query
+// whose stream was abandoned mid-response.
+type clientMessageSource struct {
+	c *ClaudeSDKClient
+	q Query
+}
+
+func (s clientMessageSource) Next(ctx context.Context) (SDKMessage, error) {
+	return s.q.Next(ctx)
+}
+
+func (s clientMessageSource) Close() error {
+	return s.c.Close()
+}
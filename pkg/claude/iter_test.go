@@ -0,0 +1,98 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestIterateMessages_YieldsTypedErrors(t *testing.T) {
+	streamErr := clauderrs.NewBufferSizeExceededError(1024, 2048, "json_accumulation")
+	stub := &stubSimpleQuery{responses: []stubSimpleQueryResponse{
+		{msg: &SDKAssistantMessage{}},
+		{err: streamErr},
+	}}
+
+	var msgs int
+	var gotErr error
+	for msg, err := range iterateMessages(context.Background(), stub, false, true) {
+		if err != nil {
+			gotErr = err
+
+			continue
+		}
+		if msg == nil {
+			t.Fatal("expected message with nil error")
+		}
+		msgs++
+	}
+
+	if msgs != 1 {
+		t.Fatalf("expected 1 message, got %d", msgs)
+	}
+	var bufErr *clauderrs.BufferError
+	if !errors.As(gotErr, &bufErr) {
+		t.Fatalf("expected original *clauderrs.BufferError, got %T", gotErr)
+	}
+	if !stub.closed {
+		t.Fatal("expected source to be closed after error")
+	}
+}
+
+func TestIterateMessages_BreakClosesSource(t *testing.T) {
+	stub := &stubSimpleQuery{responses: []stubSimpleQueryResponse{
+		{msg: &SDKAssistantMessage{}},
+		{msg: &SDKAssistantMessage{}},
+		{msg: &SDKResultMessage{}},
+	}}
+
+	for range iterateMessages(context.Background(), stub, true, false) {
+		break
+	}
+
+	if !stub.closed {
+		t.Fatal("expected source to be closed when the loop breaks")
+	}
+	if len(stub.responses) != 2 {
+		t.Fatalf("expected iteration to stop after one message, %d left", len(stub.responses))
+	}
+}
+
+func TestIterateMessages_StopsAtResult(t *testing.T) {
+	stub := &stubSimpleQuery{responses: []stubSimpleQueryResponse{
+		{msg: &SDKAssistantMessage{}},
+		{msg: &SDKResultMessage{}},
+		{msg: &SDKAssistantMessage{}},
+	}}
+
+	var count int
+	for _, err := range iterateMessages(context.Background(), stub, true, false) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		count++
+	}
+
+	if count != 2 {
+		t.Fatalf("expected iteration to end at the result, got %d messages", count)
+	}
+	if stub.closed {
+		t.Fatal("expected source to stay open after a complete response")
+	}
+}
+
+func TestClientMessages_NoActiveQuery(t *testing.T) {
+	c, _ := NewClient(nil)
+
+	for _, err := range c.Messages(context.Background()) {
+		if !clauderrs.IsClientError(err) {
+			t.Fatalf("expected client error, got %v", err)
+		}
+
+		return
+	}
+
+	t.Fatal("expected an error to be yielded")
+}
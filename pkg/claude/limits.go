@@ -0,0 +1,127 @@
+package claude
+
+import (
+	"errors"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/internal/transport"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// IOPriorityClass is an IO scheduling class, as set by ionice.
+type IOPriorityClass int
+
+const (
+	// IOPriorityDefault leaves the IO priority unchanged.
+	IOPriorityDefault IOPriorityClass = iota
+	// IOPriorityRealtime is served before everything else; setting it
+	// requires CAP_SYS_ADMIN.
+	IOPriorityRealtime
+	// IOPriorityBestEffort is the normal class, ordered by IOPriorityLevel.
+	IOPriorityBestEffort
+	// IOPriorityIdle only gets disk time no other process wants.
+	IOPriorityIdle
+)
+
+// ResourceLimits constrains the CLI process. The limits are in place before
+// the CLI starts and are inherited by the tool subprocesses it runs (Bash
+// commands, MCP servers), so a runaway tool cannot take the host down.
+//
+// All limits except WallTimeout are Linux-only; on other platforms setting
+// them makes the query fail to start. Zero fields are left unchanged, and
+// raising a limit above the SDK process's own hard limit, or lowering Nice
+// below zero, requires privileges.
+//
+// When a limit stops the CLI, the query fails with a *clauderrs.ProcessError
+// with code clauderrs.ErrCodeProcessLimitExceeded whose Limit method names
+// the limit. CPU time and the wall-clock timeout are detected exactly; the
+// address space, open file and process limits only make system calls fail,
+// so they are recognised from the CLI's stderr.
+type ResourceLimits struct {
+	// AddressSpace is the largest virtual memory size, in bytes, of each
+	// process (RLIMIT_AS). Node.js reserves a lot of address space up
+	// front, so leave generous headroom.
+	AddressSpace uint64
+	// CPUTime is the CPU time each process may use, rounded up to whole
+	// seconds (RLIMIT_CPU).
+	CPUTime time.Duration
+	// OpenFiles is the number of file descriptors each process may have
+	// open (RLIMIT_NOFILE).
+	OpenFiles uint64
+	// Processes caps the processes the CLI's user may have (RLIMIT_NPROC).
+	// It counts all of the user's processes, not only the CLI's, so it is
+	// most useful together with Options.User.
+	Processes uint64
+	// CoreSize is the largest core dump, in bytes (RLIMIT_CORE). Nil leaves
+	// it unchanged; a pointer to zero disables core dumps.
+	CoreSize *uint64
+	// Nice is the scheduling niceness of the CLI's process group, from -20
+	// to 19.
+	Nice int
+	// IOPriority and IOPriorityLevel set the IO priority of the CLI's
+	// process group. The level goes from 0 (highest) to 7 and applies to
+	// the realtime and best-effort classes.
+	IOPriority      IOPriorityClass
+	IOPriorityLevel int
+	// WallTimeout terminates the CLI's whole process group once it has run
+	// this long: SIGTERM first, then SIGKILL after ShutdownGracePeriod.
+	WallTimeout time.Duration
+}
+
+// validateResourceLimits checks the ranges of the limits.
+func validateResourceLimits(limits *ResourceLimits) error {
+	if limits == nil {
+		return nil
+	}
+
+	switch {
+	case limits.CPUTime < 0:
+		return limitRangeError("CPUTime", "CPU time must not be negative", limits.CPUTime)
+	case limits.WallTimeout < 0:
+		return limitRangeError("WallTimeout", "wall-clock timeout must not be negative", limits.WallTimeout)
+	case limits.Nice < -20 || limits.Nice > 19:
+		return limitRangeError("Nice", "nice value must be between -20 and 19", limits.Nice)
+	case limits.IOPriority < IOPriorityDefault || limits.IOPriority > IOPriorityIdle:
+		return limitRangeError("IOPriority", "unknown IO priority class", limits.IOPriority)
+	case limits.IOPriorityLevel < 0 || limits.IOPriorityLevel > 7:
+		return limitRangeError("IOPriorityLevel", "IO priority level must be between 0 and 7", limits.IOPriorityLevel)
+	}
+
+	return nil
+}
+
+func limitRangeError(field, message string, value any) error {
+	return clauderrs.NewValidationError(
+		clauderrs.ErrCodeRangeViolation,
+		message,
+		nil,
+		"ResourceLimits."+field,
+		value,
+	)
+}
+
+// processLimits converts the limits for the process transport.
+func (l *ResourceLimits) processLimits() *transport.ResourceLimits {
+	if l == nil {
+		return nil
+	}
+
+	return &transport.ResourceLimits{
+		AddressSpace: l.AddressSpace,
+		CPUTime:      l.CPUTime,
+		OpenFiles:    l.OpenFiles,
+		Processes:    l.Processes,
+		CoreSize:     l.CoreSize,
+		Nice:         l.Nice,
+		IOClass:      int(l.IOPriority),
+		IOLevel:      l.IOPriorityLevel,
+		WallTimeout:  l.WallTimeout,
+	}
+}
+
+// isLimitExceeded reports whether err is a CLI stopped by its resource limits.
+func isLimitExceeded(err error) bool {
+	var procErr *clauderrs.ProcessError
+
+	return errors.As(err, &procErr) && procErr.Limit() != ""
+}
@@ -0,0 +1,96 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestValidateResourceLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits *ResourceLimits
+		field  string
+	}{
+		{"nil", nil, ""},
+		{"valid", &ResourceLimits{Nice: 10, IOPriority: IOPriorityBestEffort, IOPriorityLevel: 7}, ""},
+		{"negative CPU time", &ResourceLimits{CPUTime: -time.Second}, "ResourceLimits.CPUTime"},
+		{"negative wall timeout", &ResourceLimits{WallTimeout: -time.Second}, "ResourceLimits.WallTimeout"},
+		{"nice out of range", &ResourceLimits{Nice: 20}, "ResourceLimits.Nice"},
+		{"unknown IO class", &ResourceLimits{IOPriority: 9}, "ResourceLimits.IOPriority"},
+		{"IO level out of range", &ResourceLimits{IOPriorityLevel: 8}, "ResourceLimits.IOPriorityLevel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceLimits(tt.limits)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+
+				return
+			}
+
+			var valErr *clauderrs.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field() != tt.field {
+				t.Fatalf("expected validation error for %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestQuery_WallTimeoutReportsLimit(t *testing.T) {
+	cli := writeFakeCLI(t, `#!/bin/sh
+case "$1" in --version|--help)
+	echo "claude version 2.1.0"
+	exit 0
+esac
+while :; do sleep 0.05; done
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	q, err := QueryFunc("hello", &Options{
+		PathToClaudeCodeExecutable: cli,
+		ResourceLimits:             &ResourceLimits{WallTimeout: 200 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	_, err = q.Next(ctx)
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) {
+		t.Fatalf("expected ProcessError, got %v", err)
+	}
+	if procErr.Code() != clauderrs.ErrCodeProcessLimitExceeded || procErr.Limit() != clauderrs.LimitWallClock {
+		t.Errorf("expected wall clock limit, got code %s limit %q", procErr.Code(), procErr.Limit())
+	}
+}
+
+func TestSupervisedQuery_DoesNotRestartOnLimitExceeded(t *testing.T) {
+	q := newChanQuery()
+	starts := 0
+	s, err := newSupervisedQuery("", &Options{Restart: &RestartPolicy{}}, func(string, *Options) (Query, error) {
+		starts++
+
+		return q, nil
+	})
+	if err != nil {
+		t.Fatalf("newSupervisedQuery: %v", err)
+	}
+
+	limitErr := clauderrs.NewProcessError(clauderrs.ErrCodeProcessLimitExceeded, "CPU time", nil, -1, "").
+		WithLimit(clauderrs.LimitCPUTime)
+	q.errs <- limitErr
+
+	if _, err := s.Next(context.Background()); !errors.Is(err, limitErr) || starts != 1 {
+		t.Errorf("expected limit error without restart, got %v after %d starts", err, starts)
+	}
+}
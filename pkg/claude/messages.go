@@ -101,8 +101,9 @@ type ContentBlock interface {
 
 // TextContentBlock represents text content.
 type TextContentBlock struct {
-	Type string `json:"type"` // "text"
-	Text string `json:"text"`
+	Type      string     `json:"type"` // "text"
+	Text      string     `json:"text"`
+	Citations []Citation `json:"citations,omitempty"`
 }
 
 func (TextContentBlock) contentBlock() {}
@@ -194,18 +195,255 @@ func (ToolResultContentBlock) contentBlock() {}
 type ThinkingBlock struct {
 	Type     string `json:"type"` // "thinking"
 	Thinking string `json:"thinking"`
+	// Signature verifies the thinking content when it is sent back to the API.
+	Signature string `json:"signature,omitempty"`
 }
 
 func (ThinkingBlock) contentBlock() {}
 
+// RedactedThinkingBlock represents thinking content that was encrypted by the
+// API's safety systems. Data is opaque and must be passed back unchanged.
+type RedactedThinkingBlock struct {
+	Type string `json:"type"` // "redacted_thinking"
+	Data string `json:"data"`
+}
+
+func (RedactedThinkingBlock) contentBlock() {}
+
 // TextBlock represents text content.
 type TextBlock struct {
-	Type string `json:"type"` // "text"
-	Text string `json:"text"`
+	Type      string     `json:"type"` // "text"
+	Text      string     `json:"text"`
+	Citations []Citation `json:"citations,omitempty"`
 }
 
 func (TextBlock) contentBlock() {}
 
+// Citation types.
+const (
+	CitationTypeCharLocation            = "char_location"
+	CitationTypePageLocation            = "page_location"
+	CitationTypeContentBlockLocation    = "content_block_location"
+	CitationTypeWebSearchResultLocation = "web_search_result_location"
+	CitationTypeSearchResultLocation    = "search_result_location"
+)
+
+// Citation points from generated text to the source it was drawn from.
+// Which location fields are set depends on Type: character ranges for plain
+// text documents, page ranges for PDFs, block ranges for custom content
+// documents and search results, and URL/title for web search results.
+type Citation struct {
+	Type      string `json:"type"`
+	CitedText string `json:"cited_text"`
+
+	// Document citations (char, page and content block locations).
+	DocumentIndex *int    `json:"document_index,omitempty"`
+	DocumentTitle *string `json:"document_title,omitempty"`
+
+	StartCharIndex *int `json:"start_char_index,omitempty"`
+	EndCharIndex   *int `json:"end_char_index,omitempty"`
+
+	StartPageNumber *int `json:"start_page_number,omitempty"`
+	EndPageNumber   *int `json:"end_page_number,omitempty"`
+
+	StartBlockIndex *int `json:"start_block_index,omitempty"`
+	EndBlockIndex   *int `json:"end_block_index,omitempty"`
+
+	// Web search result citations.
+	URL            string  `json:"url,omitempty"`
+	Title          *string `json:"title,omitempty"`
+	EncryptedIndex string  `json:"encrypted_index,omitempty"`
+
+	// Search result citations.
+	Source            string `json:"source,omitempty"`
+	SearchResultIndex *int   `json:"search_result_index,omitempty"`
+}
+
+// ServerToolUseBlock represents a tool invocation executed by the API itself
+// (for example web search) rather than by Claude Code.
+type ServerToolUseBlock struct {
+	Type  string    `json:"type"` // "server_tool_use"
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	Input JSONValue `json:"input"`
+}
+
+func (ServerToolUseBlock) contentBlock() {}
+
+// WebSearchToolResultBlock carries the results of a server-side web search.
+type WebSearchToolResultBlock struct {
+	Type      string                     `json:"type"` // "web_search_tool_result"
+	ToolUseID string                     `json:"tool_use_id"`
+	Content   WebSearchToolResultContent `json:"content"`
+}
+
+func (WebSearchToolResultBlock) contentBlock() {}
+
+// WebSearchToolResultContent is either a list of results or an error.
+type WebSearchToolResultContent struct {
+	Results []WebSearchResult         // Present when the search succeeded
+	Error   *WebSearchToolResultError // Mutually exclusive with Results
+}
+
+// WebSearchResult is a single web search hit.
+type WebSearchResult struct {
+	Type             string  `json:"type"` // "web_search_result"
+	URL              string  `json:"url"`
+	Title            string  `json:"title"`
+	EncryptedContent string  `json:"encrypted_content"`
+	PageAge          *string `json:"page_age,omitempty"`
+}
+
+// WebSearchToolResultError reports a failed web search.
+type WebSearchToolResultError struct {
+	Type      string `json:"type"` // "web_search_tool_result_error"
+	ErrorCode string `json:"error_code"`
+}
+
+// MarshalJSON encodes the union as either an error object or a result array.
+func (c WebSearchToolResultContent) MarshalJSON() ([]byte, error) {
+	if c.Error != nil {
+		return json.Marshal(c.Error)
+	}
+	if c.Results == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(c.Results)
+}
+
+// UnmarshalJSON decodes the union form.
+func (c *WebSearchToolResultContent) UnmarshalJSON(data []byte) error {
+	var results []WebSearchResult
+	if err := json.Unmarshal(data, &results); err == nil {
+		c.Results = results
+		c.Error = nil
+
+		return nil
+	}
+
+	var searchErr WebSearchToolResultError
+	err := json.Unmarshal(data, &searchErr)
+	if err == nil && searchErr.Type != "web_search_tool_result_error" {
+		err = fmt.Errorf("unexpected content type %q", searchErr.Type)
+	}
+	if err != nil {
+		return clauderrs.NewProtocolError(
+			clauderrs.ErrCodeInvalidMessage,
+			"web search result content must be a result array or an error",
+			err,
+		).WithMessageType("web_search_tool_result")
+	}
+	c.Error = &searchErr
+	c.Results = nil
+
+	return nil
+}
+
+// Document source types.
+const (
+	DocumentSourceTypeBase64  = "base64"
+	DocumentSourceTypeText    = "text"
+	DocumentSourceTypeURL     = "url"
+	DocumentSourceTypeContent = "content"
+)
+
+// DocumentContentBlock represents a document (PDF, plain text or custom
+// content) supplied to or echoed by the model.
+type DocumentContentBlock struct {
+	Type      string           `json:"type"` // "document"
+	Source    DocumentSource   `json:"source"`
+	Title     string           `json:"title,omitempty"`
+	Context   string           `json:"context,omitempty"`
+	Citations *CitationsConfig `json:"citations,omitempty"`
+}
+
+func (DocumentContentBlock) contentBlock() {}
+
+// DocumentSource describes where a document's content comes from.
+//   - base64: MediaType and Data (e.g. "application/pdf")
+//   - text:   MediaType ("text/plain") and Data
+//   - url:    URL
+//   - content: Content blocks (text and images)
+type DocumentSource struct {
+	Type      string         `json:"type"`
+	MediaType string         `json:"media_type,omitempty"`
+	Data      string         `json:"data,omitempty"`
+	URL       string         `json:"url,omitempty"`
+	Content   []ContentBlock `json:"content,omitempty"`
+}
+
+// UnmarshalJSON decodes a document source. Content may be a string, which is
+// decoded as a single text block.
+func (s *DocumentSource) UnmarshalJSON(data []byte) error {
+	type Alias struct {
+		Type      string          `json:"type"`
+		MediaType string          `json:"media_type"`
+		Data      string          `json:"data"`
+		URL       string          `json:"url"`
+		Content   json.RawMessage `json:"content"`
+	}
+
+	var aux Alias
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return clauderrs.NewProtocolError(
+			clauderrs.ErrCodeMessageParseFailed,
+			"failed to parse document source",
+			err,
+		).WithMessageType("document")
+	}
+
+	s.Type = aux.Type
+	s.MediaType = aux.MediaType
+	s.Data = aux.Data
+	s.URL = aux.URL
+	s.Content = nil
+
+	if len(aux.Content) == 0 || string(aux.Content) == "null" {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(aux.Content, &text); err == nil {
+		s.Content = []ContentBlock{TextContentBlock{Type: "text", Text: text}}
+
+		return nil
+	}
+
+	blocks, err := decodeContentBlocks(aux.Content)
+	if err != nil {
+		return err
+	}
+	s.Content = blocks
+
+	return nil
+}
+
+// CitationsConfig enables citations for a document.
+type CitationsConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RawContentBlock preserves a content block whose type this SDK does not
+// model. It re-encodes to the original JSON.
+type RawContentBlock struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (RawContentBlock) contentBlock() {}
+
+// MarshalJSON returns the original block JSON.
+func (b RawContentBlock) MarshalJSON() ([]byte, error) {
+	if len(b.Raw) == 0 {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+		}{b.Type})
+	}
+
+	return b.Raw, nil
+}
+
 // decodeContentBlocks converts raw JSON array into typed content blocks.
 func decodeContentBlocks(data []byte) ([]ContentBlock, error) {
 	var rawBlocks []json.RawMessage
@@ -301,13 +539,38 @@ func decodeContentBlock(data []byte) (ContentBlock, error) {
 		}
 
 		return block, nil
+	// Block types added after the ones above are kept as RawContentBlock
+	// if their shape does not match, so a CLI schema change cannot fail the
+	// whole message.
+	case "redacted_thinking":
+		return decodeOrRaw[RedactedThinkingBlock](envelope.Type, data), nil
+	case "server_tool_use":
+		return decodeOrRaw[ServerToolUseBlock](envelope.Type, data), nil
+	case "web_search_tool_result":
+		return decodeOrRaw[WebSearchToolResultBlock](envelope.Type, data), nil
+	case "document":
+		return decodeOrRaw[DocumentContentBlock](envelope.Type, data), nil
 	default:
-		return nil, clauderrs.NewProtocolError(
-			clauderrs.ErrCodeUnknownMessageType,
-			fmt.Sprintf("unsupported content block type: %s", envelope.Type),
-			nil,
-		).WithMessageType(envelope.Type)
+		// Preserve block types this SDK does not model yet.
+		return RawContentBlock{
+			Type: envelope.Type,
+			Raw:  append(json.RawMessage(nil), data...),
+		}, nil
+	}
+}
+
+// decodeOrRaw decodes a content block of type T, or returns it as a
+// RawContentBlock if it does not decode.
+func decodeOrRaw[T ContentBlock](blockType string, data []byte) ContentBlock {
+	var block T
+	if err := json.Unmarshal(data, &block); err != nil {
+		return RawContentBlock{
+			Type: blockType,
+			Raw:  append(json.RawMessage(nil), data...),
+		}
 	}
+
+	return block
 }
 
 // SDKAssistantMessage represents an assistant response.
@@ -460,8 +723,16 @@ type ContentBlockStopEvent struct {
 func (ContentBlockStopEvent) EventType() string { return "content_block_stop" }
 
 type MessageDeltaEvent struct {
-	Type  string `json:"type"` // "message_delta"
-	Usage *Usage `json:"usage,omitempty"`
+	Type  string       `json:"type"` // "message_delta"
+	Delta MessageDelta `json:"delta"`
+	Usage *Usage       `json:"usage,omitempty"`
+}
+
+// MessageDelta carries top-level message changes reported at the end of a
+// streamed message.
+type MessageDelta struct {
+	StopReason   *string `json:"stop_reason,omitempty"`
+	StopSequence *string `json:"stop_sequence,omitempty"`
 }
 
 func (MessageDeltaEvent) EventType() string { return "message_delta" }
@@ -472,18 +743,42 @@ type MessageStopEvent struct {
 
 func (MessageStopEvent) EventType() string { return "message_stop" }
 
-// ContentDelta represents partial updates to a text or tool block.
+// Content delta types.
+const (
+	DeltaTypeText      = "text_delta"
+	DeltaTypeInputJSON = "input_json_delta"
+	DeltaTypeThinking  = "thinking_delta"
+	DeltaTypeSignature = "signature_delta"
+	DeltaTypeCitations = "citations_delta"
+)
+
+// ContentDelta represents partial updates to a content block. Type selects
+// which field is set; unknown delta types only carry Type.
 type ContentDelta struct {
+	Type string `json:"type"`
+	// TextDelta is appended to a text block (text_delta).
 	TextDelta *string `json:"text_delta,omitempty"`
-	// Additional delta fields handled as needed (e.g., tool call streaming)
+	// PartialJSON is appended to a tool input (input_json_delta). It may be
+	// empty, and concatenated fragments only form valid JSON once the block
+	// stops.
+	PartialJSON *string `json:"partial_json,omitempty"`
+	// Thinking is appended to a thinking block (thinking_delta).
+	Thinking *string `json:"thinking,omitempty"`
+	// Signature sets the signature of a thinking block (signature_delta).
+	Signature *string `json:"signature,omitempty"`
+	// Citation is added to a text block (citations_delta).
+	Citation *Citation `json:"citation,omitempty"`
 }
 
 // decodeContentDelta converts raw JSON into a typed delta representation.
 func decodeContentDelta(data []byte) (ContentDelta, error) {
 	var envelope struct {
-		Type        string `json:"type"`
-		Text        string `json:"text,omitempty"`
-		PartialJson string `json:"partial_Json,omitempty"`
+		Type        string    `json:"type"`
+		Text        *string   `json:"text"`
+		PartialJSON *string   `json:"partial_json"`
+		Thinking    *string   `json:"thinking"`
+		Signature   *string   `json:"signature"`
+		Citation    *Citation `json:"citation"`
 	}
 	if err := json.Unmarshal(data, &envelope); err != nil {
 		return ContentDelta{}, clauderrs.NewProtocolError(
@@ -493,31 +788,46 @@ func decodeContentDelta(data []byte) (ContentDelta, error) {
 		)
 	}
 
+	delta := ContentDelta{Type: envelope.Type}
+	var missing string
+
 	switch envelope.Type {
-	case "text_delta", "input_json_delta":
-		// "input_json_delta" also carries text payloads for current protocol.
-		if envelope.Text == "" && envelope.PartialJson == "" {
-			return ContentDelta{}, clauderrs.NewProtocolError(
-				clauderrs.ErrCodeInvalidMessage,
-				"text delta payload missing text field",
-				nil,
-			).WithMessageType(envelope.Type)
+	case DeltaTypeText:
+		delta.TextDelta = envelope.Text
+		if delta.TextDelta == nil {
+			missing = "text"
 		}
-		text := envelope.Text
-		if text == "" {
-			text = envelope.PartialJson
+	case DeltaTypeInputJSON:
+		delta.PartialJSON = envelope.PartialJSON
+		if delta.PartialJSON == nil {
+			missing = "partial_json"
 		}
-		return ContentDelta{TextDelta: &text}, nil
-	default:
+	case DeltaTypeThinking:
+		delta.Thinking = envelope.Thinking
+		if delta.Thinking == nil {
+			missing = "thinking"
+		}
+	case DeltaTypeSignature:
+		delta.Signature = envelope.Signature
+		if delta.Signature == nil {
+			missing = "signature"
+		}
+	case DeltaTypeCitations:
+		delta.Citation = envelope.Citation
+		if delta.Citation == nil {
+			missing = "citation"
+		}
+	}
+
+	if missing != "" {
 		return ContentDelta{}, clauderrs.NewProtocolError(
-			clauderrs.ErrCodeUnknownMessageType,
-			fmt.Sprintf(
-				"unsupported content delta type: %s",
-				envelope.Type,
-			),
+			clauderrs.ErrCodeInvalidMessage,
+			fmt.Sprintf("%s payload missing %s field", envelope.Type, missing),
 			nil,
 		).WithMessageType(envelope.Type)
 	}
+
+	return delta, nil
 }
 
 // decodeRawMessageStreamEvent converts a raw JSON event into the proper struct.
@@ -659,10 +969,15 @@ func decodeRawMessageStreamEvent(
 }
 
 // SDKSystemMessage represents system information.
+//
+// System messages with a known subtype are delivered as their concrete type
+// (see SystemInitMessage, SDKCompactBoundaryMessage, SDKStatusMessage and
+// SDKHookResponseMessage). Other subtypes are delivered as *SDKSystemMessage
+// with Data holding the raw top-level fields.
 type SDKSystemMessage struct {
 	BaseMessage
 	Subtype string               `json:"subtype"`
-	Data    map[string]JSONValue `json:"-"` // Custom data per subtype
+	Data    map[string]JSONValue `json:"-"` // Raw top-level fields of the message
 }
 
 func (SDKSystemMessage) Type() string { return "system" }
@@ -705,6 +1020,74 @@ type CompactMetadata struct {
 	PreTokens int    `json:"pre_tokens"`
 }
 
+// System message subtypes.
+const (
+	SystemSubtypeInit            = "init"
+	SystemSubtypeCompactBoundary = "compact_boundary"
+	SystemSubtypeStatus          = "status"
+	SystemSubtypeHookResponse    = "hook_response"
+)
+
+// decodeSystemMessage decodes a system message into the concrete type for its
+// subtype: *SystemInitMessage, *SDKCompactBoundaryMessage, *SDKStatusMessage
+// or *SDKHookResponseMessage. Unknown subtypes decode into *SDKSystemMessage.
+// For SDKSystemMessage (including the embedded one in typed messages) Data
+// holds every top-level field as raw JSON so no information is lost.
+func decodeSystemMessage(data []byte) (SDKMessage, error) {
+	var fields map[string]JSONValue
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var subtype string
+	if raw, ok := fields[fieldSubtype]; ok {
+		if err := json.Unmarshal(raw, &subtype); err != nil {
+			return nil, err
+		}
+	}
+
+	switch subtype {
+	case SystemSubtypeInit:
+		var msg SystemInitMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+		msg.Data = fields
+
+		return &msg, nil
+	case SystemSubtypeCompactBoundary:
+		var msg SDKCompactBoundaryMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+		msg.Data = fields
+
+		return &msg, nil
+	case SystemSubtypeStatus:
+		var msg SDKStatusMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+
+		return &msg, nil
+	case SystemSubtypeHookResponse:
+		var msg SDKHookResponseMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+
+		return &msg, nil
+	default:
+		var msg SDKSystemMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+		msg.Data = fields
+
+		return &msg, nil
+	}
+}
+
 // SDKResultMessage represents final query result.
 type SDKResultMessage struct {
 	BaseMessage
@@ -747,6 +1130,31 @@ const (
 	ResultSubtypeErrorDuringExecution = "error_during_execution"
 )
 
+// Err returns a *clauderrs.ResultError when the result reports a failure,
+// and nil for successful results.
+func (m SDKResultMessage) Err() error {
+	if !m.IsError && (m.Subtype == "" || m.Subtype == ResultSubtypeSuccess) {
+		return nil
+	}
+
+	code := clauderrs.ErrCodeResultExecutionFailed
+	switch m.Subtype {
+	case ResultSubtypeErrorMaxTurns:
+		code = clauderrs.ErrCodeResultMaxTurns
+	case ResultSubtypeErrorMaxBudgetUsd:
+		code = clauderrs.ErrCodeResultMaxBudget
+	case ResultSubtypeErrorMaxStructuredOutputRetries:
+		code = clauderrs.ErrCodeResultMaxStructuredOutputRetries
+	}
+
+	errs := m.Errors
+	if len(errs) == 0 && m.Result != nil && *m.Result != "" {
+		errs = []string{*m.Result}
+	}
+
+	return clauderrs.NewResultError(code, m.Subtype, errs).WithSessionID(m.SessionIDField)
+}
+
 // SDKPermissionDenial represents a denied tool use.
 type SDKPermissionDenial struct {
 	ToolName  string               `json:"tool_name"`
@@ -785,6 +1193,68 @@ type SDKAuthStatusMessage struct {
 
 func (SDKAuthStatusMessage) Type() string { return "auth_status" }
 
+// SDKUnknownMessage carries a stream line the SDK could not decode. It is only
+// produced when Options.LenientMessageDecoding is enabled.
+type SDKUnknownMessage struct {
+	BaseMessage
+	// TypeField is the line's "type" value, or empty if it could not be read.
+	TypeField string
+	// Raw holds the undecoded line.
+	Raw json.RawMessage
+	// Err describes why decoding failed.
+	Err error
+}
+
+func (m SDKUnknownMessage) Type() string { return m.TypeField }
+
+// newUnknownMessage wraps an undecodable line, keeping the base fields when
+// they can be read.
+func newUnknownMessage(msgType string, data []byte, err error) *SDKUnknownMessage {
+	msg := &SDKUnknownMessage{
+		TypeField: msgType,
+		Raw:       append(json.RawMessage(nil), data...),
+		Err:       err,
+	}
+	_ = json.Unmarshal(data, &msg.BaseMessage)
+
+	return msg
+}
+
+// SDKRestartMessage is emitted by the SDK, not the CLI, when the restart
+// supervisor (see RestartPolicy) has respawned a crashed CLI.
+type SDKRestartMessage struct {
+	BaseMessage
+	// Attempt numbers the restart within the current RestartPolicy.Window,
+	// starting at 1.
+	Attempt int `json:"attempt"`
+	// Cause is the error that triggered the restart, normally a
+	// *clauderrs.ProcessError.
+	Cause error `json:"-"`
+	// ResumedSessionID is the session passed to --resume, or empty if the CLI
+	// crashed before reporting one and a new session was started.
+	ResumedSessionID string `json:"resumed_session_id,omitempty"`
+	// ReplayedMessages is the number of unanswered user messages sent again
+	// to the new process.
+	ReplayedMessages int `json:"replayed_messages"`
+}
+
+func (SDKRestartMessage) Type() string { return "restart" }
+
+// SDKWarningMessage is emitted by the SDK, not the CLI, ahead of the CLI's
+// messages when an option was ignored because the installed CLI has no flag
+// for it.
+type SDKWarningMessage struct {
+	BaseMessage
+	// Option is the ignored Options field, for example
+	// "IncludePartialMessages".
+	Option string `json:"option"`
+	// Flag is the CLI flag the option needs.
+	Flag    string `json:"flag"`
+	Message string `json:"message"`
+}
+
+func (SDKWarningMessage) Type() string { return "warning" }
+
 // SDKStatusMessage represents system-level status notifications such as
 // message compaction operations. It extends SDKSystemMessage with a
 // status-specific subtype.
@@ -867,6 +1337,19 @@ type SDKControlRequest struct {
 
 func (SDKControlRequest) Type() string { return ControlRequest }
 
+// MarshalJSON ensures the type field is always set to "control_request".
+func (r SDKControlRequest) MarshalJSON() ([]byte, error) {
+	type Alias SDKControlRequest
+
+	return json.Marshal(&struct {
+		TypeField string `json:"type"`
+		*Alias
+	}{
+		TypeField: ControlRequest,
+		Alias:     (*Alias)(&r),
+	})
+}
+
 // ControlRequestVariant is the interface for all control request variants.
 type ControlRequestVariant interface {
 	// Subtype returns the control request subtype string.
@@ -1091,6 +1574,19 @@ type SDKControlResponse struct {
 
 func (SDKControlResponse) Type() string { return "control_response" }
 
+// MarshalJSON ensures the type field is always set to "control_response".
+func (r SDKControlResponse) MarshalJSON() ([]byte, error) {
+	type Alias SDKControlResponse
+
+	return json.Marshal(&struct {
+		TypeField string `json:"type"`
+		*Alias
+	}{
+		TypeField: "control_response",
+		Alias:     (*Alias)(&r),
+	})
+}
+
 // ControlResponseVariant is the interface for all control response variants.
 type ControlResponseVariant interface {
 	// Subtype returns the control response variant's subtype.
@@ -0,0 +1,110 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeSystemMessage_Init(t *testing.T) {
+	data := []byte(`{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet",` +
+		`"cwd":"/work","tools":["Read","Bash"],"permissionMode":"default","future_field":42}`)
+
+	msg, err := decodeSystemMessage(data)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	init, ok := msg.(*SystemInitMessage)
+	if !ok {
+		t.Fatalf("expected *SystemInitMessage, got %T", msg)
+	}
+	if init.Model != "claude-sonnet" || init.Cwd != "/work" || len(init.Tools) != 2 {
+		t.Errorf("unexpected init fields: %+v", init)
+	}
+	if init.SessionID() != "s1" || init.Subtype != SystemSubtypeInit {
+		t.Errorf("unexpected base fields: session=%q subtype=%q", init.SessionID(), init.Subtype)
+	}
+	if string(init.Data["future_field"]) != "42" {
+		t.Errorf("expected unmodeled field preserved in Data, got %v", init.Data)
+	}
+}
+
+func TestDecodeSystemMessage_Subtypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		check func(t *testing.T, msg SDKMessage)
+	}{
+		{
+			name: "compact boundary",
+			data: `{"type":"system","subtype":"compact_boundary","compact_metadata":{"trigger":"auto","pre_tokens":1234}}`,
+			check: func(t *testing.T, msg SDKMessage) {
+				m, ok := msg.(*SDKCompactBoundaryMessage)
+				if !ok {
+					t.Fatalf("expected *SDKCompactBoundaryMessage, got %T", msg)
+				}
+				if m.CompactMetadata.PreTokens != 1234 || m.CompactMetadata.Trigger != "auto" {
+					t.Errorf("unexpected metadata: %+v", m.CompactMetadata)
+				}
+			},
+		},
+		{
+			name: "status",
+			data: `{"type":"system","subtype":"status","status":"compacting"}`,
+			check: func(t *testing.T, msg SDKMessage) {
+				m, ok := msg.(*SDKStatusMessage)
+				if !ok {
+					t.Fatalf("expected *SDKStatusMessage, got %T", msg)
+				}
+				if m.Status != SDKStatusCompacting {
+					t.Errorf("unexpected status %q", m.Status)
+				}
+			},
+		},
+		{
+			name: "hook response",
+			data: `{"type":"system","subtype":"hook_response","hook_name":"lint","hook_event":"PostToolUse","stdout":"ok","stderr":"","exit_code":0}`,
+			check: func(t *testing.T, msg SDKMessage) {
+				m, ok := msg.(*SDKHookResponseMessage)
+				if !ok {
+					t.Fatalf("expected *SDKHookResponseMessage, got %T", msg)
+				}
+				if m.HookName != "lint" || m.ExitCode == nil || *m.ExitCode != 0 {
+					t.Errorf("unexpected hook response: %+v", m)
+				}
+			},
+		},
+		{
+			name: "unknown subtype",
+			data: `{"type":"system","subtype":"something_new","payload":{"a":[1,2]}}`,
+			check: func(t *testing.T, msg SDKMessage) {
+				m, ok := msg.(*SDKSystemMessage)
+				if !ok {
+					t.Fatalf("expected *SDKSystemMessage, got %T", msg)
+				}
+				if m.Subtype != "something_new" {
+					t.Errorf("unexpected subtype %q", m.Subtype)
+				}
+				var payload struct {
+					A []int `json:"a"`
+				}
+				if err := json.Unmarshal(m.Data["payload"], &payload); err != nil || len(payload.A) != 2 {
+					t.Errorf("expected raw payload preserved, got %s (%v)", m.Data["payload"], err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeSystemMessage([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if msg.Type() != "system" {
+				t.Errorf("expected type system, got %q", msg.Type())
+			}
+			tt.check(t, msg)
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package claude
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// MessageOverflowPolicy decides what happens when messages arrive faster than
+// the caller reads them.
+type MessageOverflowPolicy string
+
+const (
+	// MessageOverflowSpill keeps up to Capacity messages in memory and
+	// writes the rest to a temporary file, read back in order. This is the
+	// default. If the file cannot be written, messages stay in memory.
+	MessageOverflowSpill MessageOverflowPolicy = "spill"
+	// MessageOverflowBlock stops reading from the CLI once Capacity messages
+	// are buffered. Control requests and responses stall with it, so a
+	// caller that waits on a control request (SetModel, Interrupt, ...)
+	// without reading messages can deadlock the session; use it only when
+	// neither memory nor disk may grow.
+	MessageOverflowBlock MessageOverflowPolicy = "block"
+	// MessageOverflowGrow buffers unread messages in memory without limit,
+	// so a caller that stops reading lets memory grow without bound.
+	MessageOverflowGrow MessageOverflowPolicy = "grow"
+)
+
+// DefaultMessageBufferCapacity is the number of messages kept in memory by
+// the block and spill policies. It matches the buffer used before overflow
+// policies existed.
+const DefaultMessageBufferCapacity = msgChanBufferSize
+
+// MessageBufferOptions configures buffering between the CLI and the caller.
+//
+// Control requests (can_use_tool, hook callbacks) and control responses are
+// handled as soon as they are read and never wait behind unread messages, so
+// a caller that is busy in a CanUseTool callback or reads slowly does not
+// stall the session. Only the data path (the messages returned by Next) is
+// buffered according to Policy.
+type MessageBufferOptions struct {
+	// Policy selects the overflow behavior. Empty uses MessageOverflowSpill.
+	Policy MessageOverflowPolicy
+	// Capacity is the number of messages kept in memory by the block and
+	// spill policies. Zero uses DefaultMessageBufferCapacity.
+	Capacity int
+	// SpillDir is the directory for the spill file. Empty uses the default
+	// temporary directory.
+	SpillDir string
+}
+
+// queuedMessage is a buffered message. Spilled messages keep only their raw
+// line and are decoded again when read back.
+type queuedMessage struct {
+	msg SDKMessage
+	raw []byte
+}
+
+// messageQueue is the FIFO between the reader goroutine and Next.
+type messageQueue struct {
+	policy   MessageOverflowPolicy
+	capacity int
+	spillDir string
+
+	mu       sync.Mutex
+	mem      []queuedMessage
+	closed   bool
+	released bool
+	err      error
+	// notify is closed and replaced whenever the queue changes.
+	notify chan struct{}
+
+	// Spill file state. Once a message is spilled, later messages are
+	// spilled too until the file has been read back, keeping FIFO order.
+	spill    *os.File
+	spilled  int
+	readOff  int64
+	writeOff int64
+}
+
+func newMessageQueue(opts *MessageBufferOptions) *messageQueue {
+	q := &messageQueue{
+		policy:   MessageOverflowSpill,
+		capacity: DefaultMessageBufferCapacity,
+		notify:   make(chan struct{}),
+	}
+	if opts != nil {
+		if opts.Policy != "" {
+			q.policy = opts.Policy
+		}
+		if opts.Capacity > 0 {
+			q.capacity = opts.Capacity
+		}
+		q.spillDir = opts.SpillDir
+	}
+
+	return q
+}
+
+// push adds a message, blocking under the block policy while the queue is
+// full. It reports false if done was closed first.
+func (q *messageQueue) push(item queuedMessage, done <-chan struct{}) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.released {
+		return false
+	}
+
+	for q.policy == MessageOverflowBlock && len(q.mem) >= q.capacity {
+		notify := q.notify
+		q.mu.Unlock()
+		select {
+		case <-notify:
+		case <-done:
+			q.mu.Lock()
+
+			return false
+		}
+		q.mu.Lock()
+		if q.released {
+			return false
+		}
+	}
+
+	if q.policy == MessageOverflowSpill && (q.spilled > 0 || len(q.mem) >= q.capacity) {
+		// On a spill failure, fall back to memory rather than drop data.
+		if q.writeSpill(item.raw) == nil {
+			q.signal()
+
+			return true
+		}
+	}
+
+	q.mem = append(q.mem, item)
+	q.signal()
+
+	return true
+}
+
+// close ends the queue; pop returns err after the remaining messages.
+func (q *messageQueue) close(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.err = err
+	q.signal()
+}
+
+// pop returns the next message. ok is false once the queue is closed and
+// drained, or done was closed.
+func (q *messageQueue) pop(done <-chan struct{}) (item queuedMessage, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if len(q.mem) > 0 {
+			item = q.mem[0]
+			q.mem[0] = queuedMessage{}
+			q.mem = q.mem[1:]
+			q.signal()
+
+			return item, true
+		}
+
+		if q.spilled > 0 {
+			raw, err := q.readSpill()
+			if err == nil {
+				q.signal()
+
+				return queuedMessage{raw: raw}, true
+			}
+			// The spill file is unreadable; end the stream with the error.
+			q.spilled = 0
+			q.closed = true
+			q.err = clauderrs.NewTransportError(
+				clauderrs.ErrCodeIOError,
+				"failed to read spilled message",
+				err,
+			)
+		}
+
+		if q.closed {
+			return queuedMessage{}, false
+		}
+
+		notify := q.notify
+		q.mu.Unlock()
+		select {
+		case <-notify:
+		case <-done:
+			q.mu.Lock()
+
+			return queuedMessage{}, false
+		}
+		q.mu.Lock()
+	}
+}
+
+// failure returns the error the queue was closed with.
+func (q *messageQueue) failure() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.err
+}
+
+// release discards buffered messages and removes the spill file. Later pushes
+// are rejected.
+func (q *messageQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.released = true
+	q.signal()
+
+	if q.spill != nil {
+		_ = q.spill.Close()
+		_ = os.Remove(q.spill.Name())
+		q.spill = nil
+	}
+	q.mem = nil
+}
+
+// signal wakes waiters. Callers hold q.mu.
+func (q *messageQueue) signal() {
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// writeSpill appends a length-prefixed line to the spill file. Callers hold
+// q.mu.
+func (q *messageQueue) writeSpill(raw []byte) error {
+	if q.spill == nil {
+		f, err := os.CreateTemp(q.spillDir, "claude-messages-*.spill")
+		if err != nil {
+			return err
+		}
+		q.spill = f
+	}
+
+	record := binary.AppendUvarint(nil, uint64(len(raw)))
+	record = append(record, raw...)
+	if _, err := q.spill.WriteAt(record, q.writeOff); err != nil {
+		return err
+	}
+	q.writeOff += int64(len(record))
+	q.spilled++
+
+	return nil
+}
+
+// readSpill reads the oldest spilled line. Callers hold q.mu.
+func (q *messageQueue) readSpill() ([]byte, error) {
+	var header [binary.MaxVarintLen64]byte
+	n, err := q.spill.ReadAt(header[:], q.readOff)
+	if n == 0 {
+		return nil, err
+	}
+	size, headerLen := binary.Uvarint(header[:n])
+	if headerLen <= 0 {
+		return nil, os.ErrInvalid
+	}
+
+	raw := make([]byte, size)
+	if _, err := q.spill.ReadAt(raw, q.readOff+int64(headerLen)); err != nil {
+		return nil, err
+	}
+	q.readOff += int64(headerLen) + int64(size)
+	q.spilled--
+
+	// Reuse the file from the start once it has been drained.
+	if q.spilled == 0 {
+		q.readOff, q.writeOff = 0, 0
+		_ = q.spill.Truncate(0)
+	}
+
+	return raw, nil
+}
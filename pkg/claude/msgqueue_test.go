@@ -0,0 +1,146 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func rawMessage(i int) queuedMessage {
+	return queuedMessage{raw: []byte(fmt.Sprintf(
+		`{"type":"tool_progress","tool_use_id":"t%d","tool_name":"Bash","elapsed_time_seconds":1}`, i))}
+}
+
+func TestMessageQueue_SpillKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	q := newMessageQueue(&MessageBufferOptions{Policy: MessageOverflowSpill, Capacity: 2, SpillDir: dir})
+	done := make(chan struct{})
+
+	for i := range 5 {
+		if !q.push(rawMessage(i), done) {
+			t.Fatal("push rejected")
+		}
+	}
+	if q.spilled != 3 {
+		t.Fatalf("expected 3 spilled messages, got %d", q.spilled)
+	}
+
+	// Memory frees up, but new messages still queue behind the spilled ones.
+	for want := range 3 {
+		item, ok := q.pop(done)
+		if !ok || string(item.raw) != string(rawMessage(want).raw) {
+			t.Fatalf("pop %d: got %q", want, item.raw)
+		}
+	}
+	q.push(rawMessage(5), done)
+	q.close(nil)
+
+	for want := 3; want <= 5; want++ {
+		item, ok := q.pop(done)
+		if !ok || string(item.raw) != string(rawMessage(want).raw) {
+			t.Fatalf("pop %d: got %q", want, item.raw)
+		}
+	}
+	if _, ok := q.pop(done); ok {
+		t.Fatal("expected the queue to be drained")
+	}
+
+	q.release()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected the spill file to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestMessageQueue_BlockWaitsForSpace(t *testing.T) {
+	q := newMessageQueue(&MessageBufferOptions{Policy: MessageOverflowBlock, Capacity: 1})
+	done := make(chan struct{})
+
+	q.push(rawMessage(0), done)
+
+	pushed := make(chan bool, 1)
+	go func() { pushed <- q.push(rawMessage(1), done) }()
+
+	select {
+	case <-pushed:
+		t.Fatal("expected push to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	q.pop(done)
+	if ok := <-pushed; !ok {
+		t.Fatal("expected push to succeed once space was freed")
+	}
+
+	go func() { pushed <- q.push(rawMessage(2), done) }()
+	close(done)
+	if ok := <-pushed; ok {
+		t.Fatal("expected a blocked push to give up when done is closed")
+	}
+}
+
+func TestMessageQueue_DefaultSpillsToDisk(t *testing.T) {
+	q := newMessageQueue(nil)
+	if q.policy != MessageOverflowSpill || q.capacity != DefaultMessageBufferCapacity {
+		t.Fatalf("expected to spill after %d messages, got %s at %d",
+			DefaultMessageBufferCapacity, q.policy, q.capacity)
+	}
+}
+
+// controlBehindDataCLI floods stdout with data messages, then asks for tool
+// permission and only finishes after reading the control response.
+const controlBehindDataCLI = `#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "claude version 2.1.0"
+	exit 0
+fi
+i=0
+while [ $i -lt 300 ]; do
+	echo '{"type":"tool_progress","tool_use_id":"t'$i'","tool_name":"Bash","parent_tool_use_id":null,"elapsed_time_seconds":1}'
+	i=$((i + 1))
+done
+echo '{"type":"control_request","request_id":"req_1","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{},"tool_use_id":"toolu_1"}}'
+while read -r line; do
+	case "$line" in
+	*control_response*)
+		echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":1,"result":"ok"}'
+		exit 0
+		;;
+	esac
+done
+`
+
+func TestQuery_ControlRequestsFlowWhileConsumerIsIdle(t *testing.T) {
+	cli := writeFakeCLI(t, controlBehindDataCLI)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	asked := make(chan struct{})
+	q, err := QueryFunc("hello", &Options{
+		PathToClaudeCodeExecutable: cli,
+		CanUseTool: func(
+			context.Context, string, map[string]JSONValue, []PermissionUpdate,
+			string, *string, *string, *string,
+		) (PermissionResult, error) {
+			close(asked)
+
+			return PermissionAllow{Behavior: PermissionBehaviorAllow}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	// Nothing has been read yet; the permission request must still arrive.
+	select {
+	case <-asked:
+	case <-ctx.Done():
+		t.Fatal("can_use_tool request stalled behind unread messages")
+	}
+
+	if got := drainResults(ctx, t, q); got != 1 {
+		t.Fatalf("expected the result after the control response, got %d results", got)
+	}
+}
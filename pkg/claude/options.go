@@ -1,6 +1,9 @@
 package claude
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // DefaultMaxBufferSize is the default maximum buffer size (1MB) for CLI stdout buffering
 // during JSON message accumulation. This matches the Python SDK default.
@@ -39,6 +42,9 @@ type Options struct {
 	Executable     string // "node", "bun", "deno"
 	ExecutableArgs []string
 	ExtraArgs      map[string]*string
+	// EnvPolicy restricts the environment inherited by the CLI. Nil passes
+	// the SDK process's whole environment; see EnvPolicy and EffectiveEnv.
+	EnvPolicy *EnvPolicy
 
 	// Model configuration
 	Model             string
@@ -101,7 +107,45 @@ type Options struct {
 	// Message handling
 	IncludePartialMessages bool
 
+	// LenientMessageDecoding delivers lines the SDK cannot decode (unknown
+	// message types or malformed payloads) as *SDKUnknownMessage instead of
+	// failing the query. Enable it to keep running against newer CLI
+	// releases that emit message types this SDK does not know yet.
+	LenientMessageDecoding bool
+
+	// Compaction configures callbacks around conversation compaction and an
+	// optional automatic compaction policy. See CompactionOptions.
+	Compaction *CompactionOptions
+
+	// ShutdownGracePeriod is how long Close waits for the CLI to exit after
+	// closing its stdin, and again after SIGTERM, before killing its process
+	// group. Zero uses a default of 5 seconds.
+	ShutdownGracePeriod time.Duration
+
+	// StderrTailSize is how many bytes of the CLI's most recent stderr output
+	// are kept and attached to the *clauderrs.ProcessError returned when it
+	// exits abnormally. The tail is kept whether or not Stderr is set. Zero
+	// uses a default of 32 KB.
+	StderrTailSize int
+
+	// Restart enables the restart supervisor for ClaudeSDKClient: when the
+	// CLI crashes, it is respawned and the session resumed. Nil disables
+	// restarts. See RestartPolicy.
+	Restart *RestartPolicy
+
+	// MessageBuffer configures how messages are buffered when the caller
+	// reads more slowly than the CLI writes. Nil keeps
+	// DefaultMessageBufferCapacity messages in memory and spills the rest to
+	// a temporary file. See MessageBufferOptions.
+	MessageBuffer *MessageBufferOptions
+
+	// TransportFactory creates the transport to the CLI. Nil uses
+	// DefaultTransportFactory, which runs the CLI as a subprocess.
+	TransportFactory TransportFactory
+
 	// SDK-specific
+	// PathToClaudeCodeExecutable is the CLI to run. Empty discovers it: see
+	// FindClaudeExecutable for the locations searched.
 	PathToClaudeCodeExecutable string
 
 	// Settings sources
@@ -117,7 +161,14 @@ type Options struct {
 	Agents map[string]AgentDefinition
 
 	// User specifies the username to run the Claude Code CLI subprocess as.
-	// When set, the subprocess will run with the credentials of the specified user.
+	// When set, the subprocess will run with the credentials of the specified user
+	// and its supplementary groups, with HOME, USER and LOGNAME set for that user
+	// so the CLI reads the user's own ~/.claude configuration (Env can still
+	// override them). Before starting, the SDK checks that the user can access
+	// Cwd (or the current directory).
+	//
+	// Lookup failures, an inaccessible working directory and a missing
+	// privilege to switch users are reported as *clauderrs.PermissionError.
 	//
 	// This feature is Unix-specific and requires appropriate permissions:
 	//   - The parent process must have CAP_SETUID and CAP_SETGID capabilities
@@ -143,6 +194,55 @@ type Options struct {
 	//	    User: "nobody",  // Run as unprivileged user
 	//	}
 	User string
+
+	// Group overrides the primary group of the CLI, by name or numeric GID.
+	// It may be used without User to switch only the group. Unix only.
+	Group string
+
+	// SupplementaryGroups replaces the supplementary groups of the CLI, by
+	// name or numeric GID; an empty, non-nil slice drops them all. Nil uses
+	// the groups of User, or keeps the SDK process's groups when User is
+	// empty. Unix only.
+	SupplementaryGroups []string
+
+	// CommandWrapper is an argv prefix the CLI runs under, for OS-level
+	// isolation with tools such as bwrap, nsjail, firejail, systemd-run or
+	// unshare. The placeholders are expanded in each argument:
+	//   - {executable}: the CLI executable; appended after the wrapper when
+	//     no argument contains it
+	//   - {cwd}: the working directory (Cwd, or the current directory)
+	//   - {env:WORDS}: the argument expands, once per variable of the CLI's
+	//     environment (the EnvPolicy base, the identity variables of User,
+	//     and Env), to the space-separated WORDS with NAME and VALUE
+	//     replaced; for example "{env:--setenv=NAME}" for systemd-run,
+	//     which reads the values from its own environment, or
+	//     "{env:--setenv NAME VALUE}" for bwrap --clearenv
+	//
+	// The wrapper always receives the environment, as the CLI would. Its
+	// arguments, unlike the environment, can be read by any user on the
+	// host through ps or /proc, so VALUE exposes secrets such as API keys;
+	// prefer wrappers that pass the environment through, or forms that take
+	// only NAME.
+	//
+	// The CLI version check runs inside the wrapper as well, and on Linux
+	// shutdown signals also reach CLI processes the wrapper moved into a new
+	// session or process group.
+	//
+	// Example:
+	//
+	//	opts := &claude.Options{
+	//	    CommandWrapper: []string{
+	//	        "bwrap", "--ro-bind", "/", "/", "--bind", "{cwd}", "{cwd}",
+	//	        "--chdir", "{cwd}", "--die-with-parent", "--",
+	//	    },
+	//	}
+	CommandWrapper []string
+
+	// ResourceLimits constrains the CLI and its tool subprocesses: address
+	// space, CPU time, open files, process count, core size, CPU and IO
+	// priority, and a wall-clock timeout. Nil applies none. See
+	// ResourceLimits.
+	ResourceLimits *ResourceLimits
 }
 
 // AgentDefinition defines a custom agent.
@@ -3,21 +3,31 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/connerohnesorge/claude-agent-sdk-go/internal/transport"
 	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
 	"github.com/google/uuid"
 )
 
 const (
-	// Message channel and control request buffer sizes.
+	// Default message buffer and control request buffer sizes.
 	msgChanBufferSize        = 100
 	controlRequestChanBuffer = 10
 
+	// initializeTimeout bounds the initialize handshake sent on startup.
+	initializeTimeout = 60 * time.Second
+
+	// processExitTimeout bounds how long the reader waits, after stdout
+	// closes, for the process exit status.
+	processExitTimeout = 2 * time.Second
+
 	// Control protocol message types and subtypes.
 	messageTypeUser            = "user"
 	messageTypeControlRequest  = "control_request"
@@ -88,14 +98,55 @@ type Query interface {
 	// The context can be used to cancel the operation.
 	// Returns an error if the query is closed or if the request fails.
 	AccountInfo(ctx context.Context) (*AccountInfo, error)
+
+	// Messages returns an iterator over the remaining messages of the query.
+	// Stream errors are yielded once, in their original typed form, and end
+	// the iteration. The query is closed when the loop ends, including when
+	// the caller breaks out early.
+	Messages(ctx context.Context) iter.Seq2[SDKMessage, error]
+}
+
+// Compactor is implemented by queries that can compact the conversation;
+// every Query this package returns does.
+type Compactor interface {
+	// Compact asks the CLI to compact the conversation history, optionally
+	// guided by custom instructions. Progress is reported on the message
+	// stream (status and compact_boundary system messages) and through the
+	// callbacks in Options.Compaction.
+	Compact(ctx context.Context, instructions string) error
+}
+
+// compactQuery compacts q, or fails if q cannot compact.
+func compactQuery(ctx context.Context, q Query, instructions string) error {
+	c, ok := q.(Compactor)
+	if !ok {
+		return clauderrs.NewClientError(
+			clauderrs.ErrCodeInvalidState,
+			"query does not support compaction",
+			nil,
+		)
+	}
+
+	return c.Compact(ctx, instructions)
+}
+
+// queryTransportStats returns the write counters of q, or zero counters if
+// q does not report them.
+func queryTransportStats(q Query) TransportStats {
+	if provider, ok := q.(TransportStatsProvider); ok {
+		return provider.TransportStats()
+	}
+
+	return TransportStats{}
 }
 
 // queryImpl implements the Query interface.
 type queryImpl struct {
-	proc                    *transport.Process
+	transport               Transport
 	msgChan                 chan SDKMessage
 	errChan                 chan error
-	closeChan               chan struct{}
+	closeChan               chan struct{} // Closed when Close is called
+	exited                  chan struct{} // Closed once the transport has shut down
 	opts                    *Options
 	sessionID               string
 	mu                      sync.Mutex
@@ -106,6 +157,10 @@ type queryImpl struct {
 	hookCallbacks           map[string]HookCallback // Maps callback IDs to hook functions
 	nextCallbackID          int                     // Counter for generating callback IDs
 	controlRequestChan      chan json.RawMessage    // Channel for incoming control requests
+	compaction              *compactionMonitor
+	results                 *resultCounter
+	queue                   *messageQueue
+	warnings                []SDKMessage // SDK warnings sent before the CLI's messages
 }
 
 // newQueryImpl creates a new query implementation.
@@ -114,17 +169,31 @@ func newQueryImpl(prompt string, opts *Options) (*queryImpl, error) {
 		opts = &Options{}
 	}
 
+	if err := validateCompactionOptions(opts.Compaction); err != nil {
+		return nil, err
+	}
+	if err := validateEnvPolicy(opts.EnvPolicy); err != nil {
+		return nil, err
+	}
+	if err := validateResourceLimits(opts.ResourceLimits); err != nil {
+		return nil, err
+	}
+
 	q := &queryImpl{
-		msgChan:                 make(chan SDKMessage, msgChanBufferSize),
+		msgChan:                 make(chan SDKMessage),
+		queue:                   newMessageQueue(opts.MessageBuffer),
 		errChan:                 make(chan error, 1),
 		closeChan:               make(chan struct{}),
+		exited:                  make(chan struct{}),
 		opts:                    opts,
 		sessionID:               uuid.New().String(),
 		pendingControlResponses: make(map[string]chan *SDKControlResponse),
 		hookCallbacks:           make(map[string]HookCallback),
 		nextCallbackID:          0,
 		controlRequestChan:      make(chan json.RawMessage, controlRequestChanBuffer),
+		results:                 newResultCounter(),
 	}
+	q.compaction = newCompactionMonitor(opts.Compaction, q.sendCompact)
 
 	// Start the process
 	if err := q.start(prompt); err != nil {
@@ -136,32 +205,32 @@ func newQueryImpl(prompt string, opts *Options) (*queryImpl, error) {
 
 // start initializes the process and message handling.
 func (q *queryImpl) start(prompt string) error {
-	// Build process args
-	args := q.buildArgs()
-
-	// Build environment
-	env := q.buildEnv()
-
-	// Determine max buffer size
-	maxBufferSize := q.opts.MaxBufferSize
-	if maxBufferSize == 0 {
-		maxBufferSize = DefaultMaxBufferSize
+	// Build process args, leaving out what the installed CLI cannot do
+	args, warnings, err := q.checkCapabilities(q.buildArgs())
+	if err != nil {
+		return err
 	}
+	q.warnings = warnings
 
-	// Create process config
-	config := &transport.ProcessConfig{
-		Executable:    q.opts.PathToClaudeCodeExecutable,
-		Args:          args,
-		Env:           env,
-		Cwd:           q.opts.Cwd,
-		StderrHandler: q.opts.Stderr,
-		MaxBufferSize: maxBufferSize,
-		User:          q.opts.User,
-	}
+	// Create transport config
+	config := transportConfig(q.opts, args)
 
-	// Start process
-	proc, err := transport.NewProcess(context.Background(), config)
+	// Start the CLI
+	factory := q.opts.TransportFactory
+	if factory == nil {
+		factory = DefaultTransportFactory
+	}
+	t, err := factory(context.Background(), config)
 	if err != nil {
+		// A user switch that cannot work is not a spawn failure.
+		var permErr *clauderrs.PermissionError
+		if errors.As(err, &permErr) {
+			return permErr
+		}
+		if procErr := executableError(err); procErr != nil {
+			return procErr.WithSessionID(q.sessionID)
+		}
+
 		return clauderrs.CreateProcessError(
 			clauderrs.ErrCodeProcessSpawnFailed,
 			"failed to start Claude Code process",
@@ -172,14 +241,28 @@ func (q *queryImpl) start(prompt string) error {
 			WithCommand(fmt.Sprintf("%s %v", q.opts.PathToClaudeCodeExecutable, args)).
 			WithSessionID(q.sessionID)
 	}
-	q.proc = proc
+	q.transport = t
 
-	// Start message reading goroutine
+	// Start message reading goroutines
 	go q.readMessages()
+	go q.forwardMessages()
 
 	// Start control request handler goroutine
 	go q.handleControlRequests()
 
+	// Register hook callbacks with the CLI before any prompt is processed,
+	// otherwise the CLI never invokes them.
+	if len(q.opts.Hooks) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), initializeTimeout)
+		_, err := q.Initialize(ctx)
+		cancel()
+		if err != nil {
+			_ = q.Close()
+
+			return err
+		}
+	}
+
 	// Send initial prompt
 	if prompt != "" {
 		if err := q.SendUserMessage(context.Background(), prompt); err != nil {
@@ -258,67 +341,215 @@ func (q *queryImpl) buildArgs() []string {
 	return args
 }
 
-// buildEnv builds the environment variables for the process.
-func (q *queryImpl) buildEnv() []string {
-	env := make([]string, 0)
-
-	for key, value := range q.opts.Env {
-		env = append(env, fmt.Sprintf("%s=%s", key, value))
+// transportConfig builds the transport configuration for running the CLI
+// with args under opts.
+func transportConfig(opts *Options, args []string) *TransportConfig {
+	// Determine max buffer size
+	maxBufferSize := opts.MaxBufferSize
+	if maxBufferSize == 0 {
+		maxBufferSize = DefaultMaxBufferSize
 	}
 
-	return env
+	return &TransportConfig{
+		Executable:          opts.PathToClaudeCodeExecutable,
+		Args:                args,
+		Env:                 envEntries(opts.Env),
+		BaseEnv:             opts.EnvPolicy.baseEnv(os.Environ()),
+		Cwd:                 opts.Cwd,
+		User:                opts.User,
+		Group:               opts.Group,
+		SupplementaryGroups: opts.SupplementaryGroups,
+		Stderr:              opts.Stderr,
+		MaxBufferSize:       maxBufferSize,
+		ShutdownGracePeriod: opts.ShutdownGracePeriod,
+		StderrTailSize:      opts.StderrTailSize,
+		CommandWrapper:      opts.CommandWrapper,
+		ResourceLimits:      opts.ResourceLimits,
+	}
 }
 
-// readMessages reads messages from the process.
+// readMessages reads messages from the process. Control traffic is handled
+// inline; data messages are queued for forwardMessages, so a caller that reads
+// slowly never holds up control requests and responses (see
+// MessageBufferOptions).
+//
+// After Close the reader keeps draining stdout, discarding data messages,
+// until the transport has shut down, so a CLI finishing its turn never blocks
+// on a full pipe.
 func (q *queryImpl) readMessages() {
+	for {
+		select {
+		case <-q.exited:
+			q.queue.close(nil)
+
+			return
+		default:
+		}
+
+		msg, raw, err := q.readMessage()
+		if err != nil {
+			q.queue.close(q.readError(err))
+
+			return
+		}
+
+		if msg == nil {
+			continue
+		}
+		select {
+		case <-q.closeChan:
+			continue
+		default:
+		}
+
+		q.compaction.observe(context.Background(), msg)
+		if _, ok := msg.(*SDKResultMessage); ok {
+			q.results.add()
+		}
+		q.queue.push(queuedMessage{msg: msg, raw: raw}, q.closeChan)
+	}
+}
+
+// forwardMessages delivers queued messages to Next, followed by the error that
+// ended the stream, if any.
+func (q *queryImpl) forwardMessages() {
 	defer close(q.msgChan)
+	defer q.queue.release()
 
-	for {
+	for _, msg := range q.warnings {
 		select {
+		case q.msgChan <- msg:
 		case <-q.closeChan:
 			return
-		default:
-			msg, err := q.readMessage()
+		}
+	}
+
+	for {
+		item, ok := q.queue.pop(q.closeChan)
+		if !ok {
+			if err := q.queue.failure(); err != nil {
+				select {
+				case q.errChan <- err:
+				case <-q.closeChan:
+				}
+			}
+
+			return
+		}
+
+		msg := item.msg
+		if msg == nil {
+			// Spilled messages are decoded again when read back.
+			var err error
+			msg, err = q.decodeLine(item.raw)
 			if err != nil {
-				q.handleReadError(err)
+				select {
+				case q.errChan <- err:
+				case <-q.closeChan:
+				}
 
 				return
 			}
+		}
 
-			if msg != nil {
-				q.msgChan <- msg
-			}
+		select {
+		case q.msgChan <- msg:
+		case <-q.closeChan:
+			return
 		}
 	}
 }
 
-// handleReadError handles errors during message reading.
-func (q *queryImpl) handleReadError(err error) {
+// readError returns the error to report after reading failed, or nil when the
+// stream ended cleanly. When stdout ends because the CLI exited abnormally,
+// the process error (exit code, signal and stderr tail) is reported in place
+// of io.EOF or the read error.
+func (q *queryImpl) readError(err error) error {
+	if q.isClosed() {
+		return nil
+	}
+
+	var bufErr *clauderrs.BufferError
+	if !errors.As(err, &bufErr) {
+		if exitErr := q.processExitError(); exitErr != nil {
+			return exitErr
+		}
+	}
+
 	if err == io.EOF {
-		return
+		return nil
 	}
 
-	q.errChan <- err
+	return err
 }
 
-// readMessage reads a single message from the process.
-func (q *queryImpl) readMessage() (SDKMessage, error) {
-	data, err := q.proc.Transport().Read(context.Background())
+// processExitError waits briefly for the process to exit and returns its
+// *clauderrs.ProcessError, or nil if it exited cleanly or is still running.
+func (q *queryImpl) processExitError() error {
+	ctx, cancel := context.WithTimeout(context.Background(), processExitTimeout)
+	defer cancel()
+
+	proc, ok := q.transport.(ProcessTransport)
+	if !ok {
+		return nil
+	}
+
+	var procErr *clauderrs.ProcessError
+	if errors.As(proc.Wait(ctx), &procErr) {
+		return procErr.WithSessionID(q.sessionID)
+	}
+
+	return nil
+}
+
+// readMessage reads a single message from the process and returns it with its
+// raw line. Control traffic is handled here and yields a nil message.
+func (q *queryImpl) readMessage() (SDKMessage, []byte, error) {
+	data, err := q.transport.Read(context.Background())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	msg, err := q.routeLine(data)
+
+	return msg, data, err
+}
+
+// decodeLine decodes a data message line.
+func (q *queryImpl) decodeLine(data []byte) (SDKMessage, error) {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return q.undecodable("", data, clauderrs.NewProtocolError(
+			clauderrs.ErrCodeMessageParseFailed,
+			"failed to parse message envelope",
+			err,
+		).
+			WithSessionID(q.sessionID))
 	}
 
+	msg, err := q.decodeMessage(envelope.Type, data)
+	if err != nil {
+		return q.undecodable(envelope.Type, data, err)
+	}
+
+	return msg, nil
+}
+
+// routeLine dispatches control traffic and decodes data messages.
+func (q *queryImpl) routeLine(data []byte) (SDKMessage, error) {
 	// Parse the message type first
 	var envelope struct {
 		Type string `json:"type"`
 	}
 	if err := json.Unmarshal(data, &envelope); err != nil {
-		return nil, clauderrs.NewProtocolError(
+		return q.undecodable("", data, clauderrs.NewProtocolError(
 			clauderrs.ErrCodeMessageParseFailed,
 			"failed to parse message envelope",
 			err,
 		).
-			WithSessionID(q.sessionID)
+			WithSessionID(q.sessionID))
 	}
 
 	// Handle control responses
@@ -350,15 +581,35 @@ func (q *queryImpl) readMessage() (SDKMessage, error) {
 		// Route to control request handler instead of message stream
 		select {
 		case q.controlRequestChan <- data:
-		case <-q.closeChan:
+		case <-q.exited:
 			return nil, io.EOF
 		}
 
 		return nil, nil // Control requests don't go to the message stream
 	}
 
-	// Decode based on type
-	switch envelope.Type {
+	msg, err := q.decodeMessage(envelope.Type, data)
+	if err != nil {
+		return q.undecodable(envelope.Type, data, err)
+	}
+
+	return msg, nil
+}
+
+// undecodable handles a line that could not be decoded. In lenient mode the
+// line is delivered as an SDKUnknownMessage; otherwise the error is returned
+// and ends the message stream.
+func (q *queryImpl) undecodable(msgType string, data []byte, err error) (SDKMessage, error) {
+	if !q.opts.LenientMessageDecoding {
+		return nil, err
+	}
+
+	return newUnknownMessage(msgType, data, err), nil
+}
+
+// decodeMessage decodes a stream message of the given type.
+func (q *queryImpl) decodeMessage(msgType string, data []byte) (SDKMessage, error) {
+	switch msgType {
 	case "user":
 		var msg SDKUserMessage
 		if err := json.Unmarshal(data, &msg); err != nil {
@@ -402,8 +653,8 @@ func (q *queryImpl) readMessage() (SDKMessage, error) {
 		return &msg, nil
 
 	case "system":
-		var msg SDKSystemMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
+		msg, err := decodeSystemMessage(data)
+		if err != nil {
 			return nil, clauderrs.NewProtocolError(
 				clauderrs.ErrCodeMessageParseFailed,
 				"failed to parse system message",
@@ -413,7 +664,7 @@ func (q *queryImpl) readMessage() (SDKMessage, error) {
 				WithMessageType("system")
 		}
 
-		return &msg, nil
+		return msg, nil
 
 	case "result":
 		var msg SDKResultMessage
@@ -429,14 +680,42 @@ func (q *queryImpl) readMessage() (SDKMessage, error) {
 
 		return &msg, nil
 
+	case "tool_progress":
+		var msg SDKToolProgressMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, clauderrs.NewProtocolError(
+				clauderrs.ErrCodeMessageParseFailed,
+				"failed to parse tool progress message",
+				err,
+			).
+				WithSessionID(q.sessionID).
+				WithMessageType("tool_progress")
+		}
+
+		return &msg, nil
+
+	case "auth_status":
+		var msg SDKAuthStatusMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, clauderrs.NewProtocolError(
+				clauderrs.ErrCodeMessageParseFailed,
+				"failed to parse auth status message",
+				err,
+			).
+				WithSessionID(q.sessionID).
+				WithMessageType("auth_status")
+		}
+
+		return &msg, nil
+
 	default:
 		return nil, clauderrs.NewProtocolError(
 			clauderrs.ErrCodeUnknownMessageType,
-			fmt.Sprintf("unknown message type: %s", envelope.Type),
+			fmt.Sprintf("unknown message type: %s", msgType),
 			nil,
 		).
 			WithSessionID(q.sessionID).
-			WithMessageType(envelope.Type)
+			WithMessageType(msgType)
 	}
 }
 
@@ -471,7 +750,24 @@ func (q *queryImpl) SendUserMessageWithContent(ctx context.Context, content []Co
 			WithMessageType("user")
 	}
 
-	return q.proc.Transport().Write(ctx, data)
+	return q.writeUser(ctx, data)
+}
+
+// Compact asks the CLI to compact the conversation history.
+func (q *queryImpl) Compact(ctx context.Context, instructions string) error {
+	q.compaction.requested(CompactTriggerManual, instructions)
+	if err := q.sendCompact(ctx, instructions); err != nil {
+		q.compaction.cancelRequest()
+
+		return err
+	}
+
+	return nil
+}
+
+// sendCompact sends the /compact command as a user message.
+func (q *queryImpl) sendCompact(ctx context.Context, instructions string) error {
+	return q.SendUserMessage(ctx, compactPrompt(instructions))
 }
 
 // Next returns the next message from the query.
@@ -479,7 +775,13 @@ func (q *queryImpl) Next(ctx context.Context) (SDKMessage, error) {
 	select {
 	case msg, ok := <-q.msgChan:
 		if !ok {
-			return nil, io.EOF
+			// The reader records its error before closing the channel.
+			select {
+			case err := <-q.errChan:
+				return nil, err
+			default:
+				return nil, io.EOF
+			}
 		}
 
 		return msg, nil
@@ -492,26 +794,45 @@ func (q *queryImpl) Next(ctx context.Context) (SDKMessage, error) {
 	}
 }
 
+// TransportStats returns counters for writes to the CLI's stdin.
+func (q *queryImpl) TransportStats() TransportStats {
+	if provider, ok := q.transport.(TransportStatsProvider); ok {
+		return provider.TransportStats()
+	}
+
+	return TransportStats{}
+}
+
 // SessionID returns the current query session identifier.
 func (q *queryImpl) SessionID() string {
 	return q.sessionID
 }
 
 // Close closes the query and cleans up resources.
+//
+// Next returns io.EOF as soon as Close is called, but stdout is drained and
+// control requests are answered until the transport has shut down, so a CLI
+// in the middle of a turn can finish it and exit cleanly.
 func (q *queryImpl) Close() error {
 	q.mu.Lock()
-	defer q.mu.Unlock()
-
 	if q.closed {
+		q.mu.Unlock()
+
 		return nil
 	}
-
 	q.closed = true
 	close(q.closeChan)
-	close(q.controlRequestChan)
+	q.mu.Unlock()
 
-	if q.proc != nil {
-		return q.proc.Close()
+	defer close(q.exited)
+
+	// Closing the transport fails a /compact write that already started,
+	// so wait only after it.
+	q.compaction.stop()
+	defer q.compaction.wait()
+
+	if q.transport != nil {
+		return q.transport.Close()
 	}
 
 	return nil
@@ -529,7 +850,7 @@ type controlRequestEnvelope struct {
 func (q *queryImpl) handleControlRequests() {
 	for {
 		select {
-		case <-q.closeChan:
+		case <-q.exited:
 			return
 		case data := <-q.controlRequestChan:
 			// Parse the control request
@@ -550,6 +871,33 @@ func (q *queryImpl) handleControlRequests() {
 	}
 }
 
+// controlRequestPayload returns the inner request object of a control request
+// envelope with the envelope's request_id copied into it, so it can be decoded
+// into the flat request structs (SDKControlPermissionRequest,
+// SDKHookCallbackRequest).
+func controlRequestPayload(data json.RawMessage) (json.RawMessage, error) {
+	var envelope struct {
+		RequestID string                     `json:"request_id"`
+		Request   map[string]json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	if envelope.Request == nil {
+		// Already flat; nothing to unwrap.
+		return data, nil
+	}
+
+	requestID, err := json.Marshal(envelope.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Request[fieldRequestID] = requestID
+
+	return json.Marshal(envelope.Request)
+}
+
 // handleControlRequest handles a single control request from the CLI.
 func (q *queryImpl) handleControlRequest(
 	ctx context.Context,
@@ -597,8 +945,19 @@ func (q *queryImpl) handleCanUseTool(
 	ctx context.Context,
 	data json.RawMessage,
 ) (map[string]any, error) {
+	payload, err := controlRequestPayload(data)
+	if err != nil {
+		return nil, clauderrs.NewProtocolError(
+			clauderrs.ErrCodeMessageParseFailed,
+			"failed to parse permission request",
+			err,
+		).
+			WithSessionID(q.sessionID).
+			WithMessageType("control_request")
+	}
+
 	var req SDKControlPermissionRequest
-	if err := json.Unmarshal(data, &req); err != nil {
+	if err := json.Unmarshal(payload, &req); err != nil {
 		return nil, clauderrs.NewProtocolError(
 			clauderrs.ErrCodeMessageParseFailed,
 			"failed to parse permission request",
@@ -686,8 +1045,19 @@ func (q *queryImpl) handleHookCallback(
 	ctx context.Context,
 	data json.RawMessage,
 ) (map[string]any, error) {
+	payload, err := controlRequestPayload(data)
+	if err != nil {
+		return nil, clauderrs.NewProtocolError(
+			clauderrs.ErrCodeMessageParseFailed,
+			"failed to parse hook callback request",
+			err,
+		).
+			WithSessionID(q.sessionID).
+			WithMessageType("control_request")
+	}
+
 	var req SDKHookCallbackRequest
-	if err := json.Unmarshal(data, &req); err != nil {
+	if err := json.Unmarshal(payload, &req); err != nil {
 		return nil, clauderrs.NewProtocolError(
 			clauderrs.ErrCodeMessageParseFailed,
 			"failed to parse hook callback request",
@@ -820,7 +1190,7 @@ func (q *queryImpl) sendControlResponse(
 			WithMessageType("control_response")
 	}
 
-	return q.proc.Transport().Write(ctx, data)
+	return q.transport.Write(ctx, data)
 }
 
 // sendControlRequest sends a control request and waits for response.
@@ -868,7 +1238,7 @@ func (q *queryImpl) sendControlRequest(
 			WithMessageType("control_request")
 	}
 
-	if err := q.proc.Transport().Write(ctx, data); err != nil {
+	if err := q.transport.Write(ctx, data); err != nil {
 		q.mu.Lock()
 		delete(q.pendingControlResponses, requestID)
 		q.mu.Unlock()
@@ -974,7 +1344,7 @@ func (q *queryImpl) SetModel(ctx context.Context, model *string) error {
 			WithMessageType("control_request")
 	}
 
-	if err := q.proc.Transport().Write(ctx, data); err != nil {
+	if err := q.transport.Write(ctx, data); err != nil {
 		q.mu.Lock()
 		delete(q.pendingControlResponses, requestID)
 		q.mu.Unlock()
@@ -1056,7 +1426,7 @@ func (q *queryImpl) SetMaxThinkingTokens(maxThinkingTokens *int) error {
 	}
 
 	ctx := context.Background()
-	if err := q.proc.Transport().Write(ctx, data); err != nil {
+	if err := q.transport.Write(ctx, data); err != nil {
 		q.mu.Lock()
 		delete(q.pendingControlResponses, requestID)
 		q.mu.Unlock()
@@ -1135,7 +1505,7 @@ func (q *queryImpl) AccountInfo(ctx context.Context) (*AccountInfo, error) {
 			WithMessageType("control_request")
 	}
 
-	if err := q.proc.Transport().Write(ctx, data); err != nil {
+	if err := q.transport.Write(ctx, data); err != nil {
 		q.mu.Lock()
 		delete(q.pendingControlResponses, requestID)
 		q.mu.Unlock()
@@ -1238,7 +1608,7 @@ func (q *queryImpl) SupportedCommands(ctx context.Context) ([]SlashCommand, erro
 			WithMessageType("control_request")
 	}
 
-	if err := q.proc.Transport().Write(ctx, data); err != nil {
+	if err := q.transport.Write(ctx, data); err != nil {
 		q.mu.Lock()
 		delete(q.pendingControlResponses, requestID)
 		q.mu.Unlock()
@@ -1337,7 +1707,7 @@ func (q *queryImpl) SupportedModels(ctx context.Context) ([]ModelInfo, error) {
 			WithMessageType("control_request")
 	}
 
-	if err := q.proc.Transport().Write(ctx, data); err != nil {
+	if err := q.transport.Write(ctx, data); err != nil {
 		q.mu.Lock()
 		delete(q.pendingControlResponses, requestID)
 		q.mu.Unlock()
@@ -1436,7 +1806,7 @@ func (q *queryImpl) McpServerStatus(ctx context.Context) ([]McpServerStatus, err
 			WithMessageType("control_request")
 	}
 
-	if err := q.proc.Transport().Write(ctx, data); err != nil {
+	if err := q.transport.Write(ctx, data); err != nil {
 		q.mu.Lock()
 		delete(q.pendingControlResponses, requestID)
 		q.mu.Unlock()
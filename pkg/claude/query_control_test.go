@@ -0,0 +1,170 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wireTransport records every frame the SDK writes and answers control
+// requests with an empty success response.
+type wireTransport struct {
+	lines  chan []byte
+	frames chan string
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newWireTransport() *wireTransport {
+	return &wireTransport{
+		lines:  make(chan []byte, 4),
+		frames: make(chan string, 4),
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *wireTransport) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case line := <-w.lines:
+		return line, nil
+	case <-w.done:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (w *wireTransport) Write(_ context.Context, data []byte) error {
+	var frame struct {
+		Type      string `json:"type"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+	if frame.Type == messageTypeControlRequest {
+		w.lines <- []byte(`{"type":"control_response","response":{"subtype":"success","request_id":"` +
+			frame.RequestID + `","response":{}}}`)
+	}
+	w.frames <- string(data)
+
+	return nil
+}
+
+func (w *wireTransport) CloseInput() error { return nil }
+
+func (w *wireTransport) Close() error {
+	w.once.Do(func() { close(w.done) })
+
+	return nil
+}
+
+// next returns the next frame the SDK wrote.
+func (w *wireTransport) next(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case frame := <-w.frames:
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a frame")
+
+		return ""
+	}
+}
+
+// frameIDs returns the generated identifiers of a frame, so the expected
+// JSON can be spelled out in full.
+func frameIDs(t *testing.T, frame string) (uuid, sessionID, requestID string) {
+	t.Helper()
+
+	var ids struct {
+		UUID      string `json:"uuid"`
+		SessionID string `json:"session_id"`
+		RequestID string `json:"request_id"`
+		Response  struct {
+			RequestID string `json:"request_id"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal([]byte(frame), &ids); err != nil {
+		t.Fatalf("decode frame %s: %v", frame, err)
+	}
+	if ids.RequestID == "" {
+		ids.RequestID = ids.Response.RequestID
+	}
+
+	return ids.UUID, ids.SessionID, ids.RequestID
+}
+
+func TestQuery_ControlProtocolWireFormat(t *testing.T) {
+	wire := newWireTransport()
+	matcher := "Bash"
+	q, err := QueryFunc("", &Options{
+		TransportFactory: func(context.Context, *TransportConfig) (Transport, error) {
+			return wire, nil
+		},
+		Hooks: map[HookEvent][]HookCallbackMatcher{
+			HookEventPreToolUse: {{
+				Matcher: &matcher,
+				Hooks: []HookCallback{func(context.Context, HookInput, *string) (HookJSONOutput, error) {
+					return nil, nil
+				}},
+			}},
+		},
+		CanUseTool: func(
+			context.Context, string, map[string]JSONValue, []PermissionUpdate,
+			string, *string, *string, *string,
+		) (PermissionResult, error) {
+			return PermissionAllow{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	// The hooks are registered with an initialize request before any prompt.
+	frame := wire.next(t)
+	uuid, sessionID, requestID := frameIDs(t, frame)
+	want := fmt.Sprintf(`{"type":"control_request","uuid":%q,"session_id":%q,"request_id":%q,`+
+		`"request":{"subtype":"initialize","hooks":{"PreToolUse":[{"hookCallbackIds":["hook_0"],"matcher":"Bash"}]}}}`,
+		uuid, sessionID, requestID)
+	if frame != want {
+		t.Errorf("unexpected initialize request:\n got: %s\nwant: %s", frame, want)
+	}
+
+	// The CLI nests the permission request under "request"; the answer
+	// carries the control_response type and the request's ID.
+	wire.lines <- []byte(`{"type":"control_request","request_id":"req_cli_1",` +
+		`"request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"ls"},"tool_use_id":"toolu_1"}}`)
+
+	frame = wire.next(t)
+	uuid, sessionID, _ = frameIDs(t, frame)
+	want = fmt.Sprintf(`{"type":"control_response","uuid":%q,"session_id":%q,`+
+		`"response":{"subtype":"success","request_id":"req_cli_1","response":{"allow":true}}}`,
+		uuid, sessionID)
+	if frame != want {
+		t.Errorf("unexpected control response:\n got: %s\nwant: %s", frame, want)
+	}
+}
+
+func TestControlRequestPayload(t *testing.T) {
+	payload, err := controlRequestPayload(json.RawMessage(
+		`{"type":"control_request","request_id":"req_1","request":{"subtype":"hook_callback","callback_id":"hook_0"}}`))
+	if err != nil {
+		t.Fatalf("controlRequestPayload: %v", err)
+	}
+	if want := `{"callback_id":"hook_0","request_id":"req_1","subtype":"hook_callback"}`; string(payload) != want {
+		t.Errorf("expected %s, got %s", want, payload)
+	}
+
+	flat := json.RawMessage(`{"subtype":"hook_callback","request_id":"req_1"}`)
+	if payload, err := controlRequestPayload(flat); err != nil || string(payload) != string(flat) {
+		t.Errorf("expected a flat request unchanged, got %s, %v", payload, err)
+	}
+}
@@ -0,0 +1,91 @@
+package claude
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestDecodeMessage_ToolProgressAndAuthStatus(t *testing.T) {
+	q := &queryImpl{opts: &Options{}}
+
+	msg, err := q.decodeMessage("tool_progress", []byte(
+		`{"type":"tool_progress","tool_use_id":"toolu_1","tool_name":"Bash","parent_tool_use_id":null,"elapsed_time_seconds":2.5}`))
+	if err != nil {
+		t.Fatalf("decode tool_progress: %v", err)
+	}
+	progress, ok := msg.(*SDKToolProgressMessage)
+	if !ok || progress.ToolName != "Bash" || progress.ElapsedTimeSeconds != 2.5 {
+		t.Fatalf("unexpected tool progress message: %#v", msg)
+	}
+
+	msg, err = q.decodeMessage("auth_status", []byte(
+		`{"type":"auth_status","isAuthenticating":true,"output":["open browser"]}`))
+	if err != nil {
+		t.Fatalf("decode auth_status: %v", err)
+	}
+	auth, ok := msg.(*SDKAuthStatusMessage)
+	if !ok || !auth.IsAuthenticating || len(auth.Output) != 1 {
+		t.Fatalf("unexpected auth status message: %#v", msg)
+	}
+}
+
+func TestUndecodable_StrictReturnsError(t *testing.T) {
+	q := &queryImpl{opts: &Options{}}
+	data := []byte(`{"type":"brand_new","session_id":"s1"}`)
+
+	_, err := q.decodeMessage("brand_new", data)
+	if err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+
+	msg, err := q.undecodable("brand_new", data, err)
+	if msg != nil || err == nil {
+		t.Fatalf("expected strict mode to return the error, got %v, %v", msg, err)
+	}
+
+	var protoErr *clauderrs.ProtocolError
+	if !errors.As(err, &protoErr) || protoErr.Code() != clauderrs.ErrCodeUnknownMessageType {
+		t.Fatalf("expected unknown message type protocol error, got %v", err)
+	}
+}
+
+func TestUndecodable_LenientReturnsUnknownMessage(t *testing.T) {
+	q := &queryImpl{opts: &Options{LenientMessageDecoding: true}}
+
+	tests := []struct {
+		name     string
+		msgType  string
+		data     string
+		wantType string
+	}{
+		{"unknown type", "brand_new", `{"type":"brand_new","session_id":"s1","x":1}`, "brand_new"},
+		{"malformed known type", "result", `{"type":"result","session_id":"s1","num_turns":"three"}`, "result"},
+		{"invalid json", "", `not json`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, decodeErr := q.decodeMessage(tt.msgType, []byte(tt.data))
+			if decodeErr == nil {
+				t.Fatal("expected decode error")
+			}
+
+			msg, err := q.undecodable(tt.msgType, []byte(tt.data), decodeErr)
+			if err != nil {
+				t.Fatalf("lenient mode returned error: %v", err)
+			}
+			unknown, ok := msg.(*SDKUnknownMessage)
+			if !ok {
+				t.Fatalf("expected *SDKUnknownMessage, got %T", msg)
+			}
+			if unknown.Type() != tt.wantType || string(unknown.Raw) != tt.data || unknown.Err == nil {
+				t.Errorf("unexpected unknown message: %+v", unknown)
+			}
+			if tt.wantType != "" && unknown.SessionID() != "s1" {
+				t.Errorf("expected session ID preserved, got %q", unknown.SessionID())
+			}
+		})
+	}
+}
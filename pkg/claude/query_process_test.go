@@ -0,0 +1,90 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func TestQuery_ReportsCrashAsProcessError(t *testing.T) {
+	cli := writeFakeCLI(t, `#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "claude version 2.1.0"
+	exit 0
+fi
+read -r line
+echo "Error: invalid API key" >&2
+exit 2
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	q, err := QueryFunc("hello", &Options{PathToClaudeCodeExecutable: cli})
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	_, err = q.Next(ctx)
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) {
+		t.Fatalf("expected ProcessError, got %v", err)
+	}
+	if procErr.ExitCode() != 2 || procErr.Code() != clauderrs.ErrCodeProcessExited {
+		t.Errorf("unexpected exit: code %s status %d", procErr.Code(), procErr.ExitCode())
+	}
+	if procErr.Stderr() != "Error: invalid API key\n" {
+		t.Errorf("unexpected stderr tail %q", procErr.Stderr())
+	}
+}
+
+// Every Query this package returns reports write counters.
+var (
+	_ TransportStatsProvider = (*queryImpl)(nil)
+	_ TransportStatsProvider = (*supervisedQuery)(nil)
+)
+
+func TestQueryTransportStats_WithoutProvider(t *testing.T) {
+	if stats := queryTransportStats(plainQuery{}); stats != (TransportStats{}) {
+		t.Fatalf("expected zero stats, got %+v", stats)
+	}
+}
+
+func TestQuery_CloseMidTurnDrainsOutput(t *testing.T) {
+	// Finishes its turn after stdin closes with more output than a pipe
+	// holds, so it only exits if the SDK keeps reading during Close.
+	cli := writeFakeCLI(t, `#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "claude version 2.1.0"
+	exit 0
+fi
+read -r line
+cat > /dev/null
+i=0
+while [ $i -lt 2000 ]; do
+	echo '{"type":"assistant","session_id":"s1","message":{"role":"assistant","content":[{"type":"text","text":"padding padding padding padding padding padding padding padding padding padding"}]}}'
+	i=$((i + 1))
+done
+echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":1,"result":"ok"}'
+`)
+
+	q, err := QueryFunc("hello", &Options{
+		PathToClaudeCodeExecutable: cli,
+		ShutdownGracePeriod:        5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+
+	start := time.Now()
+	if err := q.Close(); err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 5*time.Second {
+		t.Errorf("Close waited out the grace period (%v)", elapsed)
+	}
+}
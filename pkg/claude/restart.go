@@ -0,0 +1,512 @@
+package claude
+
+import (
+	"context"
+	"io"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// Restart policy defaults.
+const (
+	DefaultMaxRestarts           = 3
+	DefaultRestartInitialBackoff = time.Second
+	DefaultRestartMaxBackoff     = 30 * time.Second
+)
+
+// RestartPolicy configures the restart supervisor used by ClaudeSDKClient
+// when Options.Restart is set.
+//
+// When the CLI crashes (Next returns a *clauderrs.ProcessError), the
+// supervisor respawns it with --resume set to the session ID the CLI
+// reported, repeats the initialize handshake (re-registering hooks), and
+// replays the user messages that had not been answered by a result message.
+// Each restart is reported on the message stream as an *SDKRestartMessage.
+// Once the budget is spent, the crash error is returned as usual. A CLI
+// stopped by Options.ResourceLimits is not restarted, since it would only
+// hit the same limit again.
+//
+// Example:
+//
+//	opts := &claude.Options{
+//	    Restart: &claude.RestartPolicy{
+//	        MaxRestarts: 5,
+//	        Window:      time.Hour,
+//	    },
+//	}
+type RestartPolicy struct {
+	// MaxRestarts is the restart budget: the number of restarts allowed
+	// within Window. Zero uses DefaultMaxRestarts.
+	MaxRestarts int
+
+	// Window is the period over which restarts are counted against
+	// MaxRestarts. Zero counts every restart for the life of the client.
+	Window time.Duration
+
+	// InitialBackoff is the delay before a restart when no other restart
+	// happened within Window. It doubles with each counted restart, up to
+	// MaxBackoff. Zero uses DefaultRestartInitialBackoff.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between restarts. Zero uses
+	// DefaultRestartMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// validateRestartPolicy rejects negative budgets and durations.
+func validateRestartPolicy(p *RestartPolicy) error {
+	if p == nil {
+		return nil
+	}
+
+	fields := []struct {
+		name     string
+		negative bool
+		value    any
+	}{
+		{"Restart.MaxRestarts", p.MaxRestarts < 0, p.MaxRestarts},
+		{"Restart.Window", p.Window < 0, p.Window},
+		{"Restart.InitialBackoff", p.InitialBackoff < 0, p.InitialBackoff},
+		{"Restart.MaxBackoff", p.MaxBackoff < 0, p.MaxBackoff},
+	}
+	for _, f := range fields {
+		if f.negative {
+			return clauderrs.NewValidationError(
+				clauderrs.ErrCodeRangeViolation,
+				f.name+" must not be negative",
+				nil,
+				f.name,
+				f.value,
+			)
+		}
+	}
+
+	return nil
+}
+
+// withDefaults returns a copy of p with zero fields replaced by defaults.
+func (p RestartPolicy) withDefaults() RestartPolicy {
+	if p.MaxRestarts == 0 {
+		p.MaxRestarts = DefaultMaxRestarts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = DefaultRestartInitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = DefaultRestartMaxBackoff
+	}
+
+	return p
+}
+
+// backoff returns the delay before a restart when recent restarts have
+// already been counted in the window.
+func (p RestartPolicy) backoff(recent int) time.Duration {
+	d := p.InitialBackoff
+	for range recent {
+		if d >= p.MaxBackoff/2 {
+			return p.MaxBackoff
+		}
+		d *= 2
+	}
+
+	return min(d, p.MaxBackoff)
+}
+
+// queryStarter starts a query; QueryFunc in production.
+type queryStarter func(prompt string, opts *Options) (Query, error)
+
+// initializer is implemented by queries that support the initialize handshake.
+type initializer interface {
+	Initialize(ctx context.Context) (map[string]any, error)
+}
+
+// supervisedQuery is a Query that restarts the CLI after a crash.
+type supervisedQuery struct {
+	start  queryStarter
+	policy RestartPolicy
+	done   chan struct{}
+
+	mu       sync.Mutex
+	cur      Query
+	opts     Options
+	closed   bool
+	restarts []time.Time
+	// sessionID is the session ID reported by the CLI, used for --resume.
+	sessionID string
+	// pending holds user messages not yet answered by a result message,
+	// oldest first.
+	pending []*pendingMessage
+}
+
+// pendingMessage is a user message awaiting its result.
+type pendingMessage struct {
+	content []ContentBlock
+}
+
+// newSupervisedQuery starts a query under the restart supervisor.
+func newSupervisedQuery(prompt string, opts *Options, start queryStarter) (*supervisedQuery, error) {
+	if err := validateRestartPolicy(opts.Restart); err != nil {
+		return nil, err
+	}
+
+	q, err := start(prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &supervisedQuery{
+		start:  start,
+		policy: opts.Restart.withDefaults(),
+		done:   make(chan struct{}),
+		cur:    q,
+		opts:   *opts,
+	}
+	if prompt != "" {
+		s.pending = append(s.pending, &pendingMessage{content: []ContentBlock{
+			TextContentBlock{Type: "text", Text: prompt},
+		}})
+	}
+
+	return s, nil
+}
+
+func (s *supervisedQuery) current() Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cur
+}
+
+// Next returns the next message, restarting the CLI if it crashed. A restart
+// is reported as an *SDKRestartMessage.
+func (s *supervisedQuery) Next(ctx context.Context) (SDKMessage, error) {
+	msg, err := s.current().Next(ctx)
+	if err == nil {
+		s.observe(msg)
+
+		return msg, nil
+	}
+
+	if !clauderrs.IsProcessError(err) || isLimitExceeded(err) || ctx.Err() != nil {
+		return nil, err
+	}
+
+	return s.restart(ctx, err)
+}
+
+// observe tracks the session ID and acknowledged user messages.
+func (s *supervisedQuery) observe(msg SDKMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *SystemInitMessage:
+		if id := m.SessionID(); id != "" {
+			s.sessionID = id
+		}
+	case *SDKResultMessage:
+		if id := m.SessionID(); id != "" {
+			s.sessionID = id
+		}
+		if len(s.pending) > 0 {
+			s.pending = s.pending[1:]
+		}
+	}
+}
+
+// restart respawns the CLI until it starts or the budget is spent, and
+// returns the restart event. Once the budget is spent, the last error is
+// returned.
+func (s *supervisedQuery) restart(ctx context.Context, cause error) (SDKMessage, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+
+			return nil, io.EOF
+		}
+		recent := s.recentRestarts(time.Now())
+		if recent >= s.policy.MaxRestarts {
+			s.mu.Unlock()
+
+			return nil, cause
+		}
+		old := s.cur
+		s.mu.Unlock()
+
+		_ = old.Close()
+
+		if err := s.sleep(ctx, s.policy.backoff(recent)); err != nil {
+			return nil, cause
+		}
+
+		s.mu.Lock()
+		s.restarts = append(s.restarts, time.Now())
+		attempt := len(s.restarts)
+		opts := s.resumeOptions()
+		pending := append([]*pendingMessage(nil), s.pending...)
+		s.mu.Unlock()
+
+		q, err := s.resume(ctx, opts, pending)
+		if err != nil {
+			cause = err
+
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = q.Close()
+
+			return nil, io.EOF
+		}
+		s.cur = q
+		s.mu.Unlock()
+
+		return &SDKRestartMessage{
+			BaseMessage: BaseMessage{
+				UUIDField:      uuid.New(),
+				SessionIDField: opts.Resume,
+			},
+			Attempt:          attempt,
+			Cause:            cause,
+			ResumedSessionID: opts.Resume,
+			ReplayedMessages: len(pending),
+		}, nil
+	}
+}
+
+// resume starts a replacement query and replays the pending messages.
+func (s *supervisedQuery) resume(ctx context.Context, opts *Options, pending []*pendingMessage) (Query, error) {
+	q, err := s.start("", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query construction already initializes when hooks are registered.
+	if init, ok := q.(initializer); ok && len(opts.Hooks) == 0 {
+		initCtx, cancel := context.WithTimeout(ctx, initializeTimeout)
+		_, err = init.Initialize(initCtx)
+		cancel()
+		if err != nil {
+			_ = q.Close()
+
+			return nil, err
+		}
+	}
+
+	for _, msg := range pending {
+		if err := q.SendUserMessageWithContent(ctx, msg.content); err != nil {
+			_ = q.Close()
+
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// recentRestarts counts restarts within the window, dropping older ones.
+// Callers hold s.mu.
+func (s *supervisedQuery) recentRestarts(now time.Time) int {
+	if s.policy.Window > 0 {
+		kept := s.restarts[:0]
+		for _, t := range s.restarts {
+			if now.Sub(t) < s.policy.Window {
+				kept = append(kept, t)
+			}
+		}
+		s.restarts = kept
+	}
+
+	return len(s.restarts)
+}
+
+// resumeOptions returns the options for a replacement query. Callers hold
+// s.mu.
+func (s *supervisedQuery) resumeOptions() *Options {
+	opts := s.opts
+	opts.Continue = false
+	opts.ForkSession = false
+	opts.ResumeSessionAt = ""
+	if s.sessionID != "" {
+		opts.Resume = s.sessionID
+	}
+
+	return &opts
+}
+
+// sleep waits for d, returning early if ctx is done or the query is closed.
+func (s *supervisedQuery) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-s.done:
+		return io.EOF
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the query and stops any pending restart.
+func (s *supervisedQuery) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	q := s.cur
+	s.mu.Unlock()
+
+	return q.Close()
+}
+
+// SendUserMessage sends a text user message to the process.
+func (s *supervisedQuery) SendUserMessage(ctx context.Context, text string) error {
+	return s.SendUserMessageWithContent(ctx, []ContentBlock{
+		TextContentBlock{Type: "text", Text: text},
+	})
+}
+
+// SendUserMessageWithContent sends a user message and keeps it for replay
+// until a result message answers it.
+func (s *supervisedQuery) SendUserMessageWithContent(ctx context.Context, content []ContentBlock) error {
+	return s.sendPending(content, func(q Query) error {
+		return q.SendUserMessageWithContent(ctx, content)
+	})
+}
+
+// sendPending keeps content for replay until a result message answers it and
+// sends it to the current query with send.
+func (s *supervisedQuery) sendPending(content []ContentBlock, send func(Query) error) error {
+	msg := &pendingMessage{content: content}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, msg)
+	q := s.cur
+	s.mu.Unlock()
+
+	if err := send(q); err != nil {
+		s.mu.Lock()
+		s.pending = slices.DeleteFunc(s.pending, func(p *pendingMessage) bool {
+			return p == msg
+		})
+		s.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// Interrupt interrupts the current query.
+func (s *supervisedQuery) Interrupt(ctx context.Context) error {
+	return s.current().Interrupt(ctx)
+}
+
+// SetPermissionMode changes the permission mode, keeping it across restarts.
+func (s *supervisedQuery) SetPermissionMode(ctx context.Context, mode PermissionMode) error {
+	if err := s.current().SetPermissionMode(ctx, mode); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.opts.PermissionMode = mode
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SetModel changes the model, keeping it across restarts.
+func (s *supervisedQuery) SetModel(ctx context.Context, model *string) error {
+	if err := s.current().SetModel(ctx, model); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.opts.Model = ""
+	if model != nil {
+		s.opts.Model = *model
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SetMaxThinkingTokens changes the thinking budget, keeping it across
+// restarts.
+func (s *supervisedQuery) SetMaxThinkingTokens(maxThinkingTokens *int) error {
+	if err := s.current().SetMaxThinkingTokens(maxThinkingTokens); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.opts.MaxThinkingTokens = 0
+	if maxThinkingTokens != nil {
+		s.opts.MaxThinkingTokens = *maxThinkingTokens
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SupportedCommands returns available slash commands.
+func (s *supervisedQuery) SupportedCommands(ctx context.Context) ([]SlashCommand, error) {
+	return s.current().SupportedCommands(ctx)
+}
+
+// SupportedModels returns available models.
+func (s *supervisedQuery) SupportedModels(ctx context.Context) ([]ModelInfo, error) {
+	return s.current().SupportedModels(ctx)
+}
+
+// McpServerStatus returns MCP server status.
+func (s *supervisedQuery) McpServerStatus(ctx context.Context) ([]McpServerStatus, error) {
+	return s.current().McpServerStatus(ctx)
+}
+
+// GetServerInfo returns the current process's initialization result.
+func (s *supervisedQuery) GetServerInfo() (map[string]any, error) {
+	return s.current().GetServerInfo()
+}
+
+// AccountInfo retrieves current account information.
+func (s *supervisedQuery) AccountInfo(ctx context.Context) (*AccountInfo, error) {
+	return s.current().AccountInfo(ctx)
+}
+
+// Messages returns an iterator over the remaining messages, including
+// restart events.
+func (s *supervisedQuery) Messages(ctx context.Context) iter.Seq2[SDKMessage, error] {
+	return iterateMessages(ctx, s, false, true)
+}
+
+// Compact asks the CLI to compact the conversation history. The /compact
+// turn is answered by a result like any user message, so it is kept for
+// replay the same way.
+func (s *supervisedQuery) Compact(ctx context.Context, instructions string) error {
+	content := []ContentBlock{TextContentBlock{Type: "text", Text: compactPrompt(instructions)}}
+
+	return s.sendPending(content, func(q Query) error {
+		return compactQuery(ctx, q, instructions)
+	})
+}
+
+// TransportStats returns the current process's stdin write counters.
+func (s *supervisedQuery) TransportStats() TransportStats {
+	return queryTransportStats(s.current())
+}
@@ -0,0 +1,165 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+func crashError() error {
+	return clauderrs.NewProcessError(clauderrs.ErrCodeProcessCrashed, "killed", nil, -1, "").
+		WithSignal("killed")
+}
+
+func TestSupervisedQuery_RestartsAndReplaysUnansweredMessages(t *testing.T) {
+	first, second := newChanQuery(), newChanQuery()
+	var resumeOpts *Options
+	starts := 0
+	start := func(_ string, opts *Options) (Query, error) {
+		starts++
+		if starts == 1 {
+			return first, nil
+		}
+		resumeOpts = opts
+
+		return second, nil
+	}
+
+	s, err := newSupervisedQuery("hello", &Options{
+		Model:   "claude-sonnet-4-5",
+		Restart: &RestartPolicy{MaxRestarts: 1, InitialBackoff: time.Millisecond},
+	}, start)
+	if err != nil {
+		t.Fatalf("newSupervisedQuery: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	init := &SystemInitMessage{}
+	init.SessionIDField = "session-from-cli"
+	first.msgs <- init
+	first.msgs <- &SDKResultMessage{Subtype: ResultSubtypeSuccess}
+
+	if err := s.SendUserMessage(ctx, "second"); err != nil {
+		t.Fatalf("SendUserMessage: %v", err)
+	}
+	for range 2 {
+		if _, err := s.Next(ctx); err != nil {
+			t.Fatalf("unexpected error before crash: %v", err)
+		}
+	}
+	first.errs <- crashError()
+
+	msg, err := s.Next(ctx)
+	if err != nil {
+		t.Fatalf("expected restart event, got %v", err)
+	}
+	restart, ok := msg.(*SDKRestartMessage)
+	if !ok {
+		t.Fatalf("expected *SDKRestartMessage, got %T", msg)
+	}
+	if restart.Attempt != 1 || restart.ReplayedMessages != 1 || !clauderrs.IsProcessError(restart.Cause) {
+		t.Errorf("unexpected restart event: %+v", restart)
+	}
+	if restart.ResumedSessionID != "session-from-cli" || resumeOpts.Resume != "session-from-cli" {
+		t.Errorf("expected resume of session-from-cli, got %q / %q", restart.ResumedSessionID, resumeOpts.Resume)
+	}
+	if resumeOpts.Model != "claude-sonnet-4-5" {
+		t.Errorf("expected options to carry over, got model %q", resumeOpts.Model)
+	}
+	if len(second.prompts) != 1 || second.prompts[0] != "second" {
+		t.Errorf("expected only the unanswered message replayed, got %v", second.prompts)
+	}
+
+	// The budget is spent: the next crash is returned to the caller.
+	second.errs <- crashError()
+	if _, err := s.Next(ctx); !clauderrs.IsProcessError(err) {
+		t.Fatalf("expected process error once the budget is spent, got %v", err)
+	}
+}
+
+func TestSupervisedQuery_DoesNotRestartOnOtherErrors(t *testing.T) {
+	q := newChanQuery()
+	starts := 0
+	s, err := newSupervisedQuery("", &Options{Restart: &RestartPolicy{}}, func(string, *Options) (Query, error) {
+		starts++
+
+		return q, nil
+	})
+	if err != nil {
+		t.Fatalf("newSupervisedQuery: %v", err)
+	}
+
+	protoErr := clauderrs.NewProtocolError(clauderrs.ErrCodeMessageParseFailed, "bad line", nil)
+	q.errs <- protoErr
+
+	if _, err := s.Next(context.Background()); !errors.Is(err, protoErr) || starts != 1 {
+		t.Fatalf("expected protocol error without restart, got %v after %d starts", err, starts)
+	}
+}
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	p := RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for recent, w := range want {
+		if got := p.backoff(recent); got != w {
+			t.Errorf("backoff(%d) = %s, want %s", recent, got, w)
+		}
+	}
+
+	if err := validateRestartPolicy(&RestartPolicy{MaxRestarts: -1}); !clauderrs.IsValidationError(err) {
+		t.Errorf("expected validation error for negative budget, got %v", err)
+	}
+}
+
+// compactingChanQuery is a chanQuery that can compact, as queryImpl does.
+type compactingChanQuery struct{ *chanQuery }
+
+func (q compactingChanQuery) Compact(ctx context.Context, instructions string) error {
+	return q.SendUserMessage(ctx, compactPrompt(instructions))
+}
+
+func TestSupervisedQuery_CompactResultDoesNotAnswerLaterMessage(t *testing.T) {
+	first, second := compactingChanQuery{newChanQuery()}, newChanQuery()
+	queries := []Query{first, second}
+	s, err := newSupervisedQuery("", &Options{
+		Restart: &RestartPolicy{MaxRestarts: 1, InitialBackoff: time.Millisecond},
+	}, func(string, *Options) (Query, error) {
+		q := queries[0]
+		queries = queries[1:]
+
+		return q, nil
+	})
+	if err != nil {
+		t.Fatalf("newSupervisedQuery: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Compact(ctx, "keep the plan"); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if err := s.SendUserMessage(ctx, "next"); err != nil {
+		t.Fatalf("SendUserMessage: %v", err)
+	}
+
+	// The only result answers the compaction; "next" is still unanswered.
+	first.msgs <- &SDKResultMessage{Subtype: ResultSubtypeSuccess}
+	if _, err := s.Next(ctx); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	first.errs <- crashError()
+	if _, err := s.Next(ctx); err != nil {
+		t.Fatalf("expected restart event, got %v", err)
+	}
+
+	if len(second.prompts) != 1 || second.prompts[0] != "next" {
+		t.Errorf("expected the unanswered message replayed, got %v", second.prompts)
+	}
+}
@@ -0,0 +1,543 @@
+package claude
+
+// This file assembles the live tree of subagents spawned during a session.
+// Subagent lifecycle is reported through two independent channels: the
+// SubagentStart/SubagentStop hooks (which carry the CLI's agent ID) and the
+// message stream (where every message produced inside a subagent carries the
+// parent_tool_use_id of the Task tool call that spawned it). The tracker
+// correlates both into a single record per subagent.
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Tool names used by the CLI for spawning subagents.
+const (
+	toolNameTask  = "Task"
+	toolNameAgent = "Agent"
+)
+
+// SubagentEventType identifies the kind of lifecycle change reported by a
+// SubagentTracker.
+type SubagentEventType string
+
+const (
+	// SubagentEventStarted is emitted once when a subagent begins running.
+	SubagentEventStarted SubagentEventType = "started"
+	// SubagentEventToolCall is emitted when a subagent invokes a tool.
+	SubagentEventToolCall SubagentEventType = "tool_call"
+	// SubagentEventStopped is emitted once when a subagent finishes.
+	SubagentEventStopped SubagentEventType = "stopped"
+)
+
+// SubagentEvent describes a lifecycle change of a tracked subagent.
+// Subagent is a snapshot taken at the time of the event.
+type SubagentEvent struct {
+	Type     SubagentEventType
+	Subagent Subagent
+	// ToolCall is set for SubagentEventToolCall events.
+	ToolCall *SubagentToolCall
+}
+
+// SubagentToolCall records a tool invocation made by a subagent.
+type SubagentToolCall struct {
+	ID          string
+	Name        string
+	Input       JSONValue
+	Result      *ToolResultContentBlock // nil until the tool result arrives
+	StartedAt   time.Time
+	CompletedAt time.Time // zero until the tool result arrives
+}
+
+// Subagent is a snapshot of a single node in the subagent tree.
+type Subagent struct {
+	// ToolUseID is the ID of the Task tool call that spawned the subagent.
+	// Messages produced by the subagent carry it as parent_tool_use_id.
+	ToolUseID string
+	// ParentToolUseID is the ToolUseID of the spawning subagent, or nil when
+	// the subagent was spawned by the main agent.
+	ParentToolUseID *string
+	// AgentID and AgentType are reported by the SubagentStart hook.
+	AgentID   string
+	AgentType string
+	// TranscriptPath is reported by the SubagentStop hook.
+	TranscriptPath string
+	// Input is the decoded Task tool input (type, model, prompt).
+	Input AgentInput
+
+	StartedAt time.Time
+	StoppedAt time.Time // zero while the subagent is running
+
+	Messages  []SDKMessage
+	ToolCalls []SubagentToolCall
+	Children  []string // ToolUseIDs of nested subagents
+
+	// Usage accumulates the token usage of the subagent's assistant messages.
+	Usage Usage
+	// CostUSD is an estimate: the session's TotalCostUSD apportioned by the
+	// share of input and output tokens the subagent consumed. It is filled in
+	// when a result message is observed.
+	CostUSD float64
+}
+
+// Running reports whether the subagent has started and not yet stopped.
+func (s Subagent) Running() bool {
+	return !s.StartedAt.IsZero() && s.StoppedAt.IsZero()
+}
+
+// subagentRecord is the mutable state behind a Subagent snapshot.
+type subagentRecord struct {
+	Subagent
+	seenMessageIDs map[string]bool
+	started        bool
+	stopped        bool
+}
+
+// SubagentTracker builds the live tree of subagents from hook callbacks and
+// the message stream.
+//
+// Install the tracker's hooks into Options.Hooks and pass every message
+// received from the query to Observe:
+//
+//	tracker := claude.NewSubagentTracker(func(evt claude.SubagentEvent) {
+//	    log.Printf("%s %s (%s)", evt.Type, evt.Subagent.Input.SubagentType, evt.Subagent.ToolUseID)
+//	})
+//	opts := &claude.Options{Hooks: tracker.Hooks()}
+//	...
+//	for {
+//	    msg, err := q.Next(ctx)
+//	    if err != nil {
+//	        break
+//	    }
+//	    tracker.Observe(msg)
+//	}
+//
+// A SubagentTracker is safe for concurrent use. The event callback is invoked
+// synchronously and must not call back into the tracker.
+type SubagentTracker struct {
+	mu        sync.Mutex
+	agents    map[string]*subagentRecord // keyed by ToolUseID
+	order     []string
+	byAgentID map[string]string // AgentID -> ToolUseID
+	// unclaimed holds hook-started agents whose Task tool call has not been
+	// observed yet (hooks run concurrently with message delivery).
+	unclaimed []*subagentRecord
+	mainUsage Usage
+	mainSeen  map[string]bool
+	onEvent   func(SubagentEvent)
+	now       func() time.Time
+}
+
+// NewSubagentTracker creates a tracker. onEvent may be nil.
+func NewSubagentTracker(onEvent func(SubagentEvent)) *SubagentTracker {
+	return &SubagentTracker{
+		agents:    make(map[string]*subagentRecord),
+		byAgentID: make(map[string]string),
+		mainSeen:  make(map[string]bool),
+		onEvent:   onEvent,
+		now:       time.Now,
+	}
+}
+
+// Hooks returns SubagentStart and SubagentStop hook matchers that feed the
+// tracker. Merge them into Options.Hooks alongside any other hooks.
+func (t *SubagentTracker) Hooks() map[HookEvent][]HookCallbackMatcher {
+	return map[HookEvent][]HookCallbackMatcher{
+		HookEventSubagentStart: {{Hooks: []HookCallback{t.subagentStartHook}}},
+		HookEventSubagentStop:  {{Hooks: []HookCallback{t.subagentStopHook}}},
+	}
+}
+
+func (t *SubagentTracker) subagentStartHook(
+	_ context.Context,
+	input HookInput,
+	toolUseID *string,
+) (HookJSONOutput, error) {
+	if in, ok := input.(SubagentStartHookInput); ok {
+		t.handleStart(in, toolUseID)
+	}
+
+	return SyncHookOutput{}, nil
+}
+
+func (t *SubagentTracker) subagentStopHook(
+	_ context.Context,
+	input HookInput,
+	_ *string,
+) (HookJSONOutput, error) {
+	if in, ok := input.(SubagentStopHookInput); ok {
+		t.handleStop(in)
+	}
+
+	return SyncHookOutput{}, nil
+}
+
+// Observe feeds a message from the query stream into the tracker.
+func (t *SubagentTracker) Observe(msg SDKMessage) {
+	var events []SubagentEvent
+
+	t.mu.Lock()
+	switch m := msg.(type) {
+	case *SDKAssistantMessage:
+		events = t.observeAssistant(m.ParentToolUseID, m)
+	case *SDKUserMessage:
+		events = t.observeUser(m.ParentToolUseID, m, m.Message.Content)
+	case *SDKUserMessageReplay:
+		events = t.observeUser(m.ParentToolUseID, m, m.Message.Content)
+	case *SDKToolProgressMessage:
+		if rec := t.owner(m.ParentToolUseID); rec != nil {
+			rec.Messages = append(rec.Messages, m)
+		}
+	case *SDKResultMessage:
+		t.apportionCost(m.TotalCostUSD)
+	}
+	t.mu.Unlock()
+
+	t.emit(events)
+}
+
+// Get returns a snapshot of the subagent spawned by the given Task tool call.
+func (t *SubagentTracker) Get(toolUseID string) (Subagent, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rec, ok := t.agents[toolUseID]
+	if !ok {
+		return Subagent{}, false
+	}
+
+	return rec.snapshot(), true
+}
+
+// Subagents returns snapshots of all tracked subagents in spawn order.
+func (t *SubagentTracker) Subagents() []Subagent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Subagent, 0, len(t.order))
+	for _, id := range t.order {
+		out = append(out, t.agents[id].snapshot())
+	}
+
+	return out
+}
+
+// Roots returns snapshots of the subagents spawned directly by the main agent.
+func (t *SubagentTracker) Roots() []Subagent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Subagent, 0)
+	for _, id := range t.order {
+		if rec := t.agents[id]; rec.ParentToolUseID == nil {
+			out = append(out, rec.snapshot())
+		}
+	}
+
+	return out
+}
+
+// Running returns snapshots of the subagents that are currently running.
+func (t *SubagentTracker) Running() []Subagent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Subagent, 0)
+	for _, id := range t.order {
+		if rec := t.agents[id]; rec.started && !rec.stopped {
+			out = append(out, rec.snapshot())
+		}
+	}
+
+	return out
+}
+
+func (t *SubagentTracker) observeAssistant(
+	parent *string,
+	m *SDKAssistantMessage,
+) []SubagentEvent {
+	var events []SubagentEvent
+
+	owner := t.owner(parent)
+	if owner != nil {
+		events = append(events, t.markStarted(owner)...)
+		owner.Messages = append(owner.Messages, m)
+		if !owner.seenMessageIDs[m.Message.ID] {
+			owner.seenMessageIDs[m.Message.ID] = true
+			addUsage(&owner.Usage, m.Message.Usage)
+		}
+	} else if !t.mainSeen[m.Message.ID] {
+		t.mainSeen[m.Message.ID] = true
+		addUsage(&t.mainUsage, m.Message.Usage)
+	}
+
+	for _, block := range m.Message.Content {
+		toolUse, ok := block.(ToolUseContentBlock)
+		if !ok {
+			continue
+		}
+
+		if toolUse.Name == toolNameTask || toolUse.Name == toolNameAgent {
+			events = append(events, t.spawn(parent, toolUse)...)
+		}
+
+		if owner != nil {
+			call := SubagentToolCall{
+				ID:        toolUse.ID,
+				Name:      toolUse.Name,
+				Input:     toolUse.Input,
+				StartedAt: t.now(),
+			}
+			owner.ToolCalls = append(owner.ToolCalls, call)
+			events = append(events, SubagentEvent{
+				Type:     SubagentEventToolCall,
+				Subagent: owner.snapshot(),
+				ToolCall: &call,
+			})
+		}
+	}
+
+	return events
+}
+
+func (t *SubagentTracker) observeUser(
+	parent *string,
+	m SDKMessage,
+	content []ContentBlock,
+) []SubagentEvent {
+	var events []SubagentEvent
+
+	owner := t.owner(parent)
+	if owner != nil {
+		events = append(events, t.markStarted(owner)...)
+		owner.Messages = append(owner.Messages, m)
+	}
+
+	for _, block := range content {
+		result, ok := block.(ToolResultContentBlock)
+		if !ok {
+			continue
+		}
+
+		// A tool result for a Task call means that subagent has finished.
+		if rec, ok := t.agents[result.ToolUseID]; ok {
+			events = append(events, t.markStopped(rec)...)
+		}
+
+		if owner == nil {
+			continue
+		}
+		for i := range owner.ToolCalls {
+			if owner.ToolCalls[i].ID == result.ToolUseID {
+				res := result
+				owner.ToolCalls[i].Result = &res
+				owner.ToolCalls[i].CompletedAt = t.now()
+			}
+		}
+	}
+
+	return events
+}
+
+// spawn registers the subagent created by a Task tool call.
+func (t *SubagentTracker) spawn(parent *string, toolUse ToolUseContentBlock) []SubagentEvent {
+	if _, exists := t.agents[toolUse.ID]; exists {
+		return nil
+	}
+
+	var input AgentInput
+	_ = json.Unmarshal(toolUse.Input, &input)
+
+	rec := t.claim(input.SubagentType)
+	if rec == nil {
+		rec = &subagentRecord{seenMessageIDs: make(map[string]bool)}
+	}
+	rec.ToolUseID = toolUse.ID
+	rec.Input = input
+	if parent != nil {
+		p := *parent
+		rec.ParentToolUseID = &p
+		if parentRec, ok := t.agents[p]; ok {
+			parentRec.Children = append(parentRec.Children, toolUse.ID)
+		}
+	}
+	if rec.AgentID != "" {
+		t.byAgentID[rec.AgentID] = toolUse.ID
+	}
+
+	t.agents[toolUse.ID] = rec
+	t.order = append(t.order, toolUse.ID)
+
+	if rec.started {
+		// Started via hook before the Task call was observed.
+		evt := SubagentEvent{Type: SubagentEventStarted, Subagent: rec.snapshot()}
+		if rec.stopped {
+			return []SubagentEvent{evt, {Type: SubagentEventStopped, Subagent: rec.snapshot()}}
+		}
+
+		return []SubagentEvent{evt}
+	}
+
+	return nil
+}
+
+// claim removes and returns the oldest hook-started record of the given type
+// that has not been matched to a Task call yet.
+func (t *SubagentTracker) claim(agentType string) *subagentRecord {
+	for i, rec := range t.unclaimed {
+		if agentType == "" || rec.AgentType == "" || rec.AgentType == agentType {
+			t.unclaimed = append(t.unclaimed[:i], t.unclaimed[i+1:]...)
+
+			return rec
+		}
+	}
+
+	return nil
+}
+
+func (t *SubagentTracker) handleStart(in SubagentStartHookInput, toolUseID *string) {
+	var events []SubagentEvent
+
+	t.mu.Lock()
+	rec := t.match(in.AgentType, toolUseID)
+	switch {
+	case rec != nil:
+		rec.AgentID = in.AgentID
+		rec.AgentType = in.AgentType
+		t.byAgentID[in.AgentID] = rec.ToolUseID
+		events = t.markStarted(rec)
+	default:
+		// The Task call has not been observed yet; hold the record until it is.
+		rec = &subagentRecord{seenMessageIDs: make(map[string]bool)}
+		rec.AgentID = in.AgentID
+		rec.AgentType = in.AgentType
+		rec.StartedAt = t.now()
+		rec.started = true
+		t.unclaimed = append(t.unclaimed, rec)
+	}
+	t.mu.Unlock()
+
+	t.emit(events)
+}
+
+// match finds the tracked subagent a SubagentStart hook refers to: the Task
+// call named by toolUseID when the CLI provides it, otherwise the oldest
+// subagent of the same type that has no agent ID yet.
+func (t *SubagentTracker) match(agentType string, toolUseID *string) *subagentRecord {
+	if toolUseID != nil {
+		if rec, ok := t.agents[*toolUseID]; ok {
+			return rec
+		}
+	}
+
+	for _, id := range t.order {
+		rec := t.agents[id]
+		if rec.AgentID != "" {
+			continue
+		}
+		if agentType == "" || rec.Input.SubagentType == "" || rec.Input.SubagentType == agentType {
+			return rec
+		}
+	}
+
+	return nil
+}
+
+func (t *SubagentTracker) handleStop(in SubagentStopHookInput) {
+	var events []SubagentEvent
+
+	t.mu.Lock()
+	if id, ok := t.byAgentID[in.AgentID]; ok {
+		rec := t.agents[id]
+		rec.TranscriptPath = in.AgentTranscriptPath
+		events = t.markStopped(rec)
+	} else {
+		for _, rec := range t.unclaimed {
+			if rec.AgentID == in.AgentID {
+				rec.TranscriptPath = in.AgentTranscriptPath
+				rec.StoppedAt = t.now()
+				rec.stopped = true
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	t.emit(events)
+}
+
+func (t *SubagentTracker) markStarted(rec *subagentRecord) []SubagentEvent {
+	if rec.started {
+		return nil
+	}
+	rec.started = true
+	rec.StartedAt = t.now()
+
+	return []SubagentEvent{{Type: SubagentEventStarted, Subagent: rec.snapshot()}}
+}
+
+func (t *SubagentTracker) markStopped(rec *subagentRecord) []SubagentEvent {
+	if rec.stopped {
+		return nil
+	}
+	events := t.markStarted(rec)
+	rec.stopped = true
+	rec.StoppedAt = t.now()
+
+	return append(events, SubagentEvent{Type: SubagentEventStopped, Subagent: rec.snapshot()})
+}
+
+// owner returns the record a message with the given parent_tool_use_id
+// belongs to, or nil for main-agent messages.
+func (t *SubagentTracker) owner(parent *string) *subagentRecord {
+	if parent == nil {
+		return nil
+	}
+
+	return t.agents[*parent]
+}
+
+// apportionCost distributes the session cost across subagents by their share
+// of input and output tokens.
+func (t *SubagentTracker) apportionCost(totalCostUSD float64) {
+	total := t.mainUsage.InputTokens + t.mainUsage.OutputTokens
+	for _, rec := range t.agents {
+		total += rec.Usage.InputTokens + rec.Usage.OutputTokens
+	}
+	if total == 0 {
+		return
+	}
+
+	for _, rec := range t.agents {
+		tokens := rec.Usage.InputTokens + rec.Usage.OutputTokens
+		rec.CostUSD = totalCostUSD * float64(tokens) / float64(total)
+	}
+}
+
+func (t *SubagentTracker) emit(events []SubagentEvent) {
+	if t.onEvent == nil {
+		return
+	}
+	for _, evt := range events {
+		t.onEvent(evt)
+	}
+}
+
+// snapshot returns a copy of the record that is safe to hand to callers.
+func (r *subagentRecord) snapshot() Subagent {
+	s := r.Subagent
+	s.Messages = append([]SDKMessage(nil), r.Messages...)
+	s.ToolCalls = append([]SubagentToolCall(nil), r.ToolCalls...)
+	s.Children = append([]string(nil), r.Children...)
+
+	return s
+}
+
+func addUsage(dst *Usage, u Usage) {
+	dst.InputTokens += u.InputTokens
+	dst.OutputTokens += u.OutputTokens
+	dst.CacheReadInputTokens += u.CacheReadInputTokens
+	dst.CacheCreationInputTokens += u.CacheCreationInputTokens
+}
@@ -0,0 +1,246 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// Default separators used by text streams.
+const (
+	DefaultTextStreamBlockSeparator = "\n"
+	DefaultTextStreamTurnSeparator  = "\n\n"
+)
+
+// TextStreamOptions configures a text stream created by NewTextStream.
+type TextStreamOptions struct {
+	// BlockSeparator is written between consecutive text (and thinking)
+	// blocks of the same turn. Nil uses DefaultTextStreamBlockSeparator.
+	BlockSeparator *string
+	// TurnSeparator is written between turns when MultiTurn is set. Nil uses
+	// DefaultTextStreamTurnSeparator.
+	TurnSeparator *string
+	// ExcludeThinking omits thinking content from the stream.
+	ExcludeThinking bool
+	// MultiTurn keeps the stream open across result messages until the query
+	// ends. By default the stream ends at the first result message.
+	MultiTurn bool
+}
+
+// NewTextStream returns a reader that yields the main agent's assistant text
+// from q as it arrives.
+//
+// With Options.IncludePartialMessages enabled, text is written as text_delta
+// events stream in; otherwise it is written when each completed text block
+// arrives. Subagent output is not included.
+//
+// The stream ends with io.EOF after a successful result (or at the end of the
+// query in MultiTurn mode). When a result reports an error, Read returns the
+// result's error (a *clauderrs.ResultError) once the text has been consumed.
+//
+// The stream consumes messages from q, so q must not be read concurrently.
+// Closing the reader stops the stream but does not close q.
+func NewTextStream(ctx context.Context, q Query, opts *TextStreamOptions) io.ReadCloser {
+	if opts == nil {
+		opts = &TextStreamOptions{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+
+	s := &textStream{
+		q:              q,
+		w:              pw,
+		blockSeparator: stringOrDefault(opts.BlockSeparator, DefaultTextStreamBlockSeparator),
+		turnSeparator:  stringOrDefault(opts.TurnSeparator, DefaultTextStreamTurnSeparator),
+		opts:           opts,
+		streamed:       make(map[string]bool),
+	}
+	go func() {
+		_ = pw.CloseWithError(s.run(ctx))
+	}()
+
+	return &textStreamReader{PipeReader: pr, cancel: cancel}
+}
+
+// TextStream returns a reader over the assistant text of the current query.
+// See NewTextStream.
+func (c *ClaudeSDKClient) TextStream(ctx context.Context, opts *TextStreamOptions) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.query == nil {
+		return nil, clauderrs.NewClientError(
+			clauderrs.ErrCodeNoActiveQuery,
+			errNoActiveQuery,
+			nil,
+		)
+	}
+
+	return NewTextStream(ctx, c.query, opts), nil
+}
+
+type textStreamReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+// Close stops the stream.
+func (r *textStreamReader) Close() error {
+	r.cancel()
+
+	return r.PipeReader.Close()
+}
+
+type textStream struct {
+	q              Query
+	w              *io.PipeWriter
+	blockSeparator string
+	turnSeparator  string
+	opts           *TextStreamOptions
+
+	// streamed holds IDs of messages whose text was written from deltas, so
+	// the completed message is not written twice.
+	streamed map[string]bool
+	// wroteInTurn reports whether text was written in the current turn.
+	wroteInTurn bool
+	// wroteAny reports whether any text was written at all.
+	wroteAny bool
+	// pending is written before the next text.
+	pending string
+}
+
+// run pumps messages until the stream ends. The returned error is delivered
+// to the reader; nil means io.EOF.
+func (s *textStream) run(ctx context.Context) error {
+	for {
+		msg, err := s.q.Next(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		done, err := s.handle(msg)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+func (s *textStream) handle(msg SDKMessage) (bool, error) {
+	switch m := msg.(type) {
+	case *SDKStreamEvent:
+		if m.ParentToolUseID != nil {
+			return false, nil
+		}
+
+		return false, s.handleEvent(m.Event)
+	case *SDKAssistantMessage:
+		if m.ParentToolUseID != nil || s.streamed[m.Message.ID] {
+			return false, nil
+		}
+
+		return false, s.handleBlocks(m.Message.Content)
+	case *SDKResultMessage:
+		if err := m.Err(); err != nil {
+			return true, err
+		}
+		if !s.opts.MultiTurn {
+			return true, nil
+		}
+		s.wroteInTurn = false
+		if s.wroteAny {
+			s.pending = s.turnSeparator
+		}
+	}
+
+	return false, nil
+}
+
+func (s *textStream) handleEvent(evt RawMessageStreamEvent) error {
+	switch e := evt.(type) {
+	case MessageStartEvent:
+		s.streamed[e.Message.ID] = true
+	case ContentBlockStartEvent:
+		switch e.ContentBlock.(type) {
+		case TextContentBlock, TextBlock:
+			s.startBlock()
+		case ThinkingBlock:
+			if !s.opts.ExcludeThinking {
+				s.startBlock()
+			}
+		}
+	case ContentBlockDeltaEvent:
+		switch {
+		case e.Delta.TextDelta != nil:
+			return s.write(*e.Delta.TextDelta)
+		case e.Delta.Thinking != nil && !s.opts.ExcludeThinking:
+			return s.write(*e.Delta.Thinking)
+		}
+	}
+
+	return nil
+}
+
+func (s *textStream) handleBlocks(blocks []ContentBlock) error {
+	for _, block := range blocks {
+		var text string
+		switch b := block.(type) {
+		case TextContentBlock:
+			text = b.Text
+		case TextBlock:
+			text = b.Text
+		case ThinkingBlock:
+			if s.opts.ExcludeThinking {
+				continue
+			}
+			text = b.Thinking
+		default:
+			continue
+		}
+
+		s.startBlock()
+		if err := s.write(text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startBlock schedules the block separator if the turn already has text.
+func (s *textStream) startBlock() {
+	if s.wroteInTurn && s.pending == "" {
+		s.pending = s.blockSeparator
+	}
+}
+
+func (s *textStream) write(text string) error {
+	if text == "" {
+		return nil
+	}
+
+	if s.pending != "" {
+		text = s.pending + text
+		s.pending = ""
+	}
+	s.wroteInTurn = true
+	s.wroteAny = true
+
+	_, err := io.WriteString(s.w, text)
+
+	return err
+}
+
+func stringOrDefault(s *string, def string) string {
+	if s == nil {
+		return def
+	}
+
+	return *s
+}
@@ -0,0 +1,179 @@
+package claude
+
+import (
+	"context"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/internal/transport"
+)
+
+// Transport carries the stream-json protocol between the SDK and a Claude
+// Code CLI. The default transport runs the CLI as a subprocess and talks to it
+// over stdin and stdout; Options.TransportFactory can supply another one, for
+// example a CLI behind a command wrapper, inside an existing PTY, across a
+// Unix socket to a sidecar, or an in-memory fake for tests.
+//
+// Read and Write may be called concurrently with each other, and Write may be
+// called from several goroutines at once; implementations must keep frames
+// from interleaving.
+type Transport interface {
+	// Read returns the next message line from the CLI, with or without its
+	// trailing newline. It returns io.EOF once the CLI's output has ended.
+	Read(ctx context.Context) ([]byte, error)
+
+	// Write sends one JSON message to the CLI. data does not include the
+	// newline delimiter; the transport adds it.
+	Write(ctx context.Context, data []byte) error
+
+	// CloseInput signals the end of input (for a subprocess, closes stdin)
+	// while output can still be read. It must be safe to call more than once.
+	CloseInput() error
+
+	// Close shuts the transport down and releases its resources.
+	Close() error
+}
+
+// ProcessTransport is implemented by transports that run the CLI as a
+// process. When the CLI's output ends, the SDK calls Wait to report an
+// abnormal exit as the stream's error instead of io.EOF.
+type ProcessTransport interface {
+	Transport
+
+	// Wait blocks until the process exits or ctx is done. It returns nil for
+	// a clean exit, a *clauderrs.ProcessError for an abnormal one, and
+	// ctx.Err() if ctx ends first.
+	Wait(ctx context.Context) error
+}
+
+// TransportStatsProvider is implemented by transports that report write
+// counters, and by every Query this package returns.
+type TransportStatsProvider interface {
+	TransportStats() TransportStats
+}
+
+// TransportConfig describes the CLI a transport should connect to. It is
+// built from Options for every query.
+type TransportConfig struct {
+	// Executable is Options.PathToClaudeCodeExecutable; empty means
+	// discover it as FindClaudeExecutable does.
+	Executable string
+	// Args are the CLI arguments for the stream-json protocol and the
+	// configured options.
+	Args []string
+	// Env holds KEY=VALUE entries to add to the base environment.
+	Env []string
+	// BaseEnv is the environment Env is added to, as chosen by
+	// Options.EnvPolicy. Nil means the SDK process's own environment.
+	BaseEnv []string
+	// Cwd is the working directory, or empty for the current one.
+	Cwd string
+	// User, Group and SupplementaryGroups are the identity to run the CLI
+	// as; see Options.User.
+	User                string
+	Group               string
+	SupplementaryGroups []string
+	// Stderr receives the CLI's stderr output line by line, if set.
+	Stderr func(string)
+	// MaxBufferSize is the largest message line accepted, in bytes.
+	MaxBufferSize int
+	// ShutdownGracePeriod, StderrTailSize, CommandWrapper and
+	// ResourceLimits mirror the fields in Options.
+	ShutdownGracePeriod time.Duration
+	StderrTailSize      int
+	CommandWrapper      []string
+	ResourceLimits      *ResourceLimits
+}
+
+// TransportFactory creates the transport for a query.
+type TransportFactory func(ctx context.Context, config *TransportConfig) (Transport, error)
+
+// DefaultTransportFactory starts the CLI as a subprocess. It is used when
+// Options.TransportFactory is nil, and can be called from a custom factory
+// that adjusts the configuration first.
+func DefaultTransportFactory(ctx context.Context, config *TransportConfig) (Transport, error) {
+	proc, err := transport.NewProcess(ctx, processConfig(config))
+	if err != nil {
+		return nil, err
+	}
+
+	return processTransport{proc}, nil
+}
+
+// processConfig converts config for transport.NewProcess.
+func processConfig(config *TransportConfig) *transport.ProcessConfig {
+	return &transport.ProcessConfig{
+		Executable:          config.Executable,
+		Args:                config.Args,
+		Env:                 config.Env,
+		BaseEnv:             config.BaseEnv,
+		Cwd:                 config.Cwd,
+		StderrHandler:       config.Stderr,
+		MaxBufferSize:       config.MaxBufferSize,
+		User:                config.User,
+		Group:               config.Group,
+		SupplementaryGroups: config.SupplementaryGroups,
+		ShutdownGracePeriod: config.ShutdownGracePeriod,
+		StderrTailSize:      config.StderrTailSize,
+		CommandWrapper:      config.CommandWrapper,
+		ResourceLimits:      config.ResourceLimits.processLimits(),
+	}
+}
+
+// TransportStats reports writes to the CLI's stdin. All writes (user
+// messages, control requests and control responses) go through one ordered
+// queue; a full queue blocks the caller.
+type TransportStats struct {
+	// QueueDepth is the number of messages waiting to be written.
+	QueueDepth int
+	// MaxQueueDepth is the highest QueueDepth observed.
+	MaxQueueDepth int
+	// MessagesWritten and BytesWritten count completed writes.
+	MessagesWritten uint64
+	BytesWritten    uint64
+	// WritesCancelled counts writes abandoned through their context before
+	// they started.
+	WritesCancelled uint64
+	// EnqueueWait is the total time callers spent blocked on a full queue.
+	EnqueueWait time.Duration
+	// WriteTime is the total time spent writing to stdin.
+	WriteTime time.Duration
+}
+
+// processTransport adapts a subprocess to Transport.
+type processTransport struct {
+	proc *transport.Process
+}
+
+func (t processTransport) Read(ctx context.Context) ([]byte, error) {
+	return t.proc.Transport().Read(ctx)
+}
+
+func (t processTransport) Write(ctx context.Context, data []byte) error {
+	return t.proc.Transport().Write(ctx, data)
+}
+
+func (t processTransport) CloseInput() error {
+	return t.proc.CloseInput()
+}
+
+func (t processTransport) Close() error {
+	return t.proc.Close()
+}
+
+func (t processTransport) Wait(ctx context.Context) error {
+	return t.proc.Wait(ctx)
+}
+
+func (t processTransport) TransportStats() TransportStats {
+	stats := t.proc.Transport().Stats()
+
+	return TransportStats{
+		QueueDepth:      stats.QueueDepth,
+		MaxQueueDepth:   stats.MaxQueueDepth,
+		MessagesWritten: stats.FramesWritten,
+		BytesWritten:    stats.BytesWritten,
+		WritesCancelled: stats.WritesCancelled,
+		EnqueueWait:     stats.EnqueueWait,
+		WriteTime:       stats.WriteTime,
+	}
+}
@@ -0,0 +1,323 @@
+package claude
+
+import (
+	"context"
+	"io"
+	"iter"
+	"strings"
+	"sync"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// Turn is a handle for one prompt sent with ClaudeSDKClient.Send and the
+// response it produces. Messages are recorded as they arrive, so a Turn can
+// be iterated, waited on or inspected at any time, from any goroutine.
+type Turn struct {
+	index int
+
+	mu       sync.Mutex
+	messages []SDKMessage
+	text     []string
+	result   *SDKResultMessage
+	err      error
+	// notify is closed and replaced whenever the turn changes.
+	notify chan struct{}
+	done   chan struct{}
+}
+
+func newTurn(index int) *Turn {
+	return &Turn{
+		index:  index,
+		notify: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Index returns the zero-based position of the turn within the client.
+func (t *Turn) Index() int {
+	return t.index
+}
+
+// Done returns a channel that is closed when the turn has finished.
+func (t *Turn) Done() <-chan struct{} {
+	return t.done
+}
+
+// Messages returns an iterator over the turn's messages, starting from the
+// first one and ending after the result message. If the turn fails, the error
+// is yielded last. Iterating does not consume messages; each call replays the
+// turn from the beginning.
+func (t *Turn) Messages(ctx context.Context) iter.Seq2[SDKMessage, error] {
+	return func(yield func(SDKMessage, error) bool) {
+		for i := 0; ; i++ {
+			t.mu.Lock()
+			for i >= len(t.messages) && !t.finished() {
+				notify := t.notify
+				t.mu.Unlock()
+
+				select {
+				case <-notify:
+				case <-ctx.Done():
+					yield(nil, ctx.Err())
+
+					return
+				}
+
+				t.mu.Lock()
+			}
+
+			if i >= len(t.messages) {
+				err := t.err
+				t.mu.Unlock()
+				if err != nil {
+					yield(nil, err)
+				}
+
+				return
+			}
+
+			msg := t.messages[i]
+			t.mu.Unlock()
+
+			if !yield(msg, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Wait blocks until the turn finishes and returns its result message. The
+// error is the stream error that ended the turn, or the result's error (a
+// *clauderrs.ResultError) when Claude reported a failure.
+func (t *Turn) Wait(ctx context.Context) (*SDKResultMessage, error) {
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return t.Result(), t.Err()
+}
+
+// Result returns the turn's result message, or nil while the turn is running
+// or if it failed before a result arrived.
+func (t *Turn) Result() *SDKResultMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.result
+}
+
+// Err returns the error that ended the turn, or the result's error when the
+// result reports a failure. It returns nil while the turn is running.
+func (t *Turn) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return t.err
+	}
+	if t.result != nil {
+		return t.result.Err()
+	}
+
+	return nil
+}
+
+// Text returns the main agent's assistant text received so far, with text
+// blocks separated by newlines.
+func (t *Turn) Text() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return strings.Join(t.text, "\n")
+}
+
+// finished reports whether the turn is complete. Callers hold t.mu.
+func (t *Turn) finished() bool {
+	return t.result != nil || t.err != nil
+}
+
+// deliver records a message for the turn and reports whether it ended it.
+func (t *Turn) deliver(msg SDKMessage) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, msg)
+
+	var ended bool
+	switch m := msg.(type) {
+	case *SDKAssistantMessage:
+		if m.ParentToolUseID == nil {
+			for _, block := range m.Message.Content {
+				switch b := block.(type) {
+				case TextContentBlock:
+					t.text = append(t.text, b.Text)
+				case TextBlock:
+					t.text = append(t.text, b.Text)
+				}
+			}
+		}
+	case *SDKResultMessage:
+		t.result = m
+		ended = true
+	}
+
+	t.signal(ended)
+
+	return ended
+}
+
+// fail ends the turn with err.
+func (t *Turn) fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.finished() {
+		return
+	}
+	t.err = err
+	t.signal(true)
+}
+
+// signal wakes waiters. Callers hold t.mu.
+func (t *Turn) signal(ended bool) {
+	close(t.notify)
+	t.notify = make(chan struct{})
+	if ended {
+		close(t.done)
+	}
+}
+
+// Send sends a prompt and returns a handle for the turn it starts.
+//
+// The first call starts the query. Turns are queued: if Send is called again
+// before the previous turn has finished, the new turn receives the messages
+// that follow the previous turn's result. Messages are routed to turns by a
+// background reader, so clients using Send should read responses through the
+// returned turns rather than ReceiveMessages or ReceiveResponse.
+func (c *ClaudeSDKClient) Send(ctx context.Context, prompt string) (*Turn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, clauderrs.NewClientError(
+			clauderrs.ErrCodeClientClosed,
+			"client is closed",
+			nil,
+		)
+	}
+
+	c.turnsMu.Lock()
+	if c.dispatchErr != nil {
+		err := c.dispatchErr
+		c.turnsMu.Unlock()
+
+		return nil, err
+	}
+	turn := newTurn(c.turnCount)
+	c.turnCount++
+	c.turns = append(c.turns, turn)
+	c.turnsMu.Unlock()
+
+	var err error
+	if c.query == nil {
+		var q Query
+		q, err = c.startQuery(prompt)
+		if err == nil {
+			c.query = q
+		} else if _, ok := clauderrs.AsSDKError(err); !ok {
+			err = clauderrs.NewClientError(
+				clauderrs.ErrCodeInvalidState,
+				"failed to create query",
+				err,
+			)
+		}
+	} else {
+		err = c.query.SendUserMessage(ctx, prompt)
+	}
+
+	if err != nil {
+		c.turnsMu.Lock()
+		c.turns = c.turns[:len(c.turns)-1]
+		c.turnCount--
+		c.turnsMu.Unlock()
+
+		return nil, err
+	}
+
+	if !c.dispatching {
+		c.dispatching = true
+		dispatchCtx, cancel := context.WithCancel(context.Background())
+		c.dispatchCancel = cancel
+		go c.dispatchTurns(dispatchCtx, c.query)
+	}
+
+	return turn, nil
+}
+
+// dispatchTurns routes messages from the query to the queued turns.
+func (c *ClaudeSDKClient) dispatchTurns(ctx context.Context, q Query) {
+	for {
+		msg, err := q.Next(ctx)
+		if err != nil {
+			c.failTurns(err)
+
+			return
+		}
+
+		c.turnsMu.Lock()
+		var head *Turn
+		if len(c.turns) > 0 {
+			head = c.turns[0]
+		}
+		c.turnsMu.Unlock()
+
+		// Messages outside any turn (nothing was sent) are dropped.
+		if head == nil {
+			continue
+		}
+
+		if head.deliver(msg) {
+			c.turnsMu.Lock()
+			c.turns = c.turns[1:]
+			c.turnsMu.Unlock()
+		}
+	}
+}
+
+// failTurns ends every pending turn after the message stream stopped.
+func (c *ClaudeSDKClient) failTurns(streamErr error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+
+	var err error
+	switch {
+	case closed:
+		err = clauderrs.NewClientError(
+			clauderrs.ErrCodeClientClosed,
+			"client closed before the turn completed",
+			nil,
+		)
+	case streamErr == io.EOF:
+		err = clauderrs.NewNetworkError(
+			clauderrs.ErrCodeConnectionClosed,
+			"query ended before the turn completed",
+			io.ErrUnexpectedEOF,
+		)
+	default:
+		err = streamErr
+	}
+
+	c.turnsMu.Lock()
+	pending := c.turns
+	c.turns = nil
+	c.dispatchErr = err
+	c.turnsMu.Unlock()
+
+	for _, turn := range pending {
+		turn.fail(err)
+	}
+}
@@ -0,0 +1,174 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// chanQuery is a Query stub fed through a channel.
+type chanQuery struct {
+	Query
+	msgs chan SDKMessage
+	errs chan error
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func newChanQuery() *chanQuery {
+	return &chanQuery{msgs: make(chan SDKMessage, 16), errs: make(chan error, 1)}
+}
+
+func (q *chanQuery) Next(ctx context.Context) (SDKMessage, error) {
+	select {
+	case msg, ok := <-q.msgs:
+		if !ok {
+			return nil, io.EOF
+		}
+
+		return msg, nil
+	case err := <-q.errs:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *chanQuery) SendUserMessage(_ context.Context, text string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prompts = append(q.prompts, text)
+
+	return nil
+}
+
+func (q *chanQuery) SendUserMessageWithContent(ctx context.Context, content []ContentBlock) error {
+	for _, block := range content {
+		if text, ok := block.(TextContentBlock); ok {
+			return q.SendUserMessage(ctx, text.Text)
+		}
+	}
+
+	return nil
+}
+
+func (q *chanQuery) Close() error { return nil }
+
+func assistantText(text string) *SDKAssistantMessage {
+	return &SDKAssistantMessage{Message: APIAssistantMessage{
+		Content: []ContentBlock{TextContentBlock{Type: "text", Text: text}},
+	}}
+}
+
+func TestClientSend_QueuesTurns(t *testing.T) {
+	q := newChanQuery()
+	c, _ := NewClient(nil)
+	c.query = q
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	first, err := c.Send(ctx, "first")
+	if err != nil {
+		t.Fatalf("send first: %v", err)
+	}
+	second, err := c.Send(ctx, "second")
+	if err != nil {
+		t.Fatalf("send second: %v", err)
+	}
+
+	q.msgs <- assistantText("one")
+	q.msgs <- &SDKResultMessage{Subtype: ResultSubtypeSuccess, NumTurns: 1}
+	q.msgs <- assistantText("two")
+	q.msgs <- assistantText("more")
+	q.msgs <- &SDKResultMessage{Subtype: ResultSubtypeSuccess, NumTurns: 2}
+
+	res, err := second.Wait(ctx)
+	if err != nil || res.NumTurns != 2 {
+		t.Fatalf("unexpected second result %+v, %v", res, err)
+	}
+	if got := second.Text(); got != "two\nmore" {
+		t.Errorf("unexpected second text %q", got)
+	}
+
+	res, err = first.Wait(ctx)
+	if err != nil || res.NumTurns != 1 {
+		t.Fatalf("unexpected first result %+v, %v", res, err)
+	}
+	if got := first.Text(); got != "one" {
+		t.Errorf("unexpected first text %q", got)
+	}
+
+	var count int
+	for _, err := range first.Messages(ctx) {
+		if err != nil {
+			t.Fatalf("unexpected iteration error: %v", err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 messages in first turn, got %d", count)
+	}
+
+	if len(q.prompts) != 2 || first.Index() != 0 || second.Index() != 1 {
+		t.Errorf("unexpected prompts %v / indexes %d,%d", q.prompts, first.Index(), second.Index())
+	}
+}
+
+func TestClientSend_ResultErrorAndStreamError(t *testing.T) {
+	q := newChanQuery()
+	c, _ := NewClient(nil)
+	c.query = q
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	failing, _ := c.Send(ctx, "first")
+	pending, _ := c.Send(ctx, "second")
+
+	q.msgs <- &SDKResultMessage{Subtype: ResultSubtypeErrorMaxTurns, IsError: true}
+	if _, err := failing.Wait(ctx); !clauderrs.IsResultError(err) {
+		t.Fatalf("expected result error, got %v", err)
+	}
+
+	streamErr := clauderrs.NewProtocolError(clauderrs.ErrCodeMessageParseFailed, "bad line", nil)
+	q.errs <- streamErr
+
+	if _, err := pending.Wait(ctx); !errors.Is(err, streamErr) {
+		t.Fatalf("expected stream error, got %v", err)
+	}
+
+	var gotErr error
+	for _, err := range pending.Messages(ctx) {
+		gotErr = err
+	}
+	if !errors.Is(gotErr, streamErr) {
+		t.Fatalf("expected iteration to end with stream error, got %v", gotErr)
+	}
+
+	if _, err := c.Send(ctx, "third"); !errors.Is(err, streamErr) {
+		t.Fatalf("expected send after stream failure to return the error, got %v", err)
+	}
+}
+
+func TestClientClose_FailsPendingTurns(t *testing.T) {
+	q := newChanQuery()
+	c, _ := NewClient(nil)
+	c.query = q
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	turn, _ := c.Send(ctx, "hello")
+	_ = c.Close()
+
+	if _, err := turn.Wait(ctx); !clauderrs.IsClientError(err) {
+		t.Fatalf("expected client closed error, got %v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package clauderrs
 
+import "strings"
+
 // ClientError represents client-related errors.
 type ClientError struct {
 	*BaseError
@@ -28,3 +30,15 @@ func NewVersionMismatchError(currentVersion string, minimumVersion string) *Clie
 
 	return err
 }
+
+// NewUnsupportedOptionsError creates a client error for options the CLI at
+// currentVersion has no flag for. Each option is described as
+// "Options.Field (--flag)".
+func NewUnsupportedOptionsError(currentVersion string, options []string) *ClientError {
+	message := "Claude Code CLI version " + currentVersion + " does not support " + strings.Join(options, ", ") + ". Upgrade the CLI or unset these options."
+	err := NewClientError(ErrCodeUnsupportedOption, message, nil)
+	_ = err.WithMetadata(MetadataKeyCurrentVersion, currentVersion)
+	_ = err.WithMetadata(MetadataKeyUnsupportedOptions, options)
+
+	return err
+}
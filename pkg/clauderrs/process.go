@@ -5,8 +5,22 @@ type ProcessError struct {
 	*BaseError
 	exitCode int
 	stderr   string
+	signal   string
+	limit    ProcessLimit
 }
 
+// ProcessLimit names a resource limit that stopped a process.
+type ProcessLimit string
+
+// Resource limits reported by ProcessError.Limit.
+const (
+	LimitAddressSpace ProcessLimit = "address_space"
+	LimitCPUTime      ProcessLimit = "cpu_time"
+	LimitOpenFiles    ProcessLimit = "open_files"
+	LimitProcesses    ProcessLimit = "processes"
+	LimitWallClock    ProcessLimit = "wall_clock"
+)
+
 // NewProcessError creates a new process error.
 func NewProcessError(
 	code ErrorCode,
@@ -38,6 +52,34 @@ func (e *ProcessError) Stderr() string {
 	return e.stderr
 }
 
+// Signal returns a description of the signal that terminated the process
+// (for example "killed"), or "" if it exited normally.
+func (e *ProcessError) Signal() string {
+	return e.signal
+}
+
+// WithSignal records the signal that terminated the process.
+func (e *ProcessError) WithSignal(signal string) *ProcessError {
+	e.signal = signal
+	_ = e.WithMetadata("signal", signal)
+
+	return e
+}
+
+// Limit returns the resource limit the process exceeded, or "" if it was not
+// stopped by one.
+func (e *ProcessError) Limit() ProcessLimit {
+	return e.limit
+}
+
+// WithLimit records the resource limit the process exceeded.
+func (e *ProcessError) WithLimit(limit ProcessLimit) *ProcessError {
+	e.limit = limit
+	_ = e.WithMetadata("limit", string(limit))
+
+	return e
+}
+
 // WithCommand adds command metadata to the error.
 func (e *ProcessError) WithCommand(command string) *ProcessError {
 	_ = e.WithMetadata("command", command)
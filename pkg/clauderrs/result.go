@@ -0,0 +1,47 @@
+package clauderrs
+
+import "strings"
+
+// ResultError represents a query that completed with an error result message
+// (for example error_max_turns or error_during_execution).
+type ResultError struct {
+	*BaseError
+	subtype string
+	errors  []string
+}
+
+// NewResultError creates a new result error. subtype is the result message
+// subtype and errors are the messages reported by the CLI.
+func NewResultError(code ErrorCode, subtype string, errors []string) *ResultError {
+	message := "query finished with " + subtype
+	if len(errors) > 0 {
+		message += ": " + strings.Join(errors, "; ")
+	}
+
+	err := &ResultError{
+		BaseError: NewBaseError(CategoryResult, code, message, nil),
+		subtype:   subtype,
+		errors:    errors,
+	}
+
+	_ = err.WithMetadata("subtype", subtype)
+
+	return err
+}
+
+// WithSessionID adds session ID metadata to the error.
+func (e *ResultError) WithSessionID(sessionID string) *ResultError {
+	_ = e.WithMetadata(MetadataKeySessionID, sessionID)
+
+	return e
+}
+
+// Subtype returns the result message subtype.
+func (e *ResultError) Subtype() string {
+	return e.subtype
+}
+
+// Errors returns the error messages reported in the result.
+func (e *ResultError) Errors() []string {
+	return e.errors
+}
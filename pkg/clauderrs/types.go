@@ -27,6 +27,8 @@ const (
 	CategoryPermission ErrorCategory = "permission"
 	// CategoryCallback represents callback-related errors.
 	CategoryCallback ErrorCategory = "callback"
+	// CategoryResult represents queries that finished with an error result.
+	CategoryResult ErrorCategory = "result"
 )
 
 // ErrorCode represents specific error codes within each category.
@@ -40,6 +42,9 @@ const (
 	ErrCodeMissingAPIKey    ErrorCode = "missing_api_key"
 	ErrCodeInvalidConfig    ErrorCode = "invalid_config"
 	ErrCodeVersionMismatch  ErrorCode = "version_mismatch"
+	// ErrCodeUnsupportedOption means the installed CLI lacks a flag that a
+	// set option needs.
+	ErrCodeUnsupportedOption ErrorCode = "unsupported_option"
 )
 
 // API error codes.
@@ -83,6 +88,9 @@ const (
 	ErrCodeProcessSpawnFailed ErrorCode = "process_spawn_failed"
 	ErrCodeProcessCrashed     ErrorCode = "process_crashed"
 	ErrCodeProcessExited      ErrorCode = "process_exited"
+	// ErrCodeProcessLimitExceeded means the process was stopped by one of
+	// its resource limits; ProcessError.Limit names which one.
+	ErrCodeProcessLimitExceeded ErrorCode = "process_limit_exceeded"
 )
 
 // Validation error codes.
@@ -95,9 +103,10 @@ const (
 
 // Permission error codes.
 const (
-	ErrCodeToolDenied      ErrorCode = "tool_denied"
-	ErrCodeDirectoryDenied ErrorCode = "directory_denied"
-	ErrCodeResourceDenied  ErrorCode = "resource_denied"
+	ErrCodeToolDenied       ErrorCode = "tool_denied"
+	ErrCodeDirectoryDenied  ErrorCode = "directory_denied"
+	ErrCodeResourceDenied   ErrorCode = "resource_denied"
+	ErrCodeUserSwitchFailed ErrorCode = "user_switch_failed"
 )
 
 // Callback error codes.
@@ -108,9 +117,18 @@ const (
 	ErrCodeHookTimeout     ErrorCode = "hook_timeout"
 )
 
+// Result error codes.
+const (
+	ErrCodeResultMaxTurns                   ErrorCode = "result_max_turns"
+	ErrCodeResultMaxBudget                  ErrorCode = "result_max_budget"
+	ErrCodeResultMaxStructuredOutputRetries ErrorCode = "result_max_structured_output_retries"
+	ErrCodeResultExecutionFailed            ErrorCode = "result_execution_failed"
+)
+
 // Metadata keys.
 const (
-	MetadataKeySessionID      = "session_id"
-	MetadataKeyCurrentVersion = "current_version"
-	MetadataKeyMinimumVersion = "minimum_version"
+	MetadataKeySessionID          = "session_id"
+	MetadataKeyCurrentVersion     = "current_version"
+	MetadataKeyMinimumVersion     = "minimum_version"
+	MetadataKeyUnsupportedOptions = "unsupported_options"
 )
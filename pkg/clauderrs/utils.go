@@ -215,6 +215,15 @@ func IsCallbackError(err error) bool {
 	return false
 }
 
+// IsResultError checks if the error is a result error.
+func IsResultError(err error) bool {
+	if sdkErr, ok := AsSDKError(err); ok {
+		return sdkErr.Category() == CategoryResult
+	}
+
+	return false
+}
+
 // Deprecated types for backward compatibility.
 
 // AbortError represents an aborted operation.
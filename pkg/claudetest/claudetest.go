@@ -0,0 +1,590 @@
+// Package claudetest provides a scripted, in-memory stand-in for the Claude
+// Code CLI, for testing code built on package claude without a real binary.
+//
+// A CLI runs a script of steps against the SDK: it waits for user messages,
+// emits assistant, tool-use and result messages, and sends can_use_tool,
+// hook_callback and mcp_message control requests whose responses the script
+// can check. Control requests sent by the SDK (initialize, interrupt,
+// set_model, ...) are answered with success and recorded.
+//
+//	cli := claudetest.New(t,
+//		claudetest.ExpectUserMessage("list files"),
+//		claudetest.CanUseTool("Bash", "tool_1", map[string]any{"command": "ls"}, nil),
+//		claudetest.Result("done"),
+//	)
+//	q, err := claude.QueryFunc("list files", cli.Options(&claude.Options{
+//		CanUseTool: myPermissionCallback,
+//	}))
+//
+// Step failures are reported through t when the test finishes, or can be
+// collected earlier with Wait.
+package claudetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	claude "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+)
+
+// SessionID is the session ID used in emitted messages.
+const SessionID = "claudetest-session"
+
+// ErrClosed is returned by Session methods once the SDK has closed the
+// transport.
+var ErrClosed = errors.New("claudetest: transport closed")
+
+// ErrInputClosed is returned while waiting for a user message after the SDK
+// has closed its input.
+var ErrInputClosed = errors.New("claudetest: input closed")
+
+// ErrExited is returned when sending after the script has ended the CLI's
+// output.
+var ErrExited = errors.New("claudetest: CLI exited")
+
+// CLI is a scripted fake CLI. It serves a single query; create one per test.
+type CLI struct {
+	tb    testing.TB
+	steps []Step
+	// done is closed when the script has finished.
+	done chan struct{}
+
+	mu       sync.Mutex
+	started  bool
+	session  *Session
+	config   *claude.TransportConfig
+	requests []ControlRequest
+	err      error
+}
+
+// New returns a CLI that runs steps in order once the SDK starts it. Any step
+// failure, or a script that never started, is reported through tb when the
+// test finishes.
+func New(tb testing.TB, steps ...Step) *CLI {
+	tb.Helper()
+
+	c := &CLI{
+		tb:    tb,
+		steps: steps,
+		done:  make(chan struct{}),
+	}
+	tb.Cleanup(c.cleanup)
+
+	return c
+}
+
+// Options sets opts.TransportFactory to the CLI and returns opts. A nil opts
+// is replaced with a new Options.
+func (c *CLI) Options(opts *claude.Options) *claude.Options {
+	if opts == nil {
+		opts = &claude.Options{}
+	}
+	opts.TransportFactory = c.Factory
+
+	return opts
+}
+
+// Factory is a claude.TransportFactory that starts the script. It fails if
+// called more than once.
+func (c *CLI) Factory(_ context.Context, config *claude.TransportConfig) (claude.Transport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		return nil, errors.New("claudetest: CLI already started")
+	}
+	c.started = true
+	c.config = config
+	c.session = newSession(c)
+
+	go c.run(c.session)
+
+	return c.session, nil
+}
+
+// run executes the script, then keeps the output open until the SDK closes
+// its input, as the real CLI does, unless the script ended it with Exit.
+func (c *CLI) run(s *Session) {
+	defer close(c.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for i, step := range c.steps {
+		if err := step.run(ctx, s); err != nil {
+			c.fail(fmt.Errorf("step %d (%s): %w", i+1, step.name, err))
+			s.exit()
+
+			return
+		}
+	}
+
+	s.waitInputClosed()
+	s.exit()
+}
+
+// Wait blocks until the script has finished or ctx is done, and returns the
+// first step failure.
+func (c *CLI) Wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err returns the first step failure so far.
+func (c *CLI) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+func (c *CLI) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *CLI) cleanup() {
+	c.mu.Lock()
+	started, s := c.started, c.session
+	c.mu.Unlock()
+
+	if !started {
+		if len(c.steps) > 0 {
+			c.tb.Errorf("claudetest: the CLI was never started")
+		}
+
+		return
+	}
+
+	s.close()
+	<-c.done
+
+	if err := c.Err(); err != nil {
+		c.tb.Errorf("claudetest: %v", err)
+	}
+}
+
+// Config returns the transport configuration the SDK started the CLI with, or
+// nil if it has not been started.
+func (c *CLI) Config() *claude.TransportConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.config
+}
+
+// Args returns the command-line arguments the SDK passed.
+func (c *CLI) Args() []string {
+	if config := c.Config(); config != nil {
+		return slices.Clone(config.Args)
+	}
+
+	return nil
+}
+
+// HasFlag reports whether flag (for example "--model") was passed, either
+// on its own or as "--flag=value".
+func (c *CLI) HasFlag(flag string) bool {
+	for _, arg := range c.Args() {
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FlagValue returns the value of flag, given as "--flag=value" or as the
+// following argument. ok is false if flag was not passed or has no value.
+func (c *CLI) FlagValue(flag string) (value string, ok bool) {
+	args := c.Args()
+	for i, arg := range args {
+		if value, found := strings.CutPrefix(arg, flag+"="); found {
+			return value, true
+		}
+		if arg == flag && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			return args[i+1], true
+		}
+	}
+
+	return "", false
+}
+
+// ControlRequests returns the control requests the SDK has sent, in order.
+func (c *CLI) ControlRequests() []ControlRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.requests)
+}
+
+func (c *CLI) recordRequest(req ControlRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, req)
+}
+
+// ControlRequest is a control request sent by the SDK.
+type ControlRequest struct {
+	RequestID string
+	Subtype   string
+	// Request is the decoded request object, including "subtype".
+	Request map[string]any
+}
+
+// ControlResponse is the SDK's answer to a control request sent by the
+// script.
+type ControlResponse struct {
+	RequestID string
+	// Subtype is "success" or "error".
+	Subtype string
+	// Response is the decoded response payload of a success.
+	Response map[string]any
+	// Error is the message of an error response.
+	Error string
+}
+
+// Err returns the error message of an error response as an error, or nil for
+// a success.
+func (r ControlResponse) Err() error {
+	if r.Subtype == "error" {
+		return errors.New(r.Error)
+	}
+
+	return nil
+}
+
+// Session is the running CLI as seen by a script step. It implements
+// claude.Transport for the SDK side.
+type Session struct {
+	cli *CLI
+	out chan []byte
+	// closed is closed when the SDK closes the transport.
+	closed    chan struct{}
+	closeOnce sync.Once
+	// exited is closed when the CLI's output has ended.
+	exited   chan struct{}
+	exitOnce sync.Once
+	// initialized is closed once the SDK's initialize request is answered.
+	initialized chan struct{}
+	initOnce    sync.Once
+
+	mu          sync.Mutex
+	users       []claude.SDKUserMessage
+	inputClosed bool
+	notify      chan struct{}
+	pending     map[string]chan ControlResponse
+	nextID      int
+}
+
+var _ claude.Transport = (*Session)(nil)
+
+func newSession(c *CLI) *Session {
+	return &Session{
+		cli:         c,
+		out:         make(chan []byte),
+		closed:      make(chan struct{}),
+		exited:      make(chan struct{}),
+		initialized: make(chan struct{}),
+		notify:      make(chan struct{}),
+		pending:     make(map[string]chan ControlResponse),
+	}
+}
+
+// Send writes msg to the SDK as one line. msg may be a string,
+// []byte or json.RawMessage holding JSON, or any value to marshal.
+func (s *Session) Send(ctx context.Context, msg any) error {
+	var data []byte
+	switch m := msg.(type) {
+	case string:
+		data = []byte(m)
+	case []byte:
+		data = m
+	case json.RawMessage:
+		data = m
+	default:
+		var err error
+		if data, err = json.Marshal(msg); err != nil {
+			return err
+		}
+	}
+	data = append(slices.Clip(data), '\n')
+
+	select {
+	case s.out <- data:
+		return nil
+	case <-s.exited:
+		return ErrExited
+	case <-s.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NextUserMessage waits for the next user message from the SDK.
+func (s *Session) NextUserMessage(ctx context.Context) (claude.SDKUserMessage, error) {
+	for {
+		s.mu.Lock()
+		if len(s.users) > 0 {
+			msg := s.users[0]
+			s.users = s.users[1:]
+			s.mu.Unlock()
+
+			return msg, nil
+		}
+		inputClosed, notify := s.inputClosed, s.notify
+		s.mu.Unlock()
+
+		if inputClosed {
+			return claude.SDKUserMessage{}, ErrInputClosed
+		}
+
+		select {
+		case <-notify:
+		case <-s.closed:
+			return claude.SDKUserMessage{}, ErrClosed
+		case <-ctx.Done():
+			return claude.SDKUserMessage{}, ctx.Err()
+		}
+	}
+}
+
+// Request sends a control request with the given subtype and fields and
+// waits for the SDK's response.
+func (s *Session) Request(ctx context.Context, subtype string, fields map[string]any) (ControlResponse, error) {
+	request := map[string]any{"subtype": subtype}
+	for k, v := range fields {
+		request[k] = v
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	requestID := fmt.Sprintf("claudetest_req_%d", s.nextID)
+	respChan := make(chan ControlResponse, 1)
+	s.pending[requestID] = respChan
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, requestID)
+		s.mu.Unlock()
+	}()
+
+	err := s.Send(ctx, map[string]any{
+		"type":       "control_request",
+		"request_id": requestID,
+		"request":    request,
+	})
+	if err != nil {
+		return ControlResponse{}, err
+	}
+
+	select {
+	case resp := <-respChan:
+		return resp, nil
+	case <-s.closed:
+		return ControlResponse{}, ErrClosed
+	case <-ctx.Done():
+		return ControlResponse{}, ctx.Err()
+	}
+}
+
+// Read implements claude.Transport.
+func (s *Session) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-s.out:
+		return data, nil
+	case <-s.exited:
+		return nil, io.EOF
+	case <-s.closed:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Write implements claude.Transport.
+func (s *Session) Write(_ context.Context, data []byte) error {
+	s.mu.Lock()
+	inputClosed := s.inputClosed
+	s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+		return ErrClosed
+	default:
+	}
+	if inputClosed {
+		return ErrInputClosed
+	}
+
+	var envelope struct {
+		Type      string                     `json:"type"`
+		RequestID string                     `json:"request_id"`
+		Request   map[string]any             `json:"request"`
+		Response  map[string]json.RawMessage `json:"response"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("claudetest: invalid message from SDK: %w", err)
+	}
+
+	switch envelope.Type {
+	case "user":
+		var msg claude.SDKUserMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("claudetest: invalid user message: %w", err)
+		}
+		s.mu.Lock()
+		s.users = append(s.users, msg)
+		s.signal()
+		s.mu.Unlock()
+	case "control_request":
+		subtype, _ := envelope.Request["subtype"].(string)
+		s.cli.recordRequest(ControlRequest{
+			RequestID: envelope.RequestID,
+			Subtype:   subtype,
+			Request:   envelope.Request,
+		})
+		go func() {
+			if s.acknowledge(envelope.RequestID) == nil && subtype == "initialize" {
+				s.initOnce.Do(func() { close(s.initialized) })
+			}
+		}()
+	case "control_response":
+		s.deliverResponse(envelope.Response)
+	}
+
+	return nil
+}
+
+// acknowledge answers an SDK control request with an empty success.
+func (s *Session) acknowledge(requestID string) error {
+	return s.Send(context.Background(), map[string]any{
+		"type": "control_response",
+		"response": map[string]any{
+			"subtype":    "success",
+			"request_id": requestID,
+			"response":   map[string]any{},
+		},
+	})
+}
+
+func (s *Session) deliverResponse(raw map[string]json.RawMessage) {
+	var resp ControlResponse
+	_ = json.Unmarshal(raw["request_id"], &resp.RequestID)
+	_ = json.Unmarshal(raw["subtype"], &resp.Subtype)
+	_ = json.Unmarshal(raw["response"], &resp.Response)
+	_ = json.Unmarshal(raw["error"], &resp.Error)
+
+	s.mu.Lock()
+	respChan, ok := s.pending[resp.RequestID]
+	s.mu.Unlock()
+
+	if !ok {
+		s.cli.fail(fmt.Errorf("control response for unknown request %q", resp.RequestID))
+
+		return
+	}
+
+	select {
+	case respChan <- resp:
+	default:
+		s.cli.fail(fmt.Errorf("duplicate control response for request %q", resp.RequestID))
+	}
+}
+
+// CloseInput implements claude.Transport.
+func (s *Session) CloseInput() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inputClosed = true
+	s.signal()
+
+	return nil
+}
+
+// Close implements claude.Transport.
+func (s *Session) Close() error {
+	s.close()
+
+	return nil
+}
+
+func (s *Session) close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+}
+
+// exit ends the CLI's output; the SDK then reads io.EOF.
+func (s *Session) exit() {
+	s.exitOnce.Do(func() {
+		close(s.exited)
+	})
+}
+
+// waitInitialized blocks until the SDK's initialize request has been
+// answered.
+func (s *Session) waitInitialized(ctx context.Context) error {
+	select {
+	case <-s.initialized:
+		return nil
+	case <-s.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitInputClosed blocks until the SDK closes its input or the transport, or
+// the output has already ended.
+func (s *Session) waitInputClosed() {
+	for {
+		s.mu.Lock()
+		inputClosed, notify := s.inputClosed, s.notify
+		s.mu.Unlock()
+
+		if inputClosed {
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-s.closed:
+			return
+		case <-s.exited:
+			return
+		}
+	}
+}
+
+// signal wakes waiters. Callers hold s.mu.
+func (s *Session) signal() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
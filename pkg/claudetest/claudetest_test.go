@@ -0,0 +1,261 @@
+package claudetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	claude "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+)
+
+// runToResult reads messages until the result and returns everything read.
+func runToResult(t *testing.T, q claude.Query) []claude.SDKMessage {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var msgs []claude.SDKMessage
+	for {
+		msg, err := q.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		msgs = append(msgs, msg)
+		if _, ok := msg.(*claude.SDKResultMessage); ok {
+			return msgs
+		}
+	}
+}
+
+func TestCLI_PermissionCallback(t *testing.T) {
+	var gotTool string
+	cli := New(t,
+		ExpectUserMessage("list files"),
+		SystemInit("claude-test"),
+		ToolUse("tool_1", "Bash", map[string]any{"command": "ls"}),
+		CanUseTool("Bash", "tool_1", map[string]any{"command": "ls"}, func(resp ControlResponse) error {
+			if err := resp.Err(); err != nil {
+				return err
+			}
+			if resp.Response["allow"] != true {
+				return fmt.Errorf("expected the tool to be allowed, got %v", resp.Response)
+			}
+
+			return nil
+		}),
+		CanUseTool("Bash", "tool_2", map[string]any{"command": "rm -rf /"}, func(resp ControlResponse) error {
+			if resp.Response["allow"] != false || resp.Response["reason"] != "too dangerous" {
+				return fmt.Errorf("expected a denial, got %v", resp.Response)
+			}
+
+			return nil
+		}),
+		Assistant("Here are the files."),
+		Result("done"),
+	)
+
+	q, err := claude.QueryFunc("list files", cli.Options(&claude.Options{
+		Model: "claude-test",
+		CanUseTool: func(
+			_ context.Context,
+			toolName string,
+			input map[string]claude.JSONValue,
+			_ []claude.PermissionUpdate,
+			_ string,
+			_, _, _ *string,
+		) (claude.PermissionResult, error) {
+			gotTool = toolName
+			if strings.Contains(string(input["command"]), "rm") {
+				return claude.PermissionDeny{Behavior: claude.PermissionBehaviorDeny, Message: "too dangerous"}, nil
+			}
+
+			return claude.PermissionAllow{Behavior: claude.PermissionBehaviorAllow}, nil
+		},
+	}))
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	msgs := runToResult(t, q)
+	if len(msgs) != 4 {
+		t.Errorf("expected init, tool use, text and result, got %d messages", len(msgs))
+	}
+	if gotTool != "Bash" {
+		t.Errorf("expected CanUseTool to be called for Bash, got %q", gotTool)
+	}
+
+	if model, ok := cli.FlagValue("--model"); !ok || model != "claude-test" {
+		t.Errorf("expected --model claude-test, got %q (%v)", model, ok)
+	}
+	if !cli.HasFlag("--input-format") {
+		t.Errorf("expected --input-format in %v", cli.Args())
+	}
+
+	// The script keeps running until the SDK closes the transport.
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Wait(ctx); err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+}
+
+func TestCLI_HookCallback(t *testing.T) {
+	called := make(chan claude.HookInput, 1)
+	cli := New(t,
+		HookCallback("hook_0", "tool_1", map[string]any{
+			"hook_event_name": "PreToolUse",
+			"session_id":      SessionID,
+			"transcript_path": "/tmp/transcript",
+			"cwd":             "/work",
+			"tool_name":       "Bash",
+			"tool_input":      map[string]any{"command": "ls"},
+			"tool_use_id":     "tool_1",
+		}, func(resp ControlResponse) error {
+			if err := resp.Err(); err != nil {
+				return err
+			}
+			if resp.Response["continue"] != true {
+				return fmt.Errorf("expected continue, got %v", resp.Response)
+			}
+
+			return nil
+		}),
+		Result("done"),
+		Exit(),
+	)
+
+	cont := true
+	q, err := claude.QueryFunc("go", cli.Options(&claude.Options{
+		Hooks: map[claude.HookEvent][]claude.HookCallbackMatcher{
+			claude.HookEventPreToolUse: {{
+				Hooks: []claude.HookCallback{
+					func(_ context.Context, input claude.HookInput, _ *string) (claude.HookJSONOutput, error) {
+						called <- input
+
+						return claude.SyncHookOutput{Continue: &cont}, nil
+					},
+				},
+			}},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	runToResult(t, q)
+
+	select {
+	case input := <-called:
+		if pre, ok := input.(claude.PreToolUseHookInput); !ok || pre.ToolName != "Bash" {
+			t.Errorf("expected a PreToolUse input for Bash, got %+v", input)
+		}
+	default:
+		t.Fatal("expected the hook to be called")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := q.Next(ctx); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF after Exit, got %v", err)
+	}
+	if err := cli.Wait(ctx); err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+
+	requests := cli.ControlRequests()
+	if len(requests) == 0 || requests[0].Subtype != "initialize" {
+		t.Fatalf("expected an initialize request, got %+v", requests)
+	}
+	if _, ok := requests[0].Request["hooks"].(map[string]any)["PreToolUse"]; !ok {
+		t.Errorf("expected PreToolUse hooks to be registered, got %v", requests[0].Request)
+	}
+}
+
+func TestCLI_McpMessage(t *testing.T) {
+	cli := New(t,
+		McpMessage("calc", map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}, func(resp ControlResponse) error {
+			if resp.Err() == nil {
+				return errors.New("expected an error response")
+			}
+
+			return nil
+		}),
+		Exit(),
+	)
+
+	q, err := claude.QueryFunc("", cli.Options(nil))
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Wait(ctx); err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+}
+
+// recordingTB captures errors and cleanups instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Cleanup(fn func()) { r.cleanups = append(r.cleanups, fn) }
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestCLI_ReportsFailedStep(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	cli := New(tb, ExpectUserMessage("hello"))
+
+	q, err := claude.QueryFunc("goodbye", cli.Options(nil))
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+	defer q.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Wait(ctx); err == nil || !strings.Contains(err.Error(), `step 1 (expect user message "hello")`) {
+		t.Fatalf("expected the first step to fail, got %v", err)
+	}
+	if _, err := q.Next(ctx); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF after a failed step, got %v", err)
+	}
+
+	for _, fn := range tb.cleanups {
+		fn()
+	}
+	if len(tb.errors) != 1 {
+		t.Errorf("expected the failure to be reported once, got %q", tb.errors)
+	}
+}
+
+func TestCLI_ReportsNeverStarted(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	New(tb, Result("done"))
+
+	for _, fn := range tb.cleanups {
+		fn()
+	}
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "never started") {
+		t.Errorf("expected a never-started error, got %q", tb.errors)
+	}
+}
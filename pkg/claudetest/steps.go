@@ -0,0 +1,193 @@
+package claudetest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	claude "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+)
+
+// Step is one action in a CLI script.
+type Step struct {
+	name string
+	run  func(ctx context.Context, s *Session) error
+}
+
+// Do returns a custom step. name identifies it in failure messages.
+func Do(name string, fn func(ctx context.Context, s *Session) error) Step {
+	return Step{name: name, run: fn}
+}
+
+// Emit sends msg to the SDK; see Session.Send.
+func Emit(msg any) Step {
+	return Do("emit", func(ctx context.Context, s *Session) error {
+		return s.Send(ctx, msg)
+	})
+}
+
+// SystemInit emits the system init message that starts a session.
+func SystemInit(model string) Step {
+	return Do("system init", func(ctx context.Context, s *Session) error {
+		return s.Send(ctx, map[string]any{
+			"type":           "system",
+			"subtype":        "init",
+			"session_id":     SessionID,
+			"model":          model,
+			"cwd":            "",
+			"tools":          []string{},
+			"permissionMode": "default",
+		})
+	})
+}
+
+// Assistant emits an assistant message with a single text block.
+func Assistant(text string) Step {
+	return Do("assistant", func(ctx context.Context, s *Session) error {
+		return s.Send(ctx, assistantMessage(map[string]any{"type": "text", "text": text}))
+	})
+}
+
+// ToolUse emits an assistant message with a single tool_use block.
+func ToolUse(toolUseID, toolName string, input map[string]any) Step {
+	return Do("tool use "+toolName, func(ctx context.Context, s *Session) error {
+		return s.Send(ctx, assistantMessage(map[string]any{
+			"type":  "tool_use",
+			"id":    toolUseID,
+			"name":  toolName,
+			"input": input,
+		}))
+	})
+}
+
+// Result emits a successful result message.
+func Result(text string) Step {
+	return Do("result", func(ctx context.Context, s *Session) error {
+		return s.Send(ctx, map[string]any{
+			"type":            "result",
+			"subtype":         "success",
+			"session_id":      SessionID,
+			"is_error":        false,
+			"num_turns":       1,
+			"result":          text,
+			"duration_ms":     0,
+			"duration_api_ms": 0,
+			"total_cost_usd":  0,
+			"usage":           map[string]any{"input_tokens": 0, "output_tokens": 0},
+		})
+	})
+}
+
+// ExpectUserMessage waits for the next user message and checks that its text
+// contains substr. An empty substr accepts any message.
+func ExpectUserMessage(substr string) Step {
+	return Do(fmt.Sprintf("expect user message %q", substr), func(ctx context.Context, s *Session) error {
+		msg, err := s.NextUserMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if text := MessageText(msg); !strings.Contains(text, substr) {
+			return fmt.Errorf("user message %q does not contain %q", text, substr)
+		}
+
+		return nil
+	})
+}
+
+// Exit ends the CLI's output, as if the process exited. Next then returns
+// io.EOF.
+func Exit() Step {
+	return Do("exit", func(_ context.Context, s *Session) error {
+		s.exit()
+
+		return nil
+	})
+}
+
+// CanUseTool sends a can_use_tool control request and passes the response to
+// check. A nil check accepts any response.
+func CanUseTool(toolName, toolUseID string, input map[string]any, check func(ControlResponse) error) Step {
+	return request("can_use_tool "+toolName, "can_use_tool", map[string]any{
+		"tool_name":   toolName,
+		"tool_use_id": toolUseID,
+		"input":       input,
+	}, check)
+}
+
+// HookCallback sends a hook_callback control request for callbackID and
+// passes the response to check. Callback IDs are assigned by the SDK in
+// registration order ("hook_0", "hook_1", ...) and appear in the initialize
+// request; input must carry hook_event_name. Like the real CLI, the step
+// waits for that initialize request before invoking the callback.
+func HookCallback(callbackID, toolUseID string, input map[string]any, check func(ControlResponse) error) Step {
+	fields := map[string]any{
+		"callback_id": callbackID,
+		"input":       input,
+	}
+	if toolUseID != "" {
+		fields["tool_use_id"] = toolUseID
+	}
+
+	return Do("hook_callback "+callbackID, func(ctx context.Context, s *Session) error {
+		if err := s.waitInitialized(ctx); err != nil {
+			return err
+		}
+
+		return sendRequest(ctx, s, "hook_callback", fields, check)
+	})
+}
+
+// McpMessage sends an mcp_message control request carrying a JSON-RPC message
+// for serverName and passes the response to check.
+func McpMessage(serverName string, message any, check func(ControlResponse) error) Step {
+	return request("mcp_message "+serverName, "mcp_message", map[string]any{
+		"server_name": serverName,
+		"message":     message,
+	}, check)
+}
+
+func request(name, subtype string, fields map[string]any, check func(ControlResponse) error) Step {
+	return Do(name, func(ctx context.Context, s *Session) error {
+		return sendRequest(ctx, s, subtype, fields, check)
+	})
+}
+
+func sendRequest(ctx context.Context, s *Session, subtype string, fields map[string]any, check func(ControlResponse) error) error {
+	resp, err := s.Request(ctx, subtype, fields)
+	if err != nil {
+		return err
+	}
+	if check != nil {
+		return check(resp)
+	}
+
+	return nil
+}
+
+// MessageText returns the concatenated text blocks of a user message.
+func MessageText(msg claude.SDKUserMessage) string {
+	var b strings.Builder
+	for _, block := range msg.Message.Content {
+		if text, ok := block.(claude.TextContentBlock); ok {
+			b.WriteString(text.Text)
+		}
+	}
+
+	return b.String()
+}
+
+func assistantMessage(block map[string]any) map[string]any {
+	return map[string]any{
+		"type":               "assistant",
+		"session_id":         SessionID,
+		"parent_tool_use_id": nil,
+		"message": map[string]any{
+			"type":        "message",
+			"role":        "assistant",
+			"model":       "claudetest",
+			"content":     []any{block},
+			"stop_reason": nil,
+			"usage":       map[string]any{"input_tokens": 0, "output_tokens": 0},
+		},
+	}
+}
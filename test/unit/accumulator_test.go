@@ -0,0 +1,194 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+
+	claudeagent "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+)
+
+func streamEvent(t *testing.T, parent string, event string) *claudeagent.SDKStreamEvent {
+	t.Helper()
+
+	parentJSON := "null"
+	if parent != "" {
+		parentJSON = `"` + parent + `"`
+	}
+	data := `{"type":"stream_event","session_id":"s1","uuid":"6f0f8c1e-7b9a-4a53-9a4c-2f6f0b1d8e11",` +
+		`"parent_tool_use_id":` + parentJSON + `,"event":` + event + `}`
+
+	var evt claudeagent.SDKStreamEvent
+	if err := json.Unmarshal([]byte(data), &evt); err != nil {
+		t.Fatalf("failed to decode stream event %s: %v", event, err)
+	}
+
+	return &evt
+}
+
+func TestContentDeltaDecoding(t *testing.T) {
+	tests := []struct {
+		event string
+		check func(d claudeagent.ContentDelta) bool
+	}{
+		{`{"type":"text_delta","text":"hi"}`, func(d claudeagent.ContentDelta) bool {
+			return d.TextDelta != nil && *d.TextDelta == "hi"
+		}},
+		{`{"type":"input_json_delta","partial_json":""}`, func(d claudeagent.ContentDelta) bool {
+			return d.TextDelta == nil && d.PartialJSON != nil && *d.PartialJSON == ""
+		}},
+		{`{"type":"thinking_delta","thinking":"let me"}`, func(d claudeagent.ContentDelta) bool {
+			return d.Thinking != nil && *d.Thinking == "let me"
+		}},
+		{`{"type":"signature_delta","signature":"sig"}`, func(d claudeagent.ContentDelta) bool {
+			return d.Signature != nil && *d.Signature == "sig"
+		}},
+		{`{"type":"future_delta","x":1}`, func(d claudeagent.ContentDelta) bool {
+			return d.Type == "future_delta"
+		}},
+	}
+
+	for _, tt := range tests {
+		evt := streamEvent(t, "", `{"type":"content_block_delta","index":0,"delta":`+tt.event+`}`)
+		delta, ok := evt.Event.(claudeagent.ContentBlockDeltaEvent)
+		if !ok {
+			t.Fatalf("expected ContentBlockDeltaEvent, got %T", evt.Event)
+		}
+		if !tt.check(delta.Delta) {
+			t.Errorf("unexpected delta for %s: %+v", tt.event, delta.Delta)
+		}
+	}
+}
+
+func TestMessageAccumulatorAssemblesStream(t *testing.T) {
+	var events []claudeagent.AccumulatorEvent
+	var midToolSnapshot claudeagent.APIAssistantMessage
+
+	var acc *claudeagent.MessageAccumulator
+	acc = claudeagent.NewMessageAccumulator(func(evt claudeagent.AccumulatorEvent) {
+		events = append(events, evt)
+		if evt.Type == claudeagent.AccumulatorToolInputDelta && evt.Delta == `, "limit": 1` {
+			midToolSnapshot, _ = acc.Snapshot(evt.ParentToolUseID)
+		}
+	})
+
+	lines := []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Reading "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"the file."}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig-1"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Let me "}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"check."}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"Read","input":{}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"file_path\": \"/tmp/a"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":".go\""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":", \"limit\": 1"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"0}"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":42}}`,
+		`{"type":"message_stop"}`,
+	}
+	for _, line := range lines {
+		acc.Observe(streamEvent(t, "", line))
+	}
+
+	if !acc.Done(nil) {
+		t.Fatal("expected message to be complete")
+	}
+
+	msg, ok := acc.Snapshot(nil)
+	if !ok {
+		t.Fatal("expected snapshot")
+	}
+	if msg.ID != "msg_1" || msg.StopReason == nil || *msg.StopReason != "tool_use" {
+		t.Errorf("unexpected message metadata: %+v", msg)
+	}
+	if msg.Usage.InputTokens != 10 || msg.Usage.OutputTokens != 42 {
+		t.Errorf("unexpected usage: %+v", msg.Usage)
+	}
+	if len(msg.Content) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(msg.Content))
+	}
+
+	thinking, ok := msg.Content[0].(claudeagent.ThinkingBlock)
+	if !ok || thinking.Thinking != "Reading the file." || thinking.Signature != "sig-1" {
+		t.Errorf("unexpected thinking block: %#v", msg.Content[0])
+	}
+	text, ok := msg.Content[1].(claudeagent.TextContentBlock)
+	if !ok || text.Text != "Let me check." {
+		t.Errorf("unexpected text block: %#v", msg.Content[1])
+	}
+	tool, ok := msg.Content[2].(claudeagent.ToolUseContentBlock)
+	if !ok {
+		t.Fatalf("expected tool use block, got %T", msg.Content[2])
+	}
+	var input struct {
+		FilePath string `json:"file_path"`
+		Limit    int    `json:"limit"`
+	}
+	if err := json.Unmarshal(tool.Input, &input); err != nil || input.FilePath != "/tmp/a.go" || input.Limit != 10 {
+		t.Errorf("unexpected tool input %s (%v)", tool.Input, err)
+	}
+
+	// Mid-stream snapshots repair the partial tool input into valid JSON.
+	if len(midToolSnapshot.Content) != 3 {
+		t.Fatalf("expected mid-stream snapshot with 3 blocks, got %d", len(midToolSnapshot.Content))
+	}
+	partial := midToolSnapshot.Content[2].(claudeagent.ToolUseContentBlock)
+	if !json.Valid(partial.Input) {
+		t.Errorf("mid-stream input is not valid JSON: %s", partial.Input)
+	}
+
+	counts := map[claudeagent.AccumulatorEventType]int{}
+	for _, evt := range events {
+		counts[evt.Type]++
+	}
+	want := map[claudeagent.AccumulatorEventType]int{
+		claudeagent.AccumulatorMessageStarted:    1,
+		claudeagent.AccumulatorThinkingAppended:  2,
+		claudeagent.AccumulatorTextAppended:      2,
+		claudeagent.AccumulatorToolCallStarted:   1,
+		claudeagent.AccumulatorToolInputDelta:    5,
+		claudeagent.AccumulatorToolInputComplete: 1,
+		claudeagent.AccumulatorMessageCompleted:  1,
+	}
+	for typ, n := range want {
+		if counts[typ] != n {
+			t.Errorf("expected %d %s events, got %d", n, typ, counts[typ])
+		}
+	}
+
+	for _, evt := range events {
+		if evt.Type == claudeagent.AccumulatorToolInputComplete {
+			if evt.ToolUseID != "toolu_1" || evt.ToolName != "Read" || evt.Err != nil {
+				t.Errorf("unexpected tool input complete event: %+v", evt)
+			}
+		}
+	}
+}
+
+func TestMessageAccumulatorSeparatesSubagentStreams(t *testing.T) {
+	acc := claudeagent.NewMessageAccumulator(nil)
+
+	acc.Observe(streamEvent(t, "", `{"type":"message_start","message":{"id":"main","content":[]}}`))
+	acc.Observe(streamEvent(t, "toolu_task", `{"type":"message_start","message":{"id":"sub","content":[]}}`))
+	acc.Observe(streamEvent(t, "", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`))
+	acc.Observe(streamEvent(t, "toolu_task", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`))
+	acc.Observe(streamEvent(t, "toolu_task", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"sub text"}}`))
+	acc.Observe(streamEvent(t, "", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"main text"}}`))
+
+	main, _ := acc.Snapshot(nil)
+	parent := "toolu_task"
+	sub, _ := acc.Snapshot(&parent)
+
+	if got := main.Content[0].(claudeagent.TextContentBlock).Text; got != "main text" {
+		t.Errorf("unexpected main text %q", got)
+	}
+	if got := sub.Content[0].(claudeagent.TextContentBlock).Text; got != "sub text" {
+		t.Errorf("unexpected subagent text %q", got)
+	}
+}
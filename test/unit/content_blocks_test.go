@@ -0,0 +1,193 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+
+	claudeagent "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+)
+
+func decodeAssistantContent(t *testing.T, content string) []claudeagent.ContentBlock {
+	t.Helper()
+
+	data := []byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":` + content + `}`)
+
+	var msg claudeagent.APIAssistantMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("failed to decode assistant message: %v", err)
+	}
+
+	return msg.Content
+}
+
+func TestThinkingBlocksWithSignatureAndRedaction(t *testing.T) {
+	blocks := decodeAssistantContent(t, `[
+		{"type":"thinking","thinking":"hmm","signature":"sig-abc"},
+		{"type":"redacted_thinking","data":"ZW5jcnlwdGVk"}
+	]`)
+
+	thinking, ok := blocks[0].(claudeagent.ThinkingBlock)
+	if !ok || thinking.Signature != "sig-abc" {
+		t.Fatalf("expected thinking block with signature, got %#v", blocks[0])
+	}
+
+	redacted, ok := blocks[1].(claudeagent.RedactedThinkingBlock)
+	if !ok || redacted.Data != "ZW5jcnlwdGVk" {
+		t.Fatalf("expected redacted thinking block, got %#v", blocks[1])
+	}
+}
+
+func TestWebSearchBlocksAndCitations(t *testing.T) {
+	blocks := decodeAssistantContent(t, `[
+		{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{"query":"go iterators"}},
+		{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[
+			{"type":"web_search_result","url":"https://go.dev/blog/range-functions","title":"Range Over Function Types","encrypted_content":"enc","page_age":"2024"}
+		]},
+		{"type":"text","text":"Go 1.23 added range-over-func.","citations":[
+			{"type":"web_search_result_location","url":"https://go.dev/blog/range-functions","title":"Range Over Function Types","encrypted_index":"idx","cited_text":"range over function"}
+		]},
+		{"type":"web_search_tool_result","tool_use_id":"srvtoolu_2","content":{"type":"web_search_tool_result_error","error_code":"max_uses_exceeded"}}
+	]`)
+
+	if len(blocks) != 4 {
+		t.Fatalf("expected 4 blocks, got %d", len(blocks))
+	}
+
+	use, ok := blocks[0].(claudeagent.ServerToolUseBlock)
+	if !ok || use.Name != "web_search" {
+		t.Fatalf("expected server tool use block, got %#v", blocks[0])
+	}
+
+	result, ok := blocks[1].(claudeagent.WebSearchToolResultBlock)
+	if !ok || len(result.Content.Results) != 1 || result.Content.Error != nil {
+		t.Fatalf("expected web search results, got %#v", blocks[1])
+	}
+	if result.Content.Results[0].URL != "https://go.dev/blog/range-functions" {
+		t.Errorf("unexpected result URL %q", result.Content.Results[0].URL)
+	}
+
+	text, ok := blocks[2].(claudeagent.TextContentBlock)
+	if !ok || len(text.Citations) != 1 {
+		t.Fatalf("expected text block with citation, got %#v", blocks[2])
+	}
+	if text.Citations[0].Type != claudeagent.CitationTypeWebSearchResultLocation ||
+		text.Citations[0].CitedText != "range over function" {
+		t.Errorf("unexpected citation %#v", text.Citations[0])
+	}
+
+	failed, ok := blocks[3].(claudeagent.WebSearchToolResultBlock)
+	if !ok || failed.Content.Error == nil || failed.Content.Error.ErrorCode != "max_uses_exceeded" {
+		t.Fatalf("expected web search error, got %#v", blocks[3])
+	}
+
+	// The union must round-trip.
+	data, err := json.Marshal(failed)
+	if err != nil {
+		t.Fatalf("failed to marshal web search error: %v", err)
+	}
+	var decoded claudeagent.WebSearchToolResultBlock
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Content.Error == nil {
+		t.Fatalf("round trip failed: %s (%v)", data, err)
+	}
+}
+
+func TestWebSearchUnexpectedContentFallsBackToRaw(t *testing.T) {
+	blocks := decodeAssistantContent(t, `[
+		{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"error","error":{"type":"overloaded_error","message":"busy"}}},
+		{"type":"web_search_tool_result","tool_use_id":"srvtoolu_2","content":"unavailable"},
+		{"type":"text","text":"still decoded"}
+	]`)
+
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(blocks))
+	}
+	for i, block := range blocks[:2] {
+		raw, ok := block.(claudeagent.RawContentBlock)
+		if !ok || raw.Type != "web_search_tool_result" {
+			t.Fatalf("block %d: expected raw web search block, got %#v", i, block)
+		}
+	}
+	if _, ok := blocks[2].(claudeagent.TextContentBlock); !ok {
+		t.Fatalf("expected text block after the raw blocks, got %#v", blocks[2])
+	}
+}
+
+func TestNewBlockTypesWithUnexpectedShapeFallBackToRaw(t *testing.T) {
+	blocks := decodeAssistantContent(t, `[
+		{"type":"redacted_thinking","data":5},
+		{"type":"server_tool_use","id":7,"name":"web_search","input":{}},
+		{"type":"document","source":"inline"},
+		{"type":"text","text":"still decoded"}
+	]`)
+
+	if len(blocks) != 4 {
+		t.Fatalf("expected 4 blocks, got %d", len(blocks))
+	}
+	for i, want := range []string{"redacted_thinking", "server_tool_use", "document"} {
+		raw, ok := blocks[i].(claudeagent.RawContentBlock)
+		if !ok || raw.Type != want {
+			t.Fatalf("block %d: expected raw %s block, got %#v", i, want, blocks[i])
+		}
+	}
+	if _, ok := blocks[3].(claudeagent.TextContentBlock); !ok {
+		t.Fatalf("expected text block after the raw blocks, got %#v", blocks[3])
+	}
+}
+
+func TestDocumentContentBlockSources(t *testing.T) {
+	blocks := decodeAssistantContent(t, `[
+		{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":"JVBERi0="},"title":"Spec","citations":{"enabled":true}},
+		{"type":"document","source":{"type":"text","media_type":"text/plain","data":"plain text"}},
+		{"type":"document","source":{"type":"url","url":"https://example.com/spec.pdf"}},
+		{"type":"document","source":{"type":"content","content":[{"type":"text","text":"chunk one"},{"type":"text","text":"chunk two"}]}},
+		{"type":"document","source":{"type":"content","content":"single string"}}
+	]`)
+
+	docs := make([]claudeagent.DocumentContentBlock, 0, len(blocks))
+	for i, block := range blocks {
+		doc, ok := block.(claudeagent.DocumentContentBlock)
+		if !ok {
+			t.Fatalf("block %d: expected document block, got %T", i, block)
+		}
+		docs = append(docs, doc)
+	}
+
+	if docs[0].Source.MediaType != "application/pdf" || docs[0].Title != "Spec" ||
+		docs[0].Citations == nil || !docs[0].Citations.Enabled {
+		t.Errorf("unexpected base64 document: %#v", docs[0])
+	}
+	if docs[1].Source.Type != claudeagent.DocumentSourceTypeText || docs[1].Source.Data != "plain text" {
+		t.Errorf("unexpected text document: %#v", docs[1])
+	}
+	if docs[2].Source.URL != "https://example.com/spec.pdf" {
+		t.Errorf("unexpected url document: %#v", docs[2])
+	}
+	if len(docs[3].Source.Content) != 2 {
+		t.Errorf("expected 2 content blocks, got %d", len(docs[3].Source.Content))
+	}
+	if len(docs[4].Source.Content) != 1 {
+		t.Errorf("expected string content decoded as one block, got %d", len(docs[4].Source.Content))
+	}
+}
+
+func TestUnknownContentBlockPassthrough(t *testing.T) {
+	raw := `{"type":"future_block","payload":{"x":1}}`
+	blocks := decodeAssistantContent(t, `[`+raw+`,{"type":"text","text":"still decoded"}]`)
+
+	passthrough, ok := blocks[0].(claudeagent.RawContentBlock)
+	if !ok || passthrough.Type != "future_block" {
+		t.Fatalf("expected raw passthrough block, got %#v", blocks[0])
+	}
+
+	data, err := json.Marshal(passthrough)
+	if err != nil {
+		t.Fatalf("failed to marshal raw block: %v", err)
+	}
+	if string(data) != raw {
+		t.Errorf("expected raw JSON preserved, got %s", data)
+	}
+
+	if _, ok := blocks[1].(claudeagent.TextContentBlock); !ok {
+		t.Errorf("expected text block after unknown block, got %T", blocks[1])
+	}
+}
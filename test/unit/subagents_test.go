@@ -0,0 +1,201 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	claudeagent "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+)
+
+func taskCall(id, subagentType string) claudeagent.ToolUseContentBlock {
+	input, _ := json.Marshal(map[string]string{
+		"description":   "do work",
+		"prompt":        "please do work",
+		"subagent_type": subagentType,
+	})
+
+	return claudeagent.ToolUseContentBlock{Type: "tool_use", ID: id, Name: "Task", Input: input}
+}
+
+func assistantMsg(
+	id string,
+	parent *string,
+	usage claudeagent.Usage,
+	blocks ...claudeagent.ContentBlock,
+) *claudeagent.SDKAssistantMessage {
+	return &claudeagent.SDKAssistantMessage{
+		Message: claudeagent.APIAssistantMessage{
+			ID:      id,
+			Role:    "assistant",
+			Content: blocks,
+			Usage:   usage,
+		},
+		ParentToolUseID: parent,
+	}
+}
+
+func toolResultMsg(parent *string, toolUseID string) *claudeagent.SDKUserMessage {
+	return &claudeagent.SDKUserMessage{
+		Message: claudeagent.APIUserMessage{
+			Role: "user",
+			Content: []claudeagent.ContentBlock{
+				claudeagent.ToolResultContentBlock{Type: "tool_result", ToolUseID: toolUseID},
+			},
+		},
+		ParentToolUseID: parent,
+	}
+}
+
+func runHook(
+	t *testing.T,
+	hooks map[claudeagent.HookEvent][]claudeagent.HookCallbackMatcher,
+	event claudeagent.HookEvent,
+	input claudeagent.HookInput,
+) {
+	t.Helper()
+	for _, matcher := range hooks[event] {
+		for _, hook := range matcher.Hooks {
+			if _, err := hook(context.Background(), input, nil); err != nil {
+				t.Fatalf("hook %s returned error: %v", event, err)
+			}
+		}
+	}
+}
+
+func TestSubagentTrackerBuildsTree(t *testing.T) {
+	var events []claudeagent.SubagentEvent
+	tracker := claudeagent.NewSubagentTracker(func(evt claudeagent.SubagentEvent) {
+		events = append(events, evt)
+	})
+	hooks := tracker.Hooks()
+
+	parentID := "toolu_parent"
+	childID := "toolu_child"
+
+	tracker.Observe(assistantMsg("msg_main", nil,
+		claudeagent.Usage{InputTokens: 50, OutputTokens: 50},
+		taskCall(parentID, "researcher")))
+
+	runHook(t, hooks, claudeagent.HookEventSubagentStart, claudeagent.SubagentStartHookInput{
+		AgentID:   "agent-1",
+		AgentType: "researcher",
+	})
+
+	// The subagent runs a tool and spawns a nested subagent.
+	tracker.Observe(assistantMsg("msg_sub", &parentID,
+		claudeagent.Usage{InputTokens: 100, OutputTokens: 100},
+		claudeagent.ToolUseContentBlock{Type: "tool_use", ID: "toolu_read", Name: "Read", Input: json.RawMessage(`{}`)},
+		taskCall(childID, "coder")))
+	// Duplicate message IDs (one per content block) must not double-count usage.
+	tracker.Observe(assistantMsg("msg_sub", &parentID,
+		claudeagent.Usage{InputTokens: 100, OutputTokens: 100}))
+	tracker.Observe(toolResultMsg(&parentID, "toolu_read"))
+
+	tracker.Observe(assistantMsg("msg_child", &childID,
+		claudeagent.Usage{InputTokens: 25, OutputTokens: 25}))
+	tracker.Observe(toolResultMsg(&parentID, childID))
+
+	runHook(t, hooks, claudeagent.HookEventSubagentStop, claudeagent.SubagentStopHookInput{
+		AgentID:             "agent-1",
+		AgentTranscriptPath: "/tmp/agent-1.jsonl",
+	})
+
+	tracker.Observe(&claudeagent.SDKResultMessage{TotalCostUSD: 3.5})
+
+	roots := tracker.Roots()
+	if len(roots) != 1 || roots[0].ToolUseID != parentID {
+		t.Fatalf("expected single root %s, got %+v", parentID, roots)
+	}
+
+	parent, ok := tracker.Get(parentID)
+	if !ok {
+		t.Fatal("parent subagent not tracked")
+	}
+	if parent.AgentID != "agent-1" || parent.AgentType != "researcher" {
+		t.Errorf("hook data not correlated: %+v", parent)
+	}
+	if parent.Input.SubagentType != "researcher" {
+		t.Errorf("expected decoded task input, got %+v", parent.Input)
+	}
+	if parent.TranscriptPath != "/tmp/agent-1.jsonl" {
+		t.Errorf("expected transcript path, got %q", parent.TranscriptPath)
+	}
+	if parent.Running() {
+		t.Error("parent should be stopped")
+	}
+	if len(parent.Children) != 1 || parent.Children[0] != childID {
+		t.Errorf("expected child %s, got %v", childID, parent.Children)
+	}
+	if parent.Usage.InputTokens != 100 || parent.Usage.OutputTokens != 100 {
+		t.Errorf("unexpected usage: %+v", parent.Usage)
+	}
+	if len(parent.ToolCalls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(parent.ToolCalls))
+	}
+	if parent.ToolCalls[0].Name != "Read" || parent.ToolCalls[0].Result == nil {
+		t.Errorf("expected completed Read call, got %+v", parent.ToolCalls[0])
+	}
+	if parent.CostUSD != 2.0 {
+		t.Errorf("expected cost 2.0, got %v", parent.CostUSD)
+	}
+
+	child, ok := tracker.Get(childID)
+	if !ok {
+		t.Fatal("child subagent not tracked")
+	}
+	if child.ParentToolUseID == nil || *child.ParentToolUseID != parentID {
+		t.Errorf("expected parent %s, got %v", parentID, child.ParentToolUseID)
+	}
+	if child.Running() {
+		t.Error("child should be stopped by its tool result")
+	}
+	if child.CostUSD != 0.5 {
+		t.Errorf("expected cost 0.5, got %v", child.CostUSD)
+	}
+
+	var started, stopped, toolCalls int
+	for _, evt := range events {
+		switch evt.Type {
+		case claudeagent.SubagentEventStarted:
+			started++
+		case claudeagent.SubagentEventStopped:
+			stopped++
+		case claudeagent.SubagentEventToolCall:
+			toolCalls++
+		}
+	}
+	if started != 2 || stopped != 2 || toolCalls != 2 {
+		t.Errorf("unexpected events: started=%d stopped=%d tool_call=%d", started, stopped, toolCalls)
+	}
+}
+
+func TestSubagentTrackerHookBeforeTaskCall(t *testing.T) {
+	tracker := claudeagent.NewSubagentTracker(nil)
+	hooks := tracker.Hooks()
+
+	runHook(t, hooks, claudeagent.HookEventSubagentStart, claudeagent.SubagentStartHookInput{
+		AgentID:   "agent-early",
+		AgentType: "coder",
+	})
+
+	if got := tracker.Subagents(); len(got) != 0 {
+		t.Fatalf("expected no tracked subagents before task call, got %d", len(got))
+	}
+
+	tracker.Observe(assistantMsg("msg_main", nil, claudeagent.Usage{}, taskCall("toolu_late", "coder")))
+
+	sub, ok := tracker.Get("toolu_late")
+	if !ok {
+		t.Fatal("subagent not tracked after task call")
+	}
+	if sub.AgentID != "agent-early" {
+		t.Errorf("expected early hook to be claimed, got agent ID %q", sub.AgentID)
+	}
+	if !sub.Running() {
+		t.Error("subagent should be running")
+	}
+	if running := tracker.Running(); len(running) != 1 {
+		t.Errorf("expected 1 running subagent, got %d", len(running))
+	}
+}
@@ -0,0 +1,124 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	claudeagent "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// scriptedQuery replays a fixed list of messages through Next.
+type scriptedQuery struct {
+	claudeagent.Query
+	msgs []claudeagent.SDKMessage
+}
+
+func (q *scriptedQuery) Next(ctx context.Context) (claudeagent.SDKMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(q.msgs) == 0 {
+		return nil, io.EOF
+	}
+	msg := q.msgs[0]
+	q.msgs = q.msgs[1:]
+
+	return msg, nil
+}
+
+func textMessage(id string, blocks ...claudeagent.ContentBlock) *claudeagent.SDKAssistantMessage {
+	return &claudeagent.SDKAssistantMessage{
+		Message: claudeagent.APIAssistantMessage{ID: id, Content: blocks},
+	}
+}
+
+func text(s string) claudeagent.TextContentBlock {
+	return claudeagent.TextContentBlock{Type: "text", Text: s}
+}
+
+func TestTextStreamCompletedBlocks(t *testing.T) {
+	parent := "toolu_sub"
+	q := &scriptedQuery{msgs: []claudeagent.SDKMessage{
+		textMessage("msg_1",
+			claudeagent.ThinkingBlock{Type: "thinking", Thinking: "pondering"},
+			text("Hello")),
+		&claudeagent.SDKAssistantMessage{
+			Message:         claudeagent.APIAssistantMessage{ID: "msg_sub", Content: []claudeagent.ContentBlock{text("subagent")}},
+			ParentToolUseID: &parent,
+		},
+		textMessage("msg_2", text("world")),
+		&claudeagent.SDKResultMessage{Subtype: claudeagent.ResultSubtypeSuccess},
+		textMessage("msg_3", text("after result")),
+	}}
+
+	r := claudeagent.NewTextStream(context.Background(), q, &claudeagent.TextStreamOptions{ExcludeThinking: true})
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "Hello\nworld" {
+		t.Fatalf("unexpected text %q", got)
+	}
+}
+
+func TestTextStreamDeltasNotDuplicated(t *testing.T) {
+	sep := " | "
+	q := &scriptedQuery{msgs: []claudeagent.SDKMessage{
+		streamEvent(t, "", `{"type":"message_start","message":{"id":"msg_1","content":[]}}`),
+		streamEvent(t, "", `{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`),
+		streamEvent(t, "", `{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`),
+		streamEvent(t, "", `{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`),
+		streamEvent(t, "", `{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hel"}}`),
+		streamEvent(t, "", `{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"lo"}}`),
+		textMessage("msg_1", text("Hello")),
+		&claudeagent.SDKResultMessage{Subtype: claudeagent.ResultSubtypeSuccess},
+		textMessage("msg_2", text("second turn")),
+		&claudeagent.SDKResultMessage{Subtype: claudeagent.ResultSubtypeSuccess},
+	}}
+
+	r := claudeagent.NewTextStream(context.Background(), q, &claudeagent.TextStreamOptions{
+		BlockSeparator: &sep,
+		MultiTurn:      true,
+	})
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "hmm | Hello\n\nsecond turn" {
+		t.Fatalf("unexpected text %q", got)
+	}
+}
+
+func TestTextStreamEndsWithResultError(t *testing.T) {
+	q := &scriptedQuery{msgs: []claudeagent.SDKMessage{
+		textMessage("msg_1", text("partial answer")),
+		&claudeagent.SDKResultMessage{
+			Subtype: claudeagent.ResultSubtypeErrorMaxTurns,
+			IsError: true,
+			Errors:  []string{"reached max turns"},
+		},
+	}}
+
+	r := claudeagent.NewTextStream(context.Background(), q, nil)
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if string(got) != "partial answer" {
+		t.Fatalf("unexpected text %q", got)
+	}
+
+	var resultErr *clauderrs.ResultError
+	if !errors.As(err, &resultErr) {
+		t.Fatalf("expected ResultError, got %v", err)
+	}
+	if resultErr.Code() != clauderrs.ErrCodeResultMaxTurns || resultErr.Subtype() != claudeagent.ResultSubtypeErrorMaxTurns {
+		t.Errorf("unexpected result error: %v", resultErr)
+	}
+}
@@ -0,0 +1,151 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	claudeagent "github.com/connerohnesorge/claude-agent-sdk-go/pkg/claude"
+	"github.com/connerohnesorge/claude-agent-sdk-go/pkg/clauderrs"
+)
+
+// memoryTransport is an in-memory CLI that answers each user message with a
+// result.
+type memoryTransport struct {
+	lines chan []byte
+
+	mu       sync.Mutex
+	written  []string
+	closed   bool
+	inputEnd bool
+}
+
+func newMemoryTransport() *memoryTransport {
+	return &memoryTransport{lines: make(chan []byte, 16)}
+}
+
+func (m *memoryTransport) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case line, ok := <-m.lines:
+		if !ok {
+			return nil, io.EOF
+		}
+
+		return line, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *memoryTransport) Write(_ context.Context, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed || m.inputEnd {
+		return errors.New("input closed")
+	}
+	m.written = append(m.written, string(data))
+	if strings.Contains(string(data), `"type":"user"`) {
+		m.lines <- []byte(`{"type":"result","subtype":"success","session_id":"mem","num_turns":1,"result":"ok"}` + "\n")
+	}
+
+	return nil
+}
+
+func (m *memoryTransport) CloseInput() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.inputEnd {
+		m.inputEnd = true
+		close(m.lines)
+	}
+
+	return nil
+}
+
+func (m *memoryTransport) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+
+	return nil
+}
+
+func TestTransportFactory_InMemory(t *testing.T) {
+	mem := newMemoryTransport()
+	var config *claudeagent.TransportConfig
+
+	q, err := claudeagent.QueryFunc("hello", &claudeagent.Options{
+		TransportFactory: func(_ context.Context, c *claudeagent.TransportConfig) (claudeagent.Transport, error) {
+			config = c
+
+			return mem, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryFunc: %v", err)
+	}
+
+	if config == nil || len(config.Args) == 0 || config.MaxBufferSize == 0 {
+		t.Fatalf("expected a populated transport config, got %+v", config)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := q.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	result, ok := msg.(*claudeagent.SDKResultMessage)
+	if !ok {
+		t.Fatalf("expected *SDKResultMessage, got %T", msg)
+	}
+	if result.SessionID() != "mem" {
+		t.Errorf("expected session mem, got %q", result.SessionID())
+	}
+
+	if err := mem.CloseInput(); err != nil {
+		t.Fatalf("CloseInput: %v", err)
+	}
+	if _, err := q.Next(ctx); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF after the transport ended, got %v", err)
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if !mem.closed {
+		t.Error("expected the transport to be closed")
+	}
+	if len(mem.written) != 1 || !strings.Contains(mem.written[0], "hello") {
+		t.Errorf("expected the prompt to be written once, got %q", mem.written)
+	}
+}
+
+func TestTransportFactory_Error(t *testing.T) {
+	cause := errors.New("sidecar unavailable")
+
+	_, err := claudeagent.QueryFunc("hello", &claudeagent.Options{
+		TransportFactory: func(context.Context, *claudeagent.TransportConfig) (claudeagent.Transport, error) {
+			return nil, cause
+		},
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected the factory error, got %v", err)
+	}
+
+	var procErr *clauderrs.ProcessError
+	if !errors.As(err, &procErr) {
+		t.Fatalf("expected *clauderrs.ProcessError, got %T", err)
+	}
+}